@@ -0,0 +1,57 @@
+// Command mock-oidc serves a local OpenID Connect provider that signs
+// everyone in as one configurable user, for trying out and testing single
+// sign-on without the campus identity provider:
+//
+//	go run ./cmd/mock-oidc -groups cft-staff
+//
+// and start the API with
+//
+//	OIDC_ISSUER=http://localhost:9400 OIDC_CLIENT_ID=carbon-footprint-tracker OIDC_CLIENT_SECRET=secret
+//	OIDC_REDIRECT_URL=http://localhost:8080/auth/oidc/callback OIDC_ROLE_MAPPING=cft-staff=staff
+//
+// then open http://localhost:8080/auth/oidc/login in a browser.
+package main
+
+import (
+	"carbon-footprint-tracker/oidc"
+	"flag"
+	"log"
+	"net/http"
+	"strings"
+)
+
+func main() {
+	addr := flag.String("addr", "localhost:9400", "address to listen on")
+	issuer := flag.String("issuer", "", "issuer URL (default http://<addr>)")
+	clientID := flag.String("client-id", "carbon-footprint-tracker", "client ID the API uses")
+	clientSecret := flag.String("client-secret", "secret", "client secret the API uses")
+	subject := flag.String("subject", "mock-user-1", "subject of the signed in user")
+	email := flag.String("email", "sso.user@example.org", "email address of the signed in user")
+	name := flag.String("name", "SSO User", "name of the signed in user")
+	groups := flag.String("groups", "", "comma-separated groups of the signed in user")
+	unverified := flag.Bool("unverified-email", false, "don't assert that the email address is verified")
+	flag.Parse()
+
+	if *issuer == "" {
+		*issuer = "http://" + *addr
+	}
+	user := oidc.Identity{
+		Subject:       *subject,
+		Email:         *email,
+		EmailVerified: !*unverified,
+		Name:          *name,
+		Groups:        []string{},
+	}
+	for _, g := range strings.Split(*groups, ",") {
+		if g = strings.TrimSpace(g); g != "" {
+			user.Groups = append(user.Groups, g)
+		}
+	}
+
+	provider, err := oidc.NewMockProvider(*issuer, *clientID, *clientSecret, user)
+	if err != nil {
+		log.Fatalf("Failed to create mock provider: %v", err)
+	}
+	log.Printf("Mock OIDC provider for %s (groups %v) at %s", user.Email, user.Groups, *issuer)
+	log.Fatal(http.ListenAndServe(*addr, provider))
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"carbon-footprint-tracker/config"
+	"carbon-footprint-tracker/migrations"
+	"carbon-footprint-tracker/models"
+	"carbon-footprint-tracker/utils"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+)
+
+// runCommand runs a command-line subcommand instead of the HTTP server.
+func runCommand(name string, args []string) error {
+	switch name {
+	case "migrate":
+		return migrate()
+	case "bootstrap-admin":
+		return bootstrapAdmin(args)
+	case "purge-deleted":
+		return purgeDeleted()
+	default:
+		return fmt.Errorf("unknown command %q", name)
+	}
+}
+
+// migrate applies any pending schema migrations.
+func migrate() error {
+	applied, err := migrations.Apply(config.DB)
+	for _, m := range applied {
+		log.Printf("Applied migration %04d_%s", m.Version, m.Name)
+	}
+	if err != nil {
+		return err
+	}
+	if len(applied) == 0 {
+		log.Println("Database schema is up to date")
+	}
+	return nil
+}
+
+// bootstrapAdmin creates the first super-admin account on a fresh install,
+// in the default organisation. It refuses to run once a super-admin exists;
+// further admins are invited.
+//
+//	carbon-footprint-tracker bootstrap-admin -name "Site Admin" -email admin@example.org
+//
+// The password is read from -password or BOOTSTRAP_ADMIN_PASSWORD.
+func bootstrapAdmin(args []string) error {
+	flags := flag.NewFlagSet("bootstrap-admin", flag.ContinueOnError)
+	name := flags.String("name", "Administrator", "display name of the admin")
+	email := flags.String("email", "", "email address of the admin (required)")
+	password := flags.String("password", os.Getenv("BOOTSTRAP_ADMIN_PASSWORD"), "password of the admin")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *email == "" {
+		return errors.New("-email is required")
+	}
+	if *password == "" {
+		return errors.New("a password is required, via -password or BOOTSTRAP_ADMIN_PASSWORD")
+	}
+	if err := utils.ValidatePasswordStrength(*password, *name, *email); err != nil {
+		return err
+	}
+
+	if config.AutoMigrate {
+		if err := migrate(); err != nil {
+			return err
+		}
+	}
+
+	superAdmins, err := models.CountUsersWithRole(models.RoleSuperAdmin)
+	if err != nil {
+		return err
+	}
+	if superAdmins > 0 {
+		return errors.New("a super-admin account already exists, invite further admins instead")
+	}
+
+	user := models.User{Name: *name, Email: *email, PasswordHash: *password, Role: models.RoleSuperAdmin, OrgID: config.DefaultOrganisationID}
+	if err := user.Create(); err != nil {
+		return err
+	}
+	log.Printf("Created super-admin account %d for %s", user.ID, user.Email)
+	return nil
+}
+
+// purgeDeleted removes module records deleted more than DELETED_RETENTION_DAYS
+// ago, like the server does hourly.
+func purgeDeleted() error {
+	if config.DeletedRetentionDays == 0 {
+		return errors.New("DELETED_RETENTION_DAYS is 0, deleted records are kept")
+	}
+	n, err := models.PurgeDeleted(deletedCutoff())
+	log.Printf("Purged %d deleted records", n)
+	return err
+}
@@ -4,6 +4,8 @@ import (
 	"database/sql"
 	"log"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq" // PostgreSQL driver
@@ -11,6 +13,16 @@ import (
 
 var DB *sql.DB
 var JWTSecret []byte
+var AutoMigrate bool                     // Apply pending schema migrations at server startup
+var PublicRegistration bool              // Allow anonymous sign-up of viewer accounts
+var PasswordResetURL string              // Frontend page that password reset emails link to
+var MFARequiredRoles []string            // Roles that must set up two-factor authentication
+var TrustedProxies []string              // Proxies whose X-Forwarded-For is believed for the client IP
+var DefaultOrganisationID int            // Organisation that self-registered viewers and the bootstrap admin join
+var DeletedRetentionDays int             // Days deleted module records can be restored before they are purged; 0 keeps them
+var SSORedirectURL string                // Frontend page that single sign-on hands the tokens to
+var GeneratorSFCBenchmark float64        // Specific fuel consumption (L/kWh) expected of a diesel generator set in good condition
+var GeneratorSFCBenchmarkBiofuel float64 // The same for biofuel generator sets; 0 if there is none
 
 func LoadConfig() {
 	err := godotenv.Load()
@@ -43,4 +55,76 @@ func LoadConfig() {
 		log.Fatal("JWT_SECRET environment variable not set")
 	}
 	JWTSecret = []byte(jwtSecret)
+
+	// Migrations run at startup unless AUTO_MIGRATE=false, in which case use `migrate`
+	AutoMigrate = !strings.EqualFold(os.Getenv("AUTO_MIGRATE"), "false")
+
+	// Self-registration creates viewers only; PUBLIC_REGISTRATION=false leaves invitations as the only way in
+	PublicRegistration = !strings.EqualFold(os.Getenv("PUBLIC_REGISTRATION"), "false")
+
+	// Reset emails link to this page with ?token=..., or just contain the token when unset
+	PasswordResetURL = os.Getenv("PASSWORD_RESET_URL")
+
+	// Comma-separated roles, e.g. MFA_REQUIRED_ROLES=admin, that can't use the API until TOTP is set up
+	MFARequiredRoles = splitList(os.Getenv("MFA_REQUIRED_ROLES"))
+
+	// Comma-separated IPs or CIDRs of reverse proxies; with none, the client IP is the peer address.
+	// Login throttling is per client IP, so only list proxies that overwrite X-Forwarded-For.
+	TrustedProxies = splitList(os.Getenv("TRUSTED_PROXIES"))
+
+	// Defaults to the organisation that existing data was migrated into
+	DefaultOrganisationID = 1
+	if v := os.Getenv("DEFAULT_ORGANISATION_ID"); v != "" {
+		if DefaultOrganisationID, err = strconv.Atoi(v); err != nil || DefaultOrganisationID < 1 {
+			log.Fatalf("Invalid DEFAULT_ORGANISATION_ID: %q", v)
+		}
+	}
+
+	// Single sign-on redirects here with the tokens in the URL fragment, or responds with JSON when unset
+	SSORedirectURL = os.Getenv("SSO_REDIRECT_URL")
+
+	// The server purges older deleted records hourly; DELETED_RETENTION_DAYS=0 turns the purge off
+	DeletedRetentionDays = 30
+	if v := os.Getenv("DELETED_RETENTION_DAYS"); v != "" {
+		if DeletedRetentionDays, err = strconv.Atoi(v); err != nil || DeletedRetentionDays < 0 {
+			log.Fatalf("Invalid DELETED_RETENTION_DAYS: %q", v)
+		}
+	}
+
+	// Generator analytics flag months whose fuel use per kWh is well above this, e.g. GENERATOR_SFC_BENCHMARK=0.27
+	GeneratorSFCBenchmark = 0.3
+	if v := os.Getenv("GENERATOR_SFC_BENCHMARK"); v != "" {
+		if GeneratorSFCBenchmark, err = strconv.ParseFloat(v, 64); err != nil || GeneratorSFCBenchmark <= 0 {
+			log.Fatalf("Invalid GENERATOR_SFC_BENCHMARK: %q", v)
+		}
+	}
+
+	// Biofuel carries less energy per litre than diesel, so biofuel sets have their own benchmark,
+	// e.g. GENERATOR_SFC_BENCHMARK_BIOFUEL=0.33, and aren't flagged for their fuel use when it is unset
+	if v := os.Getenv("GENERATOR_SFC_BENCHMARK_BIOFUEL"); v != "" {
+		if GeneratorSFCBenchmarkBiofuel, err = strconv.ParseFloat(v, 64); err != nil || GeneratorSFCBenchmarkBiofuel <= 0 {
+			log.Fatalf("Invalid GENERATOR_SFC_BENCHMARK_BIOFUEL: %q", v)
+		}
+	}
+}
+
+// splitList splits a comma-separated setting, dropping empty entries.
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// MFARequired reports whether users with role must use two-factor authentication.
+func MFARequired(role string) bool {
+	for _, r := range MFARequiredRoles {
+		if r == role {
+			return true
+		}
+	}
+	return false
 }
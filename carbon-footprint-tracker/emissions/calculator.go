@@ -0,0 +1,212 @@
+package emissions
+
+import (
+	"carbon-footprint-tracker/models"
+	"time"
+)
+
+// Component names used in dashboard breakdowns and reports.
+const (
+	ComponentElectrical       = "Electrical"
+	ComponentTransport        = "Transport"
+	ComponentWaterConsumption = "Water Consumption"
+	ComponentWaterTreatment   = "Water Treatment"
+	ComponentWaste            = "Waste"
+	ComponentAccommodation    = "Accommodation"
+	ComponentGoodsPurchased   = "Goods Purchased"
+	ComponentFoodConsumption  = "Food Consumption"
+)
+
+// Components lists every component in reporting order.
+var Components = []string{
+	ComponentElectrical,
+	ComponentTransport,
+	ComponentWaterConsumption,
+	ComponentWaterTreatment,
+	ComponentWaste,
+	ComponentAccommodation,
+	ComponentGoodsPurchased,
+	ComponentFoodConsumption,
+}
+
+// Line is a single emission contribution within a record, e.g. the grid
+// electricity or the treatment chemicals of a water treatment entry.
+type Line struct {
+	Activity string  `json:"activity"`
+	Quantity float64 `json:"quantity"`
+	Factor   Factor  `json:"factor"`
+	CO2eKg   float64 `json:"co2e_kg"`
+	Classification
+}
+
+// Result is the footprint of one activity record.
+type Result struct {
+	Component string    `json:"component"`
+	RecordID  int       `json:"record_id"`
+	Date      time.Time `json:"date"`
+	Location  string    `json:"location"`
+	Lines     []Line    `json:"lines"`
+	CO2eKg    float64   `json:"co2e_kg"`
+
+	// Emissions the record avoided elsewhere, e.g. solar generation displacing
+	// grid electricity. They are reported separately and never reduce CO2eKg.
+	Avoided       []Line  `json:"avoided,omitempty"`
+	AvoidedCO2eKg float64 `json:"avoided_co2e_kg"`
+}
+
+func (r *Result) add(activity string, quantity float64, factor Factor, class Classification) {
+	co2e := quantity * factor.Value
+	r.Lines = append(r.Lines, Line{Activity: activity, Quantity: quantity, Factor: factor, CO2eKg: co2e, Classification: class})
+	r.CO2eKg += co2e
+}
+
+func (r *Result) avoid(activity string, quantity float64, factor Factor, class Classification) {
+	co2e := quantity * factor.Value
+	r.Avoided = append(r.Avoided, Line{Activity: activity, Quantity: quantity, Factor: factor, CO2eKg: co2e, Classification: class})
+	r.AvoidedCO2eKg += co2e
+}
+
+// Calculator computes the footprint of individual activity records.
+type Calculator interface {
+	Electric(e models.ElectricConsumption) Result
+	Transport(t models.Transport) Result
+	WaterConsumption(w models.WaterConsumption) Result
+	WaterTreatment(wt models.WaterTreatment) Result
+	Waste(w models.Waste) Result
+	Accommodation(a models.Accommodation) Result
+	Goods(g models.GoodsPurchased) Result
+	Food(f models.FoodConsumption) Result
+}
+
+type calculator struct {
+	factors FactorSet
+}
+
+// NewCalculator returns a Calculator that takes its factors from factors.
+func NewCalculator(factors FactorSet) Calculator {
+	return &calculator{factors: factors}
+}
+
+func (c *calculator) factor(category, source string, at time.Time) Factor {
+	return c.factors.Factor(category, source, at)
+}
+
+func (c *calculator) Electric(e models.ElectricConsumption) Result {
+	r := Result{Component: ComponentElectrical, RecordID: e.ID, Date: e.Date, Location: e.Location}
+	switch e.Source {
+	case "Main Board":
+		if e.GridElectricityUsedKWH.Valid {
+			r.add("Grid electricity", e.GridElectricityUsedKWH.Float64, c.factor(models.FactorCategoryElectricity, models.FactorSourceGrid, e.Date), scope2Electricity)
+		}
+	case "Diesel Generator":
+		if e.FuelConsumedLiters.Valid {
+			r.add("Generator diesel", e.FuelConsumedLiters.Float64, c.factor(models.FactorCategoryFuel, models.FactorSourceDiesel, e.Date), scope1Direct)
+		}
+	case "Biofuel Generator":
+		if e.FuelConsumedLiters.Valid {
+			r.add("Generator biofuel", e.FuelConsumedLiters.Float64, c.factor(models.FactorCategoryFuel, models.FactorSourceBiofuel, e.Date), scope1Direct)
+		}
+	}
+	// Solar generation emits nothing. Every kWh generated, whether used on
+	// site or exported, displaces a kWh of grid electricity, whichever source
+	// the record is for.
+	if e.SolarGeneratedKWH.Valid {
+		r.avoid("Solar generation", e.SolarGeneratedKWH.Float64, c.factor(models.FactorCategoryElectricity, models.FactorSourceGrid, e.Date), scope2Electricity)
+	}
+	return r
+}
+
+func (c *calculator) Transport(t models.Transport) Result {
+	r := Result{Component: ComponentTransport, RecordID: t.ID, Date: t.Date, Location: t.EventAreaLocation}
+	if t.FuelLiters > 0 {
+		switch t.FuelType {
+		case "Diesel":
+			r.add("Vehicle diesel", t.FuelLiters, c.factor(models.FactorCategoryFuel, models.FactorSourceDiesel, t.Date), scope1Direct)
+		case "Petrol", "Gasoline":
+			r.add("Vehicle petrol", t.FuelLiters, c.factor(models.FactorCategoryFuel, models.FactorSourcePetrol, t.Date), scope1Direct)
+		case "Biofuel":
+			r.add("Vehicle biofuel", t.FuelLiters, c.factor(models.FactorCategoryFuel, models.FactorSourceBiofuel, t.Date), scope1Direct)
+		}
+	}
+	return r
+}
+
+func (c *calculator) WaterConsumption(w models.WaterConsumption) Result {
+	r := Result{Component: ComponentWaterConsumption, RecordID: w.ID, Date: w.Date, Location: w.Location}
+	// Assuming total_consumption_kld is daily in KL (1 KL = 1000 L)
+	r.add("Water supply", w.TotalConsumptionKLD*1000, c.factor(models.FactorCategoryWater, models.FactorSourceWaterTreatmentDist, w.Date), scope3PurchasedGoods)
+	return r
+}
+
+func (c *calculator) WaterTreatment(wt models.WaterTreatment) Result {
+	r := Result{Component: ComponentWaterTreatment, RecordID: wt.ID, Date: wt.Date, Location: wt.Location}
+	if wt.ElectricityUsedKWH.Valid {
+		r.add("Treatment electricity", wt.ElectricityUsedKWH.Float64, c.factor(models.FactorCategoryElectricity, models.FactorSourceGrid, wt.Date), scope2Electricity)
+	}
+	if wt.ChemicalsUsedQuantityKG.Valid {
+		r.add("Treatment chemicals", wt.ChemicalsUsedQuantityKG.Float64, c.factor(models.FactorCategoryChemicals, models.FactorSourceTreatmentChemicals, wt.Date), scope3PurchasedGoods)
+	}
+	return r
+}
+
+func (c *calculator) Waste(w models.Waste) Result {
+	r := Result{Component: ComponentWaste, RecordID: w.ID, Date: w.Date, Location: w.CollectionLocation}
+	if w.WeightKG <= 0 {
+		return r
+	}
+	switch w.WasteType {
+	case "Biodegradable":
+		r.add("Biodegradable waste", w.WeightKG, c.factor(models.FactorCategoryWaste, models.FactorSourceWasteBiodegradable, w.Date), scope3Waste)
+	case "Recyclable":
+		r.add("Recyclable waste", w.WeightKG, c.factor(models.FactorCategoryWaste, models.FactorSourceWasteRecyclable, w.Date), scope3Waste)
+	case "Landfill":
+		r.add("Landfill waste", w.WeightKG, c.factor(models.FactorCategoryWaste, models.FactorSourceWasteLandfill, w.Date), scope3Waste)
+	case "Non-Biodegradable":
+		if w.SubCategory.String == "E-waste" {
+			r.add("E-waste", w.WeightKG, c.factor(models.FactorCategoryWaste, models.FactorSourceWasteEwaste, w.Date), scope3Waste)
+		} else {
+			r.add("Non-biodegradable waste", w.WeightKG, c.factor(models.FactorCategoryWaste, models.FactorSourceWasteLandfill, w.Date), scope3Waste)
+		}
+	}
+	return r
+}
+
+func (c *calculator) Accommodation(a models.Accommodation) Result {
+	r := Result{Component: ComponentAccommodation, RecordID: a.ID, Date: a.Date, Location: a.AccommodationFacilityName}
+	if a.ElectricityConsumptionKWH.Valid {
+		r.add("Accommodation electricity", a.ElectricityConsumptionKWH.Float64, c.factor(models.FactorCategoryElectricity, models.FactorSourceGrid, a.Date), scope3BusinessTravel)
+	}
+	// Assuming water consumption is L/person/day for the duration.
+	if a.WaterConsumptionLPD.Valid {
+		// Total L = people_count * nights * L/person/day
+		liters := float64(a.PeopleCount) * float64(a.Nights) * a.WaterConsumptionLPD.Float64
+		r.add("Accommodation water", liters, c.factor(models.FactorCategoryWater, models.FactorSourceWaterTreatmentDist, a.Date), scope3BusinessTravel)
+	}
+	return r
+}
+
+func (c *calculator) Goods(g models.GoodsPurchased) Result {
+	r := Result{Component: ComponentGoodsPurchased, RecordID: g.ID, Date: g.Date, Location: g.Location}
+	r.add("Purchased goods spend", g.BillAmountINR, c.factor(models.FactorCategoryGoods, models.FactorSourceGoodsSpend, g.Date), scope3PurchasedGoods)
+	return r
+}
+
+func (c *calculator) Food(f models.FoodConsumption) Result {
+	r := Result{Component: ComponentFoodConsumption, RecordID: f.ID, Date: f.Date, Location: f.Location}
+	if f.WaterUsedLWashingCooking.Valid {
+		r.add("Kitchen water", f.WaterUsedLWashingCooking.Float64, c.factor(models.FactorCategoryWater, models.FactorSourceFoodWater, f.Date), scope3PurchasedGoods)
+	}
+	if f.FuelUsedType.Valid && f.FuelUsedQuantity.Valid {
+		switch f.FuelUsedType.String {
+		case "LPG":
+			r.add("Cooking LPG", f.FuelUsedQuantity.Float64, c.factor(models.FactorCategoryFuel, models.FactorSourceLPG, f.Date), scope1Direct)
+		case "Firewood":
+			r.add("Cooking firewood", f.FuelUsedQuantity.Float64, c.factor(models.FactorCategoryFuel, models.FactorSourceFirewood, f.Date), scope1Direct)
+		case "Electricity":
+			r.add("Cooking electricity", f.FuelUsedQuantity.Float64, c.factor(models.FactorCategoryElectricity, models.FactorSourceGrid, f.Date), scope2Electricity)
+		}
+	}
+	// Embodied carbon of food items themselves is a huge factor but very complex.
+	// f.QuantityCookedKgLiter could be used with specific food-type emission factors.
+	return r
+}
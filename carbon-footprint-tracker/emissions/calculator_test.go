@@ -0,0 +1,421 @@
+package emissions
+
+import (
+	"carbon-footprint-tracker/models"
+	"database/sql"
+	"math"
+	"testing"
+	"time"
+)
+
+var recordDate = time.Date(2024, 6, 15, 0, 0, 0, 0, time.UTC)
+
+func day(year int, month time.Month, d int) time.Time {
+	return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+}
+
+func validFloat(f float64) sql.NullFloat64 { return sql.NullFloat64{Float64: f, Valid: true} }
+func validString(s string) sql.NullString  { return sql.NullString{String: s, Valid: true} }
+
+func approxEqual(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+
+// wantLine is the expected part of a Line.
+type wantLine struct {
+	activity string
+	quantity float64
+	factor   float64
+	class    Classification
+}
+
+func checkLines(t *testing.T, kind string, got []Line, want []wantLine) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d %s lines %+v, want %d", len(got), kind, got, len(want))
+	}
+	for i, w := range want {
+		l := got[i]
+		if l.Activity != w.activity || !approxEqual(l.Quantity, w.quantity) || !approxEqual(l.Factor.Value, w.factor) || l.Classification != w.class {
+			t.Errorf("%s line %d = %+v, want %+v", kind, i, l, w)
+		}
+		if !approxEqual(l.CO2eKg, w.quantity*w.factor) {
+			t.Errorf("%s line %d co2e = %v, want %v", kind, i, l.CO2eKg, w.quantity*w.factor)
+		}
+	}
+}
+
+func checkResult(t *testing.T, r Result, component string, lines, avoided []wantLine) {
+	t.Helper()
+	if r.Component != component {
+		t.Errorf("component = %q, want %q", r.Component, component)
+	}
+	checkLines(t, "emission", r.Lines, lines)
+	checkLines(t, "avoided", r.Avoided, avoided)
+
+	var total, avoidedTotal float64
+	for _, w := range lines {
+		total += w.quantity * w.factor
+	}
+	for _, w := range avoided {
+		avoidedTotal += w.quantity * w.factor
+	}
+	if !approxEqual(r.CO2eKg, total) {
+		t.Errorf("co2e = %v, want %v", r.CO2eKg, total)
+	}
+	if !approxEqual(r.AvoidedCO2eKg, avoidedTotal) {
+		t.Errorf("avoided co2e = %v, want %v", r.AvoidedCO2eKg, avoidedTotal)
+	}
+}
+
+func TestElectric(t *testing.T) {
+	tests := []struct {
+		name    string
+		record  models.ElectricConsumption
+		lines   []wantLine
+		avoided []wantLine
+	}{
+		{
+			name:   "grid",
+			record: models.ElectricConsumption{Source: "Main Board", GridElectricityUsedKWH: validFloat(1000)},
+			lines:  []wantLine{{"Grid electricity", 1000, DefaultGridElectricity, scope2Electricity}},
+		},
+		{
+			name:   "grid without consumption",
+			record: models.ElectricConsumption{Source: "Main Board"},
+		},
+		{
+			name:   "grid with zero consumption",
+			record: models.ElectricConsumption{Source: "Main Board", GridElectricityUsedKWH: validFloat(0)},
+			lines:  []wantLine{{"Grid electricity", 0, DefaultGridElectricity, scope2Electricity}},
+		},
+		{
+			name:   "diesel generator",
+			record: models.ElectricConsumption{Source: "Diesel Generator", FuelConsumedLiters: validFloat(50)},
+			lines:  []wantLine{{"Generator diesel", 50, DefaultDiesel, scope1Direct}},
+		},
+		{
+			name:   "diesel generator without fuel",
+			record: models.ElectricConsumption{Source: "Diesel Generator", EnergyGeneratedDgKWH: validFloat(120)},
+		},
+		{
+			name:   "biofuel generator",
+			record: models.ElectricConsumption{Source: "Biofuel Generator", FuelConsumedLiters: validFloat(40)},
+			lines:  []wantLine{{"Generator biofuel", 40, DefaultBiofuel, scope1Direct}},
+		},
+		{
+			name:   "generator fuel ignored on the main board",
+			record: models.ElectricConsumption{Source: "Main Board", FuelConsumedLiters: validFloat(50)},
+		},
+		{
+			name:    "solar",
+			record:  models.ElectricConsumption{Source: "Solar", SolarGeneratedKWH: validFloat(300)},
+			avoided: []wantLine{{"Solar generation", 300, DefaultGridElectricity, scope2Electricity}},
+		},
+		{
+			name: "solar alongside grid",
+			record: models.ElectricConsumption{
+				Source:                 "Main Board",
+				GridElectricityUsedKWH: validFloat(1000),
+				SolarGeneratedKWH:      validFloat(200),
+				SolarSelfConsumedKWH:   validFloat(150),
+				SolarExportedKWH:       validFloat(50),
+			},
+			lines:   []wantLine{{"Grid electricity", 1000, DefaultGridElectricity, scope2Electricity}},
+			avoided: []wantLine{{"Solar generation", 200, DefaultGridElectricity, scope2Electricity}},
+		},
+		{
+			name:    "solar with zero generation",
+			record:  models.ElectricConsumption{Source: "Solar", SolarGeneratedKWH: validFloat(0)},
+			avoided: []wantLine{{"Solar generation", 0, DefaultGridElectricity, scope2Electricity}},
+		},
+		{
+			name:   "unknown source",
+			record: models.ElectricConsumption{Source: "Wind Turbine", GridElectricityUsedKWH: validFloat(100)},
+		},
+	}
+
+	c := NewCalculator(DefaultFactorSet())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.record.Date = recordDate
+			checkResult(t, c.Electric(tt.record), ComponentElectrical, tt.lines, tt.avoided)
+		})
+	}
+}
+
+func TestTransport(t *testing.T) {
+	tests := []struct {
+		name   string
+		record models.Transport
+		lines  []wantLine
+	}{
+		{
+			name:   "diesel bus",
+			record: models.Transport{VehicleType: "Bus", FuelType: "Diesel", FuelLiters: 30},
+			lines:  []wantLine{{"Vehicle diesel", 30, DefaultDiesel, scope1Direct}},
+		},
+		{
+			name:   "petrol car",
+			record: models.Transport{VehicleType: "Car", FuelType: "Petrol", FuelLiters: 12},
+			lines:  []wantLine{{"Vehicle petrol", 12, DefaultPetrol, scope1Direct}},
+		},
+		{
+			name:   "gasoline two-wheeler",
+			record: models.Transport{VehicleType: "Two-wheeler", FuelType: "Gasoline", FuelLiters: 3},
+			lines:  []wantLine{{"Vehicle petrol", 3, DefaultPetrol, scope1Direct}},
+		},
+		{
+			name:   "biofuel truck",
+			record: models.Transport{VehicleType: "Truck", FuelType: "Biofuel", FuelLiters: 25},
+			lines:  []wantLine{{"Vehicle biofuel", 25, DefaultBiofuel, scope1Direct}},
+		},
+		{
+			name:   "electric car",
+			record: models.Transport{VehicleType: "Car", FuelType: "Electric", DistanceKM: 80},
+		},
+		{
+			name:   "unknown fuel",
+			record: models.Transport{VehicleType: "Van", FuelType: "CNG", FuelLiters: 10},
+		},
+		{
+			name:   "zero fuel",
+			record: models.Transport{VehicleType: "Bus", FuelType: "Diesel", DistanceKM: 40},
+		},
+	}
+
+	c := NewCalculator(DefaultFactorSet())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.record.Date = recordDate
+			checkResult(t, c.Transport(tt.record), ComponentTransport, tt.lines, nil)
+		})
+	}
+}
+
+func TestWaste(t *testing.T) {
+	tests := []struct {
+		name   string
+		record models.Waste
+		lines  []wantLine
+	}{
+		{
+			name:   "biodegradable",
+			record: models.Waste{WasteType: "Biodegradable", SubCategory: validString("Food"), WeightKG: 100},
+			lines:  []wantLine{{"Biodegradable waste", 100, DefaultWasteBiodegradable, scope3Waste}},
+		},
+		{
+			name:   "recyclable",
+			record: models.Waste{WasteType: "Recyclable", SubCategory: validString("Paper"), WeightKG: 40},
+			lines:  []wantLine{{"Recyclable waste", 40, DefaultWasteRecyclable, scope3Waste}},
+		},
+		{
+			name:   "landfill",
+			record: models.Waste{WasteType: "Landfill", WeightKG: 75},
+			lines:  []wantLine{{"Landfill waste", 75, DefaultWasteLandfill, scope3Waste}},
+		},
+		{
+			name:   "e-waste",
+			record: models.Waste{WasteType: "Non-Biodegradable", SubCategory: validString("E-waste"), WeightKG: 8},
+			lines:  []wantLine{{"E-waste", 8, DefaultWasteEwaste, scope3Waste}},
+		},
+		{
+			name:   "non-biodegradable",
+			record: models.Waste{WasteType: "Non-Biodegradable", SubCategory: validString("Plastic"), WeightKG: 20},
+			lines:  []wantLine{{"Non-biodegradable waste", 20, DefaultWasteLandfill, scope3Waste}},
+		},
+		{
+			name:   "non-biodegradable without sub-category",
+			record: models.Waste{WasteType: "Non-Biodegradable", WeightKG: 5},
+			lines:  []wantLine{{"Non-biodegradable waste", 5, DefaultWasteLandfill, scope3Waste}},
+		},
+		{
+			name:   "zero weight",
+			record: models.Waste{WasteType: "Landfill"},
+		},
+		{
+			name:   "unknown type",
+			record: models.Waste{WasteType: "Hazardous", WeightKG: 10},
+		},
+	}
+
+	c := NewCalculator(DefaultFactorSet())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.record.Date = recordDate
+			checkResult(t, c.Waste(tt.record), ComponentWaste, tt.lines, nil)
+		})
+	}
+}
+
+func TestOtherComponents(t *testing.T) {
+	c := NewCalculator(DefaultFactorSet())
+	tests := []struct {
+		name      string
+		result    Result
+		component string
+		lines     []wantLine
+	}{
+		{
+			name:      "water consumption",
+			result:    c.WaterConsumption(models.WaterConsumption{Date: recordDate, TotalConsumptionKLD: 2}),
+			component: ComponentWaterConsumption,
+			lines:     []wantLine{{"Water supply", 2000, DefaultWaterTreatmentDist, scope3PurchasedGoods}},
+		},
+		{
+			name: "water treatment",
+			result: c.WaterTreatment(models.WaterTreatment{
+				Date: recordDate, ElectricityUsedKWH: validFloat(90), ChemicalsUsedQuantityKG: validFloat(4),
+			}),
+			component: ComponentWaterTreatment,
+			lines: []wantLine{
+				{"Treatment electricity", 90, DefaultGridElectricity, scope2Electricity},
+				{"Treatment chemicals", 4, DefaultChemicals, scope3PurchasedGoods},
+			},
+		},
+		{
+			name:      "water treatment without figures",
+			result:    c.WaterTreatment(models.WaterTreatment{Date: recordDate}),
+			component: ComponentWaterTreatment,
+		},
+		{
+			name: "accommodation",
+			result: c.Accommodation(models.Accommodation{
+				Date: recordDate, PeopleCount: 3, Nights: 2,
+				ElectricityConsumptionKWH: validFloat(30), WaterConsumptionLPD: validFloat(100),
+			}),
+			component: ComponentAccommodation,
+			lines: []wantLine{
+				{"Accommodation electricity", 30, DefaultGridElectricity, scope3BusinessTravel},
+				{"Accommodation water", 600, DefaultWaterTreatmentDist, scope3BusinessTravel},
+			},
+		},
+		{
+			name:      "accommodation without figures",
+			result:    c.Accommodation(models.Accommodation{Date: recordDate, PeopleCount: 3, Nights: 2}),
+			component: ComponentAccommodation,
+		},
+		{
+			name:      "goods",
+			result:    c.Goods(models.GoodsPurchased{Date: recordDate, BillAmountINR: 10000}),
+			component: ComponentGoodsPurchased,
+			lines:     []wantLine{{"Purchased goods spend", 10000, DefaultGoodsCost, scope3PurchasedGoods}},
+		},
+		{
+			name: "food with LPG",
+			result: c.Food(models.FoodConsumption{
+				Date: recordDate, WaterUsedLWashingCooking: validFloat(500),
+				FuelUsedType: validString("LPG"), FuelUsedQuantity: validFloat(14),
+			}),
+			component: ComponentFoodConsumption,
+			lines: []wantLine{
+				{"Kitchen water", 500, DefaultFoodWater, scope3PurchasedGoods},
+				{"Cooking LPG", 14, DefaultLPG, scope1Direct},
+			},
+		},
+		{
+			name:      "food with firewood",
+			result:    c.Food(models.FoodConsumption{Date: recordDate, FuelUsedType: validString("Firewood"), FuelUsedQuantity: validFloat(20)}),
+			component: ComponentFoodConsumption,
+			lines:     []wantLine{{"Cooking firewood", 20, DefaultFirewood, scope1Direct}},
+		},
+		{
+			name:      "food with electricity",
+			result:    c.Food(models.FoodConsumption{Date: recordDate, FuelUsedType: validString("Electricity"), FuelUsedQuantity: validFloat(15)}),
+			component: ComponentFoodConsumption,
+			lines:     []wantLine{{"Cooking electricity", 15, DefaultGridElectricity, scope2Electricity}},
+		},
+		{
+			name:      "food fuel without quantity",
+			result:    c.Food(models.FoodConsumption{Date: recordDate, FuelUsedType: validString("LPG")}),
+			component: ComponentFoodConsumption,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkResult(t, tt.result, tt.component, tt.lines, nil)
+		})
+	}
+}
+
+func TestFactorSet(t *testing.T) {
+	library := models.EmissionFactors{
+		{ID: 1, Category: models.FactorCategoryElectricity, Source: models.FactorSourceGrid, Value: 0.82,
+			ValidFrom: day(2020, 1, 1), ValidTo: sql.NullTime{Time: day(2022, 12, 31), Valid: true}},
+		{ID: 2, Category: models.FactorCategoryElectricity, Source: models.FactorSourceGrid, Value: 0.71,
+			ValidFrom: day(2023, 1, 1)},
+		// Starts later than factor 2 and overlaps it, so wins within its period.
+		{ID: 3, Category: models.FactorCategoryElectricity, Source: models.FactorSourceGrid, Value: 0.65,
+			ValidFrom: day(2024, 4, 1), ValidTo: sql.NullTime{Time: day(2024, 9, 30), Valid: true}},
+		{ID: 4, Category: models.FactorCategoryFuel, Source: models.FactorSourceDiesel, Value: 2.7,
+			ValidFrom: day(2024, 1, 1)},
+	}
+
+	tests := []struct {
+		name     string
+		category string
+		source   string
+		at       time.Time
+		wantID   int
+		want     float64
+	}{
+		{"before any period", models.FactorCategoryElectricity, models.FactorSourceGrid, day(2019, 12, 31), 0, DefaultGridElectricity},
+		{"first day of a period", models.FactorCategoryElectricity, models.FactorSourceGrid, day(2020, 1, 1), 1, 0.82},
+		{"last day of a period", models.FactorCategoryElectricity, models.FactorSourceGrid, day(2022, 12, 31), 1, 0.82},
+		{"open-ended period", models.FactorCategoryElectricity, models.FactorSourceGrid, day(2023, 6, 1), 2, 0.71},
+		{"latest start wins", models.FactorCategoryElectricity, models.FactorSourceGrid, day(2024, 6, 15), 3, 0.65},
+		{"after an overlapping period", models.FactorCategoryElectricity, models.FactorSourceGrid, day(2024, 10, 1), 2, 0.71},
+		{"time of day ignored", models.FactorCategoryElectricity, models.FactorSourceGrid, time.Date(2022, 12, 31, 23, 30, 0, 0, time.UTC), 1, 0.82},
+		{"fuel from the library", models.FactorCategoryFuel, models.FactorSourceDiesel, day(2024, 2, 1), 4, 2.7},
+		{"fuel before the library", models.FactorCategoryFuel, models.FactorSourceDiesel, day(2023, 12, 31), 0, DefaultDiesel},
+		{"source missing from the library", models.FactorCategoryFuel, models.FactorSourcePetrol, day(2024, 2, 1), 0, DefaultPetrol},
+		{"unknown factor", "Refrigerants", "R-22", day(2024, 2, 1), 0, 0},
+	}
+
+	set := NewFactorSet(library)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := set.Factor(tt.category, tt.source, tt.at)
+			if f.ID != tt.wantID || !approxEqual(f.Value, tt.want) {
+				t.Errorf("Factor(%s, %s, %s) = %+v, want ID %d value %v", tt.category, tt.source, tt.at.Format("2006-01-02"), f, tt.wantID, tt.want)
+			}
+			if f.Category != tt.category || f.Source != tt.source {
+				t.Errorf("Factor(%s, %s) named %s/%s", tt.category, tt.source, f.Category, f.Source)
+			}
+		})
+	}
+}
+
+func TestCalculatorUsesRecordDate(t *testing.T) {
+	library := models.EmissionFactors{
+		{ID: 1, Category: models.FactorCategoryElectricity, Source: models.FactorSourceGrid, Value: 0.8,
+			ValidFrom: day(2023, 1, 1), ValidTo: sql.NullTime{Time: day(2023, 12, 31), Valid: true}},
+		{ID: 2, Category: models.FactorCategoryElectricity, Source: models.FactorSourceGrid, Value: 0.6,
+			ValidFrom: day(2024, 1, 1)},
+	}
+
+	tests := []struct {
+		name   string
+		date   time.Time
+		wantID int
+		want   float64
+	}{
+		{"previous period", day(2023, 7, 1), 1, 0.8},
+		{"current period", day(2024, 7, 1), 2, 0.6},
+		{"before the library", day(2022, 7, 1), 0, DefaultGridElectricity},
+	}
+
+	c := NewCalculator(NewFactorSet(library))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := c.Electric(models.ElectricConsumption{
+				Date: tt.date, Source: "Main Board", GridElectricityUsedKWH: validFloat(100), SolarGeneratedKWH: validFloat(10),
+			})
+			if len(r.Lines) != 1 || r.Lines[0].Factor.ID != tt.wantID || !approxEqual(r.CO2eKg, 100*tt.want) {
+				t.Errorf("lines = %+v, co2e = %v, want factor %d and %v", r.Lines, r.CO2eKg, tt.wantID, 100*tt.want)
+			}
+			if len(r.Avoided) != 1 || r.Avoided[0].Factor.ID != tt.wantID || !approxEqual(r.AvoidedCO2eKg, 10*tt.want) {
+				t.Errorf("avoided = %+v, avoided co2e = %v, want factor %d and %v", r.Avoided, r.AvoidedCO2eKg, tt.wantID, 10*tt.want)
+			}
+		})
+	}
+}
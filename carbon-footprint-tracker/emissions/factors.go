@@ -0,0 +1,88 @@
+package emissions
+
+import (
+	"carbon-footprint-tracker/models"
+	"time"
+)
+
+// Default emission factors, used when the factor library has no factor valid
+// on a record's date.
+const (
+	DefaultGridElectricity = 0.45 // kgCO2e/kWh
+
+	DefaultDiesel  = 2.68 // kgCO2e/L
+	DefaultPetrol  = 2.31 // kgCO2e/L
+	DefaultBiofuel = 1.0  // kgCO2e/L
+
+	DefaultWaterTreatmentDist = 0.00034 // kgCO2e/L
+
+	DefaultWasteBiodegradable = 0.1  // kgCO2e/kg
+	DefaultWasteRecyclable    = -0.1 // kgCO2e/kg
+	DefaultWasteLandfill      = 0.5  // kgCO2e/kg
+	DefaultWasteEwaste        = 2.0  // kgCO2e/kg
+	DefaultGoodsCost          = 0.05 // kgCO2e/INR
+
+	DefaultFoodWater = 0.0001 // kgCO2e/L
+
+	DefaultLPG = 2.98 // kgCO2e/kg
+
+	DefaultFirewood  = 1.8 // kgCO2e/kg
+	DefaultChemicals = 1.0 // kgCO2e/kg
+)
+
+type factorKey struct {
+	category string
+	source   string
+}
+
+var defaultFactors = map[factorKey]float64{
+	{models.FactorCategoryElectricity, models.FactorSourceGrid}:             DefaultGridElectricity,
+	{models.FactorCategoryFuel, models.FactorSourceDiesel}:                  DefaultDiesel,
+	{models.FactorCategoryFuel, models.FactorSourcePetrol}:                  DefaultPetrol,
+	{models.FactorCategoryFuel, models.FactorSourceBiofuel}:                 DefaultBiofuel,
+	{models.FactorCategoryFuel, models.FactorSourceLPG}:                     DefaultLPG,
+	{models.FactorCategoryFuel, models.FactorSourceFirewood}:                DefaultFirewood,
+	{models.FactorCategoryWater, models.FactorSourceWaterTreatmentDist}:     DefaultWaterTreatmentDist,
+	{models.FactorCategoryWater, models.FactorSourceFoodWater}:              DefaultFoodWater,
+	{models.FactorCategoryWaste, models.FactorSourceWasteBiodegradable}:     DefaultWasteBiodegradable,
+	{models.FactorCategoryWaste, models.FactorSourceWasteRecyclable}:        DefaultWasteRecyclable,
+	{models.FactorCategoryWaste, models.FactorSourceWasteLandfill}:          DefaultWasteLandfill,
+	{models.FactorCategoryWaste, models.FactorSourceWasteEwaste}:            DefaultWasteEwaste,
+	{models.FactorCategoryGoods, models.FactorSourceGoodsSpend}:             DefaultGoodsCost,
+	{models.FactorCategoryChemicals, models.FactorSourceTreatmentChemicals}: DefaultChemicals,
+}
+
+// Factor is the emission factor applied to one activity.
+type Factor struct {
+	ID       int     `json:"id,omitempty"` // 0 when the built-in default was used
+	Category string  `json:"category"`
+	Source   string  `json:"source"`
+	Value    float64 `json:"value"`
+}
+
+// FactorSet resolves the factor to apply to an activity on a given date.
+type FactorSet interface {
+	Factor(category, source string, at time.Time) Factor
+}
+
+type librarySet struct {
+	library models.EmissionFactors
+}
+
+// NewFactorSet returns a FactorSet backed by the given factor library, falling
+// back to the built-in defaults where the library has no factor for a date.
+func NewFactorSet(library models.EmissionFactors) FactorSet {
+	return librarySet{library: library}
+}
+
+// DefaultFactorSet returns a FactorSet that only uses the built-in defaults.
+func DefaultFactorSet() FactorSet {
+	return librarySet{}
+}
+
+func (s librarySet) Factor(category, source string, at time.Time) Factor {
+	if f, ok := s.library.Lookup(category, source, at); ok {
+		return Factor{ID: f.ID, Category: category, Source: source, Value: f.Value}
+	}
+	return Factor{Category: category, Source: source, Value: defaultFactors[factorKey{category, source}]}
+}
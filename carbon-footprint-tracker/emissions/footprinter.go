@@ -0,0 +1,89 @@
+package emissions
+
+import (
+	"carbon-footprint-tracker/models"
+	"database/sql"
+	"fmt"
+	"math"
+)
+
+// Footprinter computes the footprint stored on activity records at write time.
+type Footprinter struct {
+	calc Calculator
+}
+
+// NewFootprinter returns a Footprinter that takes its factors from factors.
+func NewFootprinter(factors FactorSet) *Footprinter {
+	return &Footprinter{calc: NewCalculator(factors)}
+}
+
+// CurrentFootprinter returns a Footprinter backed by the factor library as it
+// is in the database now. It is registered with models.SetFootprinterSource.
+func CurrentFootprinter() (models.Footprinter, error) {
+	factors, err := models.GetAllEmissionFactors()
+	if err != nil {
+		return nil, err
+	}
+	return NewFootprinter(NewFactorSet(factors)), nil
+}
+
+// Footprint returns the kgCO2e of record, split by scope, and the id of the
+// library factor behind its largest contribution (none when only defaults
+// were used).
+func (fp *Footprinter) Footprint(record interface{}) (models.Footprint, error) {
+	var r Result
+	switch rec := record.(type) {
+	case *models.ElectricConsumption:
+		r = fp.calc.Electric(*rec)
+	case *models.Transport:
+		r = fp.calc.Transport(*rec)
+	case *models.WaterConsumption:
+		r = fp.calc.WaterConsumption(*rec)
+	case *models.WaterTreatment:
+		r = fp.calc.WaterTreatment(*rec)
+	case *models.Waste:
+		r = fp.calc.Waste(*rec)
+	case *models.Accommodation:
+		r = fp.calc.Accommodation(*rec)
+	case *models.GoodsPurchased:
+		r = fp.calc.Goods(*rec)
+	case *models.FoodConsumption:
+		r = fp.calc.Food(*rec)
+	default:
+		return models.Footprint{}, fmt.Errorf("emissions: unsupported record type %T", record)
+	}
+
+	scopes := make(map[int]float64)
+	for _, l := range r.Lines {
+		scopes[l.Scope] += l.CO2eKg
+	}
+	factorID := r.primaryFactorID()
+	return models.Footprint{
+		CO2eKg:           sql.NullFloat64{Float64: r.CO2eKg, Valid: true},
+		EmissionFactorID: sql.NullInt64{Int64: int64(factorID), Valid: factorID != 0},
+		Scope1CO2eKg:     sql.NullFloat64{Float64: scopes[Scope1], Valid: true},
+		Scope2CO2eKg:     sql.NullFloat64{Float64: scopes[Scope2], Valid: true},
+		Scope3CO2eKg:     sql.NullFloat64{Float64: scopes[Scope3], Valid: true},
+	}, nil
+}
+
+// Avoided returns the kgCO2e record avoided, which only electric consumption
+// can through solar generation. It implements models.Offsetter.
+func (fp *Footprinter) Avoided(record interface{}) (float64, error) {
+	if e, ok := record.(*models.ElectricConsumption); ok {
+		return fp.calc.Electric(*e).AvoidedCO2eKg, nil
+	}
+	return 0, nil
+}
+
+// primaryFactorID returns the factor id of the line contributing the most
+// emissions, in absolute terms.
+func (r Result) primaryFactorID() int {
+	id, largest := 0, -1.0
+	for _, l := range r.Lines {
+		if math.Abs(l.CO2eKg) > largest {
+			id, largest = l.Factor.ID, math.Abs(l.CO2eKg)
+		}
+	}
+	return id
+}
@@ -0,0 +1,86 @@
+package emissions
+
+import (
+	"carbon-footprint-tracker/models"
+	"database/sql"
+	"testing"
+)
+
+func TestFootprinterScopes(t *testing.T) {
+	library := models.EmissionFactors{
+		{ID: 7, Category: models.FactorCategoryElectricity, Source: models.FactorSourceGrid, Value: 0.7, ValidFrom: day(2024, 1, 1)},
+	}
+
+	tests := []struct {
+		name         string
+		record       interface{}
+		scope1       float64
+		scope2       float64
+		scope3       float64
+		wantFactorID int64
+	}{
+		{
+			name:         "grid electricity",
+			record:       &models.ElectricConsumption{Date: recordDate, Source: "Main Board", GridElectricityUsedKWH: validFloat(100), SolarGeneratedKWH: validFloat(50)},
+			scope2:       70,
+			wantFactorID: 7,
+		},
+		{
+			name:   "generator",
+			record: &models.ElectricConsumption{Date: recordDate, Source: "Diesel Generator", FuelConsumedLiters: validFloat(10)},
+			scope1: 10 * DefaultDiesel,
+		},
+		{
+			name:         "water treatment",
+			record:       &models.WaterTreatment{Date: recordDate, ElectricityUsedKWH: validFloat(10), ChemicalsUsedQuantityKG: validFloat(2)},
+			scope2:       7,
+			scope3:       2 * DefaultChemicals,
+			wantFactorID: 7,
+		},
+		{
+			name: "food",
+			record: &models.FoodConsumption{
+				Date: recordDate, WaterUsedLWashingCooking: validFloat(1000),
+				FuelUsedType: validString("LPG"), FuelUsedQuantity: validFloat(5),
+			},
+			scope1: 5 * DefaultLPG,
+			scope3: 1000 * DefaultFoodWater,
+		},
+		{
+			name:   "waste",
+			record: &models.Waste{Date: recordDate, WasteType: "Landfill", WeightKG: 10},
+			scope3: 10 * DefaultWasteLandfill,
+		},
+	}
+
+	fp := NewFootprinter(NewFactorSet(library))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := fp.Footprint(tt.record)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, s := range []struct {
+				name string
+				got  sql.NullFloat64
+				want float64
+			}{
+				{"scope 1", got.Scope1CO2eKg, tt.scope1},
+				{"scope 2", got.Scope2CO2eKg, tt.scope2},
+				{"scope 3", got.Scope3CO2eKg, tt.scope3},
+				{"total", got.CO2eKg, tt.scope1 + tt.scope2 + tt.scope3},
+			} {
+				if !s.got.Valid || !approxEqual(s.got.Float64, s.want) {
+					t.Errorf("%s = %+v, want %v", s.name, s.got, s.want)
+				}
+			}
+			if got.EmissionFactorID.Int64 != tt.wantFactorID || got.EmissionFactorID.Valid != (tt.wantFactorID != 0) {
+				t.Errorf("emission factor = %+v, want %d", got.EmissionFactorID, tt.wantFactorID)
+			}
+		})
+	}
+
+	if _, err := fp.Footprint(&models.Population{}); err == nil {
+		t.Error("expected an error for a record without a footprint")
+	}
+}
@@ -0,0 +1,117 @@
+package emissions
+
+import (
+	"carbon-footprint-tracker/models"
+	"fmt"
+	"sort"
+)
+
+// GHG Protocol scopes.
+const (
+	Scope1 = 1 // Direct emissions from owned or controlled sources
+	Scope2 = 2 // Indirect emissions from purchased electricity
+	Scope3 = 3 // All other indirect emissions in the value chain
+)
+
+// GHG Protocol Scope 3 categories used by this tracker.
+const (
+	Scope3PurchasedGoods = 1 // Purchased goods and services
+	Scope3Waste          = 5 // Waste generated in operations
+	Scope3BusinessTravel = 6 // Business travel (including hotel stays)
+)
+
+var scope3CategoryNames = map[int]string{
+	Scope3PurchasedGoods: "Purchased goods and services",
+	Scope3Waste:          "Waste generated in operations",
+	Scope3BusinessTravel: "Business travel",
+}
+
+// Classification places an emission line in the GHG Protocol inventory.
+type Classification struct {
+	Scope          int `json:"scope"`
+	Scope3Category int `json:"scope3_category,omitempty"`
+}
+
+var (
+	scope1Direct         = Classification{Scope: Scope1}
+	scope2Electricity    = Classification{Scope: Scope2}
+	scope3PurchasedGoods = Classification{Scope: Scope3, Scope3Category: Scope3PurchasedGoods}
+	scope3Waste          = Classification{Scope: Scope3, Scope3Category: Scope3Waste}
+	scope3BusinessTravel = Classification{Scope: Scope3, Scope3Category: Scope3BusinessTravel}
+)
+
+// ScopeName returns the display name of a scope, e.g. "Scope 1".
+func ScopeName(scope int) string {
+	return fmt.Sprintf("Scope %d", scope)
+}
+
+// Scope3CategoryName returns the GHG Protocol name of a Scope 3 category.
+func Scope3CategoryName(category int) string {
+	if name, ok := scope3CategoryNames[category]; ok {
+		return name
+	}
+	return fmt.Sprintf("Category %d", category)
+}
+
+type Scope3CategoryTotal struct {
+	Category int     `json:"category"`
+	Name     string  `json:"name"`
+	CO2eKg   float64 `json:"co2e_kg"`
+}
+
+// ScopeReport totals emissions per scope and per Scope 3 category.
+type ScopeReport struct {
+	Total            float64               `json:"total_co2e_kg"`
+	Scopes           map[string]float64    `json:"scopes"`
+	Scope3Categories []Scope3CategoryTotal `json:"scope3_categories"`
+}
+
+// tableScope3Categories gives the Scope 3 category of the Scope 3 emissions
+// of each activity table; every Scope 3 line the calculator produces for a
+// table's records falls in the same category.
+var tableScope3Categories = map[string]int{
+	models.TableElectricConsumption: Scope3PurchasedGoods,
+	models.TableTransport:           Scope3PurchasedGoods,
+	models.TableWaterConsumption:    Scope3PurchasedGoods,
+	models.TableWaterTreatment:      Scope3PurchasedGoods,
+	models.TableWaste:               Scope3Waste,
+	models.TableAccommodation:       Scope3BusinessTravel,
+	models.TableGoodsPurchased:      Scope3PurchasedGoods,
+	models.TableFoodConsumption:     Scope3PurchasedGoods,
+}
+
+// ScopeTotals builds the scope report from the stored per-scope footprints of
+// each activity table, see models.SumScopeFootprints.
+func ScopeTotals(totals map[string]models.ScopeFootprint) ScopeReport {
+	report := ScopeReport{
+		Scopes: map[string]float64{
+			ScopeName(Scope1): 0,
+			ScopeName(Scope2): 0,
+			ScopeName(Scope3): 0,
+		},
+	}
+
+	categories := make(map[int]float64)
+	for table, t := range totals {
+		report.Scopes[ScopeName(Scope1)] += t.Scope1CO2eKg
+		report.Scopes[ScopeName(Scope2)] += t.Scope2CO2eKg
+		report.Scopes[ScopeName(Scope3)] += t.Scope3CO2eKg
+		report.Total += t.Scope1CO2eKg + t.Scope2CO2eKg + t.Scope3CO2eKg
+		if t.Scope3CO2eKg != 0 {
+			categories[tableScope3Categories[table]] += t.Scope3CO2eKg
+		}
+	}
+
+	report.Scope3Categories = make([]Scope3CategoryTotal, 0, len(categories))
+	for category, co2e := range categories {
+		report.Scope3Categories = append(report.Scope3Categories, Scope3CategoryTotal{
+			Category: category,
+			Name:     Scope3CategoryName(category),
+			CO2eKg:   co2e,
+		})
+	}
+	sort.Slice(report.Scope3Categories, func(i, j int) bool {
+		return report.Scope3Categories[i].Category < report.Scope3Categories[j].Category
+	})
+	return report
+}
@@ -0,0 +1,117 @@
+package emissions
+
+import (
+	"carbon-footprint-tracker/models"
+	"testing"
+)
+
+// The scope report attributes each table's stored Scope 3 emissions to one
+// category, so every Scope 3 line of the table's records must fall in it.
+func TestTableScope3Categories(t *testing.T) {
+	c := NewCalculator(DefaultFactorSet())
+	tests := []struct {
+		table  string
+		result Result
+	}{
+		{models.TableElectricConsumption, c.Electric(models.ElectricConsumption{
+			Date: recordDate, Source: "Main Board", GridElectricityUsedKWH: validFloat(10), FuelConsumedLiters: validFloat(1), SolarGeneratedKWH: validFloat(5),
+		})},
+		{models.TableTransport, c.Transport(models.Transport{Date: recordDate, FuelType: "Diesel", FuelLiters: 10})},
+		{models.TableWaterConsumption, c.WaterConsumption(models.WaterConsumption{Date: recordDate, TotalConsumptionKLD: 1})},
+		{models.TableWaterTreatment, c.WaterTreatment(models.WaterTreatment{
+			Date: recordDate, ElectricityUsedKWH: validFloat(10), ChemicalsUsedQuantityKG: validFloat(1),
+		})},
+		{models.TableWaste, c.Waste(models.Waste{Date: recordDate, WasteType: "Non-Biodegradable", SubCategory: validString("E-waste"), WeightKG: 1})},
+		{models.TableAccommodation, c.Accommodation(models.Accommodation{
+			Date: recordDate, PeopleCount: 1, Nights: 1, ElectricityConsumptionKWH: validFloat(10), WaterConsumptionLPD: validFloat(100),
+		})},
+		{models.TableGoodsPurchased, c.Goods(models.GoodsPurchased{Date: recordDate, BillAmountINR: 100})},
+		{models.TableFoodConsumption, c.Food(models.FoodConsumption{
+			Date: recordDate, WaterUsedLWashingCooking: validFloat(10), FuelUsedType: validString("LPG"), FuelUsedQuantity: validFloat(1),
+		})},
+	}
+
+	if len(tests) != len(models.ActivityTables) {
+		t.Fatalf("testing %d tables, want all %d", len(tests), len(models.ActivityTables))
+	}
+	for _, tt := range tests {
+		t.Run(tt.table, func(t *testing.T) {
+			category, ok := tableScope3Categories[tt.table]
+			if !ok {
+				t.Fatalf("no Scope 3 category for %s", tt.table)
+			}
+			for _, l := range tt.result.Lines {
+				if l.Scope == Scope3 && l.Scope3Category != category {
+					t.Errorf("line %q in category %d, want %d", l.Activity, l.Scope3Category, category)
+				}
+			}
+		})
+	}
+}
+
+func TestScopeTotals(t *testing.T) {
+	tests := []struct {
+		name       string
+		totals     map[string]models.ScopeFootprint
+		total      float64
+		scopes     [3]float64
+		categories []Scope3CategoryTotal
+	}{
+		{
+			name:       "no records",
+			totals:     map[string]models.ScopeFootprint{},
+			categories: []Scope3CategoryTotal{},
+		},
+		{
+			name: "every scope",
+			totals: map[string]models.ScopeFootprint{
+				models.TableElectricConsumption: {Scope1CO2eKg: 50, Scope2CO2eKg: 450},
+				models.TableWaterTreatment:      {Scope2CO2eKg: 20, Scope3CO2eKg: 5},
+				models.TableWaste:               {Scope3CO2eKg: 30},
+				models.TableAccommodation:       {Scope3CO2eKg: 12},
+				models.TableGoodsPurchased:      {Scope3CO2eKg: 100},
+				models.TableTransport:           {},
+			},
+			total:  667,
+			scopes: [3]float64{50, 470, 147},
+			categories: []Scope3CategoryTotal{
+				{Category: Scope3PurchasedGoods, Name: "Purchased goods and services", CO2eKg: 105},
+				{Category: Scope3Waste, Name: "Waste generated in operations", CO2eKg: 30},
+				{Category: Scope3BusinessTravel, Name: "Business travel", CO2eKg: 12},
+			},
+		},
+		{
+			name: "negative waste credit",
+			totals: map[string]models.ScopeFootprint{
+				models.TableWaste: {Scope3CO2eKg: -4},
+			},
+			total:      -4,
+			scopes:     [3]float64{0, 0, -4},
+			categories: []Scope3CategoryTotal{{Category: Scope3Waste, Name: "Waste generated in operations", CO2eKg: -4}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := ScopeTotals(tt.totals)
+			if !approxEqual(r.Total, tt.total) {
+				t.Errorf("total = %v, want %v", r.Total, tt.total)
+			}
+			for i, want := range tt.scopes {
+				name := ScopeName(i + 1)
+				if got, ok := r.Scopes[name]; !ok || !approxEqual(got, want) {
+					t.Errorf("%s = %v, want %v", name, got, want)
+				}
+			}
+			if len(r.Scope3Categories) != len(tt.categories) {
+				t.Fatalf("categories = %+v, want %+v", r.Scope3Categories, tt.categories)
+			}
+			for i, want := range tt.categories {
+				got := r.Scope3Categories[i]
+				if got.Category != want.Category || got.Name != want.Name || !approxEqual(got.CO2eKg, want.CO2eKg) {
+					t.Errorf("category %d = %+v, want %+v", i, got, want)
+				}
+			}
+		})
+	}
+}
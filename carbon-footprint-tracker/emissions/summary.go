@@ -0,0 +1,70 @@
+package emissions
+
+import "carbon-footprint-tracker/models"
+
+// Activities bundles the records of every emitting module for a reporting period.
+type Activities struct {
+	Electric          []models.ElectricConsumption
+	Transports        []models.Transport
+	WaterConsumptions []models.WaterConsumption
+	WaterTreatments   []models.WaterTreatment
+	Waste             []models.Waste
+	Accommodations    []models.Accommodation
+	Goods             []models.GoodsPurchased
+	Food              []models.FoodConsumption
+}
+
+// Summary holds per-record results together with per-component totals.
+type Summary struct {
+	Total      float64            `json:"total_co2e_kg"`
+	Avoided    float64            `json:"avoided_co2e_kg"`
+	Components map[string]float64 `json:"components"`
+	Results    []Result           `json:"results"`
+}
+
+// NewSummary returns an empty Summary with every component present.
+func NewSummary() *Summary {
+	s := &Summary{Components: make(map[string]float64)}
+	for _, component := range Components {
+		s.Components[component] = 0
+	}
+	return s
+}
+
+// Add records a result in the summary.
+func (s *Summary) Add(r Result) {
+	s.Results = append(s.Results, r)
+	s.Components[r.Component] += r.CO2eKg
+	s.Total += r.CO2eKg
+	s.Avoided += r.AvoidedCO2eKg
+}
+
+// Calculate runs calc over every record in a.
+func Calculate(calc Calculator, a Activities) *Summary {
+	s := NewSummary()
+	for _, e := range a.Electric {
+		s.Add(calc.Electric(e))
+	}
+	for _, t := range a.Transports {
+		s.Add(calc.Transport(t))
+	}
+	for _, w := range a.WaterConsumptions {
+		s.Add(calc.WaterConsumption(w))
+	}
+	for _, wt := range a.WaterTreatments {
+		s.Add(calc.WaterTreatment(wt))
+	}
+	for _, w := range a.Waste {
+		s.Add(calc.Waste(w))
+	}
+	for _, acc := range a.Accommodations {
+		s.Add(calc.Accommodation(acc))
+	}
+	for _, g := range a.Goods {
+		s.Add(calc.Goods(g))
+	}
+	for _, f := range a.Food {
+		s.Add(calc.Food(f))
+	}
+	return s
+}
@@ -0,0 +1,125 @@
+// Package export writes tabular data as CSV or XLSX, one row at a time, so
+// that large exports can be streamed to the client.
+package export
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Supported formats.
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+)
+
+const dateLayout = "2006-01-02"
+
+// Writer writes rows into one or more sheets.
+type Writer interface {
+	// Sheet starts a new sheet with a header row. CSV supports only one sheet.
+	Sheet(name string, header []string) error
+	// Row appends a row to the current sheet. Values may be nil, strings,
+	// numbers, bools or times.
+	Row(values []interface{}) error
+	// Close finishes the output. It does not close the underlying writer.
+	Close() error
+}
+
+// NewWriter returns a Writer for format, or an error if it is not supported.
+func NewWriter(format string, w io.Writer) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return &csvWriter{w: csv.NewWriter(w)}, nil
+	case FormatXLSX:
+		return newXLSXWriter(w), nil
+	default:
+		return nil, fmt.Errorf("unsupported export format %q", format)
+	}
+}
+
+// ContentType returns the MIME type of format.
+func ContentType(format string) string {
+	if format == FormatXLSX {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+type csvWriter struct {
+	w      *csv.Writer
+	sheets int
+	rows   int
+}
+
+func (c *csvWriter) Sheet(name string, header []string) error {
+	if c.sheets++; c.sheets > 1 {
+		return errors.New("csv output holds a single sheet")
+	}
+	return c.w.Write(header)
+}
+
+func (c *csvWriter) Row(values []interface{}) error {
+	record := make([]string, len(values))
+	for i, v := range values {
+		record[i] = formatValue(v)
+		if _, ok := v.(string); ok {
+			record[i] = escapeFormula(record[i])
+		}
+	}
+	if err := c.w.Write(record); err != nil {
+		return err
+	}
+	// Flush regularly so rows reach the client instead of piling up.
+	if c.rows++; c.rows%500 == 0 {
+		c.w.Flush()
+		return c.w.Error()
+	}
+	return nil
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// escapeFormula prefixes s with a quote if it starts like a formula, so that
+// spreadsheet applications opening the CSV file show user-entered text such
+// as remarks instead of evaluating it. Numbers are written unescaped.
+func escapeFormula(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// formatValue renders v as cell text.
+func formatValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return ""
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case int:
+		return strconv.Itoa(v)
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		return v.Format(dateLayout)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,47 @@
+package export
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestCSVRowEscapesFormulas(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		want  string // The CSV line, as encoded
+	}{
+		{"formula", "=HYPERLINK(\"http://example.com\")", `"'=HYPERLINK(""http://example.com"")"`},
+		{"plus", "+1+2", "'+1+2"},
+		{"minus", "-2+3", "'-2+3"},
+		{"at", "@SUM(A1)", "'@SUM(A1)"},
+		{"tab", "\t=1", "'\t=1"},
+		{"plain text", "Main Board", "Main Board"},
+		{"formula character inside", "a=b", "a=b"},
+		{"empty", "", ""},
+		{"negative number", -0.1, "-0.1"},
+		{"integer", 42, "42"},
+		{"date", time.Date(2024, 6, 15, 0, 0, 0, 0, time.UTC), "2024-06-15"},
+		{"null", nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w, err := NewWriter(FormatCSV, &buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Row([]interface{}{tt.value}); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != tt.want+"\n" {
+				t.Errorf("row = %q, want %q", got, tt.want+"\n")
+			}
+		})
+	}
+}
@@ -35,6 +35,22 @@ func AddAccommodationData(c *gin.Context) {
 		return
 	}
 
+	a := newAccommodation(req)
+
+	if !requireLocation(c, a.AccommodationFacilityName) {
+		return
+	}
+
+	if err := a.Create(requestActor(c)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add accommodation data", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Accommodation data added successfully", "id": a.ID})
+}
+
+// newAccommodation builds a accommodation entry from req, filling in the defaults for omitted fields.
+func newAccommodation(req AccommodationRequest) models.Accommodation {
 	if req.AccommodationFacilityName == "" {
 		req.AccommodationFacilityName = "Overall"
 	}
@@ -42,7 +58,7 @@ func AddAccommodationData(c *gin.Context) {
 		req.Date = time.Now()
 	}
 
-	a := models.Accommodation{
+	return models.Accommodation{
 		Date:                      req.Date,
 		ParticipantGuestName:      toNullString(req.ParticipantGuestName),
 		Category:                  toNullString(req.Category),
@@ -58,23 +74,42 @@ func AddAccommodationData(c *gin.Context) {
 		TransportModeToVenue:      toNullString(req.TransportModeToVenue),
 		Remarks:                   toNullString(req.Remarks),
 	}
+}
 
-	if err := a.Create(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add accommodation data", "details": err.Error()})
+// ImportAccommodationData creates accommodation data from a CSV upload, see importCSV.
+func ImportAccommodationData(c *gin.Context) {
+	footprinter, ok := importFootprinter(c)
+	if !ok {
 		return
 	}
+	importCSV(c, "accommodation data", func(db models.Querier, req AccommodationRequest) error {
+		a := newAccommodation(req)
+		if !inLocationScope(c, a.AccommodationFacilityName) {
+			return errOutsideLocations(a.AccommodationFacilityName)
+		}
+		return a.CreateWith(db, requestActor(c), footprinter)
+	})
+}
 
-	c.JSON(http.StatusCreated, gin.H{"message": "Accommodation data added successfully", "id": a.ID})
+// ExportAccommodationData streams accommodation data as CSV or XLSX, see exportModule.
+func ExportAccommodationData(c *gin.Context) {
+	exportModule(c, models.TableAccommodation)
 }
 
-// GetAccommodationData retrieves all accommodation data
+// GetAccommodationData retrieves a page of accommodation data
 func GetAccommodationData(c *gin.Context) {
-	accommodations, err := models.GetAllAccommodations()
+	opts, err := parseListOptions(c, models.TableAccommodation)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	accommodations, total, err := models.ListAccommodations(opts)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve accommodation data", "details": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, accommodations)
+	c.JSON(http.StatusOK, ListResponse{Data: accommodations, Page: opts.Page, PageSize: opts.PageSize, Total: total})
 }
 
 // UpdateAccommodationData updates existing accommodation data
@@ -86,7 +121,7 @@ func UpdateAccommodationData(c *gin.Context) {
 		return
 	}
 
-	a, err := models.GetAccommodationByID(id)
+	a, err := models.GetAccommodationByID(requestOrgID(c), id)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Accommodation data not found"})
@@ -96,6 +131,8 @@ func UpdateAccommodationData(c *gin.Context) {
 		return
 	}
 
+	location := a.AccommodationFacilityName
+
 	var req AccommodationRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -123,7 +160,15 @@ func UpdateAccommodationData(c *gin.Context) {
 	a.TransportModeToVenue = toNullString(req.TransportModeToVenue)
 	a.Remarks = toNullString(req.Remarks)
 
-	if err := a.Update(); err != nil {
+	if !requireLocationChange(c, location, a.AccommodationFacilityName) {
+		return
+	}
+
+	if err := a.Update(requestActor(c)); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Accommodation data not found"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update accommodation data", "details": err.Error()})
 		return
 	}
@@ -140,10 +185,23 @@ func DeleteAccommodationData(c *gin.Context) {
 		return
 	}
 
-	if err := models.DeleteAccommodation(id); err != nil {
+	if !requireRecordLocation(c, models.TableAccommodation, id) {
+		return
+	}
+
+	if err := models.DeleteAccommodation(requestActor(c), id); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Accommodation data not found"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete accommodation data", "details": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Accommodation data deleted successfully"})
 }
+
+// RestoreAccommodationData undeletes accommodation data, see restoreRecord.
+func RestoreAccommodationData(c *gin.Context) {
+	restoreRecord(c, models.TableAccommodation, "accommodation data")
+}
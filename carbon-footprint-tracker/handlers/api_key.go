@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"carbon-footprint-tracker/models"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultAPIKeyDays = 365
+	maxAPIKeyDays     = 5 * 365
+)
+
+type APIKeyRequest struct {
+	Name          string   `json:"name" binding:"required,max=100"`
+	Permissions   []string `json:"permissions" binding:"required,min=1"` // Module permissions, e.g. electric:write
+	Locations     []string `json:"locations" binding:"dive,max=255"`     // Empty for every location
+	ExpiresInDays int      `json:"expires_in_days"`                      // Defaults to 365
+}
+
+// CreateAPIKey issues an API key for the request's organisation. It can only
+// hold module permissions and locations that the user holds themselves. The
+// key is returned only in this response and is passed like an access token,
+// in the Authorization header as "Bearer <key>".
+func CreateAPIKey(c *gin.Context) {
+	var req APIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "API key name must not be empty"})
+		return
+	}
+
+	for _, p := range req.Permissions {
+		if !models.IsModulePermission(p) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Permission '%s' can't be granted to API keys, expected a module read or write permission", p)})
+			return
+		}
+		if !hasPermission(c, p) {
+			c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("You can't grant permission '%s', which you don't hold", p)})
+			return
+		}
+	}
+
+	locations := make([]string, 0, len(req.Locations))
+	for _, location := range req.Locations {
+		if location = strings.TrimSpace(location); location == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Locations must not be empty"})
+			return
+		}
+		if !requireLocation(c, location) {
+			return
+		}
+		locations = append(locations, location)
+	}
+	if len(locations) == 0 && locationScope(c) != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You are limited to assigned locations, so the API key must be too"})
+		return
+	}
+
+	if req.ExpiresInDays == 0 {
+		req.ExpiresInDays = defaultAPIKeyDays
+	}
+	if req.ExpiresInDays < 1 || req.ExpiresInDays > maxAPIKeyDays {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("expires_in_days must be between 1 and %d", maxAPIKeyDays)})
+		return
+	}
+
+	apiKey := models.APIKey{
+		OrgID:       requestOrgID(c),
+		Name:        name,
+		Permissions: req.Permissions,
+		Locations:   locations,
+		CreatedBy:   sql.NullInt64{Int64: int64(c.GetInt("userID")), Valid: true},
+		ExpiresAt:   time.Now().AddDate(0, 0, req.ExpiresInDays),
+	}
+	key, err := apiKey.Create()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create API key", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":    "API key created successfully",
+		"id":         apiKey.ID,
+		"key":        key,
+		"prefix":     apiKey.Prefix,
+		"expires_at": apiKey.ExpiresAt,
+	})
+}
+
+func GetAPIKeys(c *gin.Context) {
+	keys, err := models.GetAllAPIKeys(requestOrgID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve API keys", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, keys)
+}
+
+// RevokeAPIKey stops an API key from being accepted. Requests already
+// authenticated with it complete.
+func RevokeAPIKey(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	if err := models.RevokeAPIKey(requestOrgID(c), id); err != nil {
+		if errors.Is(err, models.ErrAPIKeyNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "API key not found or already revoked"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke API key", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "API key revoked successfully"})
+}
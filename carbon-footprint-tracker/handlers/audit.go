@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"carbon-footprint-tracker/models"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetAuditLog lists the changes made to module records of the organisation,
+// newest first. Besides page, page_size, from and to (by date of the change)
+// it can be filtered on:
+//
+//	user_id    who made the change
+//	api_key_id the API key the change was made with
+//	module     the table changed, e.g. electric_consumption
+//	record_id  the record changed, together with module
+//	action     create, update, delete, restore or purge
+func GetAuditLog(c *gin.Context) {
+	f := models.AuditFilter{OrgID: requestOrgID(c)}
+
+	var err error
+	if f.Page, f.PageSize, err = parsePage(c); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if f.From, f.To, err = parseDateRange(c); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	for param, dest := range map[string]*int{"user_id": &f.UserID, "api_key_id": &f.APIKeyID, "record_id": &f.RecordID} {
+		if v := c.Query(param); v != "" {
+			if *dest, err = strconv.Atoi(v); err != nil || *dest < 1 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid '%s', expected a positive integer", param)})
+				return
+			}
+		}
+	}
+	if f.Table = c.Query("module"); f.Table != "" {
+		if _, ok := models.ListSpecs[f.Table]; !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Unknown module '%s'", f.Table)})
+			return
+		}
+	}
+	switch f.Action = c.Query("action"); f.Action {
+	case "", models.AuditCreate, models.AuditUpdate, models.AuditDelete, models.AuditRestore, models.AuditPurge:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'action', expected create, update, delete, restore or purge"})
+		return
+	}
+
+	entries, total, err := models.ListAuditEntries(f)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve audit log", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, ListResponse{Data: entries, Page: f.Page, PageSize: f.PageSize, Total: total})
+}
@@ -1,19 +1,25 @@
 package handlers
 
 import (
+	"carbon-footprint-tracker/config"
 	"carbon-footprint-tracker/models"
 	"carbon-footprint-tracker/utils"
 	"database/sql"
+	"errors"
+	"io"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
 type RegisterRequest struct {
-	Name     string `json:"name" binding:"required"`
-	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required,min=6"`
-	Role     string `json:"role" binding:"required"` // 'admin', 'staff', 'viewer'
+	Name        string `json:"name" binding:"required"`
+	Email       string `json:"email" binding:"required,email"`
+	Password    string `json:"password" binding:"required"`
+	Role        string `json:"role"`         // Only 'viewer' without an invitation
+	InviteToken string `json:"invite_token"` // Required to register with a role other than viewer
 }
 
 type LoginRequest struct {
@@ -21,7 +27,10 @@ type LoginRequest struct {
 	Password string `json:"password" binding:"required"`
 }
 
-// RegisterUser handles user registration
+// RegisterUser handles user registration. Anonymous callers can only create
+// viewer accounts in the default organisation, and only while public
+// registration is enabled; any other role or organisation has to come from
+// an admin's invitation.
 func RegisterUser(c *gin.Context) {
 	var req RegisterRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -29,9 +38,22 @@ func RegisterUser(c *gin.Context) {
 		return
 	}
 
-	// Basic role validation
-	if req.Role != "admin" && req.Role != "staff" && req.Role != "viewer" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role specified"})
+	if err := utils.ValidatePasswordStrength(req.Password, req.Name, req.Email); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.InviteToken != "" {
+		registerInvitedUser(c, req)
+		return
+	}
+
+	if !config.PublicRegistration {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Public registration is disabled, ask an administrator for an invitation"})
+		return
+	}
+	if req.Role != "" && req.Role != models.RoleViewer {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only viewer accounts can be self-registered, other roles require an invitation"})
 		return
 	}
 
@@ -39,7 +61,8 @@ func RegisterUser(c *gin.Context) {
 		Name:         req.Name,
 		Email:        req.Email,
 		PasswordHash: req.Password, // This will be hashed in the Create method
-		Role:         req.Role,
+		Role:         models.RoleViewer,
+		OrgID:        config.DefaultOrganisationID,
 	}
 
 	if err := user.Create(); err != nil {
@@ -47,10 +70,64 @@ func RegisterUser(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{"message": "User registered successfully", "user_id": user.ID})
+	c.JSON(http.StatusCreated, gin.H{"message": "User registered successfully", "user_id": user.ID, "role": user.Role})
+}
+
+// registerInvitedUser creates the account for an invitation and uses up the
+// invitation in the same transaction. The role and organisation come from
+// the invitation.
+func registerInvitedUser(c *gin.Context, req RegisterRequest) {
+	inv, err := models.GetPendingInvitationByToken(req.InviteToken)
+	if err != nil {
+		if errors.Is(err, models.ErrInvitationUnavailable) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invitation is invalid, expired or already used"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check invitation", "details": err.Error()})
+		return
+	}
+	if !strings.EqualFold(inv.Email, req.Email) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Invitation was issued for a different email address"})
+		return
+	}
+
+	user := models.User{
+		Name:         req.Name,
+		Email:        req.Email,
+		PasswordHash: req.Password,
+		Role:         inv.Role,
+		OrgID:        inv.OrgID,
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register user", "details": err.Error()})
+		return
+	}
+	defer tx.Rollback() // No-op once committed
+
+	if err := user.CreateWith(tx); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register user", "details": err.Error()})
+		return
+	}
+	if err := inv.Accept(tx, user.ID); err != nil {
+		if errors.Is(err, models.ErrInvitationUnavailable) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invitation is invalid, expired or already used"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register user", "details": err.Error()})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register user", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "User registered successfully", "user_id": user.ID, "role": user.Role})
 }
 
-// LoginUser handles user login and JWT token generation
+// LoginUser handles user login and JWT token generation. Failed attempts are
+// throttled per client IP and per account, see models.RecordAccountLoginFailure.
 func LoginUser(c *gin.Context) {
 	var req LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -58,9 +135,23 @@ func LoginUser(c *gin.Context) {
 		return
 	}
 
+	blockedUntil, err := models.GetIPBlockedUntil(c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to login", "details": err.Error()})
+		return
+	}
+	if blockedUntil.Valid && time.Now().Before(blockedUntil.Time) {
+		respondTooManyAttempts(c, "Too many failed login attempts, try again later", blockedUntil.Time)
+		return
+	}
+
 	user, err := models.GetUserByEmail(req.Email)
 	if err != nil {
 		if err == sql.ErrNoRows {
+			if err := recordLoginFailure(c, nil); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to login", "details": err.Error()})
+				return
+			}
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 			return
 		}
@@ -68,16 +159,152 @@ func LoginUser(c *gin.Context) {
 		return
 	}
 
+	// The password isn't checked while locked, so guesses can't go on.
+	if user.Locked() {
+		respondTooManyAttempts(c, "Account is temporarily locked after failed login attempts, try again later", user.LockedUntil.Time)
+		return
+	}
+
 	if !utils.CheckPasswordHash(req.Password, user.PasswordHash) {
+		if err := recordLoginFailure(c, user); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to login", "details": err.Error()})
+			return
+		}
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
 
-	token, err := utils.GenerateToken(user.ID, user.Role)
+	// With two-factor authentication the failures are only cleared once the
+	// code is accepted, see VerifyMFALogin.
+	if user.MFAEnabled {
+		respondWithMFAChallenge(c, user)
+		return
+	}
+
+	if user.FailedAttempts > 0 {
+		if err := models.ClearLoginFailures(user.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to login", "details": err.Error()})
+			return
+		}
+	}
+
+	refreshToken, err := models.IssueRefreshToken(config.DB, user.ID, "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token", "details": err.Error()})
+		return
+	}
+	if err := models.RecordLogin(user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to login", "details": err.Error()})
+		return
+	}
+
+	respondWithTokens(c, "Login successful", user, refreshToken)
+}
+
+// respondWithTokens sends a new access token for user along with refreshToken,
+// together with the user's organisation and the locations they are limited
+// to (null if none).
+// mfa_setup_required tells the client that the account can't be used until
+// two-factor authentication has been set up.
+func respondWithTokens(c *gin.Context, message string, user *models.User, refreshToken string) {
+	response, err := tokenResponse(user, refreshToken)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token", "details": err.Error()})
 		return
 	}
+	response["message"] = message
+	c.JSON(http.StatusOK, response)
+}
+
+// tokenResponse issues an access token for user and returns it with
+// refreshToken, as sent by respondWithTokens.
+func tokenResponse(user *models.User, refreshToken string) (gin.H, error) {
+	scoped, err := user.LocationScoped()
+	if err != nil {
+		return nil, err
+	}
+	var locations []string
+	if scoped {
+		if locations, err = models.GetUserLocations(user.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	token, err := utils.GenerateToken(user.ID, user.OrgID, user.Role, user.TokenVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	return gin.H{
+		"token":              token,
+		"expires_in":         int(utils.AccessTokenTTL.Seconds()),
+		"refresh_token":      refreshToken,
+		"role":               user.Role,
+		"org_id":             user.OrgID,
+		"locations":          locations,
+		"mfa_setup_required": config.MFARequired(user.Role) && !user.MFAEnabled && !user.SSO(),
+	}, nil
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// RefreshToken exchanges a refresh token for a new access token and a new
+// refresh token. Each refresh token can be used once.
+func RefreshToken(c *gin.Context) {
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, refreshToken, err := models.RotateRefreshToken(req.RefreshToken)
+	if err != nil {
+		if errors.Is(err, models.ErrRefreshTokenInvalid) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token is invalid, expired or revoked"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh token", "details": err.Error()})
+		return
+	}
+
+	user, err := models.GetUserByID(userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token is invalid, expired or revoked"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh token", "details": err.Error()})
+		return
+	}
+
+	respondWithTokens(c, "Token refreshed successfully", user, refreshToken)
+}
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// LogoutUser revokes the access token used for the request and, when given,
+// the refresh token issued with it.
+func LogoutUser(c *gin.Context) {
+	var req LogoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := models.RevokeAccessToken(c.GetString("tokenID"), c.GetTime("tokenExpiresAt")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to logout", "details": err.Error()})
+		return
+	}
+	if req.RefreshToken != "" {
+		if err := models.RevokeRefreshToken(c.GetInt("userID"), req.RefreshToken); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to logout", "details": err.Error()})
+			return
+		}
+	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Login successful", "token": token, "role": user.Role})
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
 }
@@ -1,256 +1,145 @@
 package handlers
 
 import (
+	"carbon-footprint-tracker/emissions"
 	"carbon-footprint-tracker/models"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 )
 
-const (
-	EmissionFactorGridElectricity = 0.45
-
-	EmissionFactorDiesel = 2.68
-
-	EmissionFactorPetrol = 2.31
-
-	EmissionFactorBiofuel = 1.0
-
-	EmissionFactorWaterTreatmentDist = 0.00034
-
-	EmissionFactorWasteBiodegradable = 0.1
-	EmissionFactorWasteRecyclable    = -0.1
-	EmissionFactorWasteLandfill      = 0.5
-	EmissionFactorWasteEwaste        = 2.0
-	EmissionFactorGoodsCost          = 0.05
-
-	EmissionFactorFoodWater = 0.0001
+type DashboardData struct {
+	TotalCarbonFootprint float64              `json:"total_carbon_footprint_co2e"`
+	NetCarbonFootprint   float64              `json:"net_carbon_footprint_co2e"` // Less the emissions avoided by solar generation
+	ComponentBreakdown   map[string]float64   `json:"component_breakdown"`
+	ScopeBreakdown       map[string]float64   `json:"scope_breakdown"`
+	PerCapitaFootprint   float64              `json:"per_capita_footprint_co2e"`
+	TotalPopulation      int                  `json:"total_population"`
+	Electricity          DashboardElectricity `json:"electricity"`
+	Trends               *DashboardTrends     `json:"trends,omitempty"`
+}
 
-	EmissionFactorLPG = 2.98
+// DashboardElectricity accounts for on-site solar generation separately from
+// the gross emissions of electricity, which it never reduces.
+type DashboardElectricity struct {
+	GrossGridCO2e        float64 `json:"gross_grid_co2e"`
+	GeneratorCO2e        float64 `json:"generator_co2e"`
+	AvoidedSolarCO2e     float64 `json:"avoided_solar_co2e"` // Grid emissions displaced by every kWh generated
+	NetCO2e              float64 `json:"net_co2e"`           // Grid and generators, less the avoided emissions
+	GridKWH              float64 `json:"grid_kwh"`
+	GeneratorKWH         float64 `json:"generator_kwh"`
+	SolarGeneratedKWH    float64 `json:"solar_generated_kwh"`
+	SolarSelfConsumedKWH float64 `json:"solar_self_consumed_kwh"`
+	SolarExportedKWH     float64 `json:"solar_exported_kwh"`
+	RenewableSharePct    float64 `json:"renewable_share_percent"` // Self-consumed solar as a share of the electricity used
+}
 
-	EmissionFactorFirewood  = 1.8
-	EmissionFactorChemicals = 1.0
-)
+func newDashboardElectricity(t models.ElectricityTotals) DashboardElectricity {
+	d := DashboardElectricity{
+		GrossGridCO2e:        t.GridCO2eKg,
+		GeneratorCO2e:        t.GeneratorCO2eKg,
+		AvoidedSolarCO2e:     t.AvoidedCO2eKg,
+		NetCO2e:              t.GridCO2eKg + t.GeneratorCO2eKg - t.AvoidedCO2eKg,
+		GridKWH:              t.GridKWH,
+		GeneratorKWH:         t.GeneratorKWH,
+		SolarGeneratedKWH:    t.SolarGeneratedKWH,
+		SolarSelfConsumedKWH: t.SolarSelfConsumedKWH,
+		SolarExportedKWH:     t.SolarExportedKWH,
+	}
+	if used := t.GridKWH + t.GeneratorKWH + t.SolarSelfConsumedKWH; used > 0 {
+		d.RenewableSharePct = t.SolarSelfConsumedKWH / used * 100
+	}
+	return d
+}
 
-type DashboardData struct {
-	TotalCarbonFootprint float64                `json:"total_carbon_footprint_co2e"`
-	ComponentBreakdown   map[string]float64     `json:"component_breakdown"`
-	PerCapitaFootprint   float64                `json:"per_capita_footprint_co2e"`
-	TotalPopulation      int                    `json:"total_population"`
-	Trends               map[string]interface{} `json:"trends,omitempty"`
+// componentTables maps each activity table to its dashboard component.
+var componentTables = map[string]string{
+	models.TableElectricConsumption: emissions.ComponentElectrical,
+	models.TableTransport:           emissions.ComponentTransport,
+	models.TableWaterConsumption:    emissions.ComponentWaterConsumption,
+	models.TableWaterTreatment:      emissions.ComponentWaterTreatment,
+	models.TableWaste:               emissions.ComponentWaste,
+	models.TableAccommodation:       emissions.ComponentAccommodation,
+	models.TableGoodsPurchased:      emissions.ComponentGoodsPurchased,
+	models.TableFoodConsumption:     emissions.ComponentFoodConsumption,
 }
 
 func GetDashboardSummary(c *gin.Context) {
-	totalCarbonFootprint := 0.0
-	componentBreakdown := make(map[string]float64)
-	totalPopulation := 0
-
-	//1. Electrical Consumption
-	electricConsumptions, err := models.GetAllElectricConsumptions()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get electric consumption data for dashboard", "details": err.Error()})
-		return
-	}
-	electricFootprint := 0.0
-	for _, ec := range electricConsumptions {
-		switch ec.Source {
-		case "Main Board":
-			if ec.GridElectricityUsedKWH.Valid {
-				electricFootprint += ec.GridElectricityUsedKWH.Float64 * EmissionFactorGridElectricity
-			}
-			// Solar generated is an offset, not an emission
-			if ec.SolarGeneratedKWH.Valid {
-				// This assumes solar generation directly offsets grid consumption.
-				// For net calculation: electricFootprint -= ec.SolarGeneratedKWH.Float64 * EmissionFactorGridElectricity
-				// For gross accounting, it's typically separate. For simplicity, just track grid usage.
-			}
-		case "Diesel Generator":
-			if ec.FuelConsumedLiters.Valid {
-				electricFootprint += ec.FuelConsumedLiters.Float64 * EmissionFactorDiesel
-			}
-		case "Biofuel Generator":
-			if ec.FuelConsumedLiters.Valid {
-				electricFootprint += ec.FuelConsumedLiters.Float64 * EmissionFactorBiofuel // Assumed lower factor
-			}
-			// "Solar Generation" source itself doesn't cause emissions, but represents offset potential
-		}
-	}
-	componentBreakdown["Electrical"] = electricFootprint
-	totalCarbonFootprint += electricFootprint
-
-	//2. Population (for per-capita)
-	populations, err := models.GetAllPopulations()
+	filter, err := parseActivityFilter(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get population data for dashboard", "details": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	for _, p := range populations {
-		totalPopulation += p.RegisteredCount + p.FloatingCount
-	}
 
-	//3. Transport
-	transports, err := models.GetAllTransports()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get transport data for dashboard", "details": err.Error()})
+	granularity := c.DefaultQuery("granularity", GranularityDay)
+	if !isValidGranularity(granularity) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid granularity, expected day, week or month"})
 		return
 	}
-	transportFootprint := 0.0
-	for _, t := range transports {
-		if t.FuelLiters > 0 {
-			if t.FuelType == "Diesel" {
-				transportFootprint += t.FuelLiters * EmissionFactorDiesel
-			} else if t.FuelType == "Petrol" || t.FuelType == "Gasoline" {
-				transportFootprint += t.FuelLiters * EmissionFactorPetrol
-			} else if t.FuelType == "Biofuel" {
-				transportFootprint += t.FuelLiters * EmissionFactorBiofuel
-			}
-		}
-	}
-	componentBreakdown["Transport"] = transportFootprint
-	totalCarbonFootprint += transportFootprint
 
-	//  4. Water Consumption (from usage)
-	waterConsumptions, err := models.GetAllWaterConsumptions()
+	// Footprints are stored on each record when written, so aggregate in SQL.
+	totals, err := models.SumFootprints(filter)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get water consumption data for dashboard", "details": err.Error()})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get emission totals for dashboard", "details": err.Error()})
 		return
 	}
-	waterConsumptionFootprint := 0.0
-	for _, wc := range waterConsumptions {
-		// Assuming total_consumption_kld is daily in KL (1 KL = 1000 L)
-		waterConsumptionFootprint += wc.TotalConsumptionKLD * 1000 * EmissionFactorWaterTreatmentDist
-	}
-	componentBreakdown["Water Consumption"] = waterConsumptionFootprint
-	totalCarbonFootprint += waterConsumptionFootprint
-
-	// 5. Water Treatment (from treatment processes)
-	waterTreatments, err := models.GetAllWaterTreatments()
+	scopeTotals, err := models.SumScopeFootprints(filter)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get water treatment data for dashboard", "details": err.Error()})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get scope totals for dashboard", "details": err.Error()})
 		return
 	}
-	waterTreatmentFootprint := 0.0
-	for _, wt := range waterTreatments {
-		if wt.ElectricityUsedKWH.Valid {
-			waterTreatmentFootprint += wt.ElectricityUsedKWH.Float64 * EmissionFactorGridElectricity
-		}
-		if wt.ChemicalsUsedQuantityKG.Valid {
-			waterTreatmentFootprint += wt.ChemicalsUsedQuantityKG.Float64 * EmissionFactorChemicals
-		}
-	}
-	componentBreakdown["Water Treatment"] = waterTreatmentFootprint
-	totalCarbonFootprint += waterTreatmentFootprint
-
-	//6. Waste Generation
-	wasteEntries, err := models.GetAllWasteEntries()
+	electricity, err := models.SumElectricity(filter)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get waste data for dashboard", "details": err.Error()})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get electricity totals for dashboard", "details": err.Error()})
 		return
 	}
-	wasteFootprint := 0.0
-	for _, w := range wasteEntries {
-		if w.WeightKG > 0 {
-			switch w.WasteType {
-			case "Biodegradable":
-				wasteFootprint += w.WeightKG * EmissionFactorWasteBiodegradable
-			case "Recyclable":
-				wasteFootprint += w.WeightKG * EmissionFactorWasteRecyclable
-			case "Landfill":
-				wasteFootprint += w.WeightKG * EmissionFactorWasteLandfill
-			case "Non-Biodegradable":
-
-				if w.SubCategory.String == "E-waste" {
-					wasteFootprint += w.WeightKG * EmissionFactorWasteEwaste
-				} else {
-					wasteFootprint += w.WeightKG * EmissionFactorWasteLandfill
-				}
-			}
-		}
-	}
-	componentBreakdown["Waste"] = wasteFootprint
-	totalCarbonFootprint += wasteFootprint
-
-	// 7. Accommodation
-	accommodations, err := models.GetAllAccommodations()
+	series, err := models.FootprintSeries(filter, granularity)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get accommodation data for dashboard", "details": err.Error()})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get emission trends for dashboard", "details": err.Error()})
 		return
 	}
-	accommodationFootprint := 0.0
-	for _, acc := range accommodations {
-		if acc.ElectricityConsumptionKWH.Valid {
-			accommodationFootprint += acc.ElectricityConsumptionKWH.Float64 * EmissionFactorGridElectricity
-		}
-		// Assuming water consumption is L/person/day for the duration.
-		if acc.WaterConsumptionLPD.Valid {
-			// Total L = people_count * nights * L/person/day
-			accommodationFootprint += float64(acc.PeopleCount) * float64(acc.Nights) * acc.WaterConsumptionLPD.Float64 * EmissionFactorWaterTreatmentDist
-		}
-
-	}
-	componentBreakdown["Accommodation"] = accommodationFootprint
-	totalCarbonFootprint += accommodationFootprint
 
-	goodsPurchased, err := models.GetAllGoodsPurchased()
+	// Population (for per-capita)
+	populations, err := models.GetAllPopulations(filter)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get goods purchased data for dashboard", "details": err.Error()})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get population data for dashboard", "details": err.Error()})
 		return
 	}
-	goodsFootprint := 0.0
-	for _, gp := range goodsPurchased {
-
-		goodsFootprint += gp.BillAmountINR * EmissionFactorGoodsCost
-
+	totalPopulation := 0
+	for _, p := range populations {
+		totalPopulation += p.RegisteredCount + p.FloatingCount
 	}
-	componentBreakdown["Goods Purchased"] = goodsFootprint
-	totalCarbonFootprint += goodsFootprint
 
-	foodConsumptions, err := models.GetAllFoodConsumptions()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get food consumption data for dashboard", "details": err.Error()})
-		return
+	totalCarbonFootprint := 0.0
+	componentBreakdown := make(map[string]float64)
+	scopeBreakdown := map[string]float64{
+		emissions.ScopeName(emissions.Scope1): 0,
+		emissions.ScopeName(emissions.Scope2): 0,
+		emissions.ScopeName(emissions.Scope3): 0,
 	}
-	foodFootprint := 0.0
-	for _, fc := range foodConsumptions {
-		if fc.WaterUsedLWashingCooking.Valid {
-			foodFootprint += fc.WaterUsedLWashingCooking.Float64 * EmissionFactorFoodWater
-		}
-		if fc.FuelUsedType.Valid {
-			switch fc.FuelUsedType.String {
-			case "LPG":
-				if fc.FuelUsedQuantity.Valid {
-					foodFootprint += fc.FuelUsedQuantity.Float64 * EmissionFactorLPG
-				}
-			case "Firewood":
-				if fc.FuelUsedQuantity.Valid {
-					foodFootprint += fc.FuelUsedQuantity.Float64 * EmissionFactorFirewood
-				}
-			case "Electricity":
-				if fc.FuelUsedQuantity.Valid {
-					foodFootprint += fc.FuelUsedQuantity.Float64 * EmissionFactorGridElectricity
-				}
-			}
-		}
-		// Embodied carbon of food items themselves is a huge factor but very complex.
-		// fc.QuantityCookedKgLiter could be used with specific food-type emission factors.
+	trends := newTrendBuilder(granularity)
+	for table, component := range componentTables {
+		componentBreakdown[component] = totals[table]
+		scopeBreakdown[emissions.ScopeName(emissions.Scope1)] += scopeTotals[table].Scope1CO2eKg
+		scopeBreakdown[emissions.ScopeName(emissions.Scope2)] += scopeTotals[table].Scope2CO2eKg
+		scopeBreakdown[emissions.ScopeName(emissions.Scope3)] += scopeTotals[table].Scope3CO2eKg
+		totalCarbonFootprint += totals[table]
+		trends.add(component, series[table])
 	}
-	componentBreakdown["Food Consumption"] = foodFootprint
-	totalCarbonFootprint += foodFootprint
 
 	perCapitaFootprint := 0.0
 	if totalPopulation > 0 {
 		perCapitaFootprint = totalCarbonFootprint / float64(totalPopulation)
 	}
 
-	trends := map[string]interface{}{
-		"daily_carbon": []interface{}{},
-	}
-
 	c.JSON(http.StatusOK, DashboardData{
 		TotalCarbonFootprint: totalCarbonFootprint,
+		NetCarbonFootprint:   totalCarbonFootprint - electricity.AvoidedCO2eKg,
 		ComponentBreakdown:   componentBreakdown,
+		ScopeBreakdown:       scopeBreakdown,
 		PerCapitaFootprint:   perCapitaFootprint,
 		TotalPopulation:      totalPopulation,
-		Trends:               trends,
+		Electricity:          newDashboardElectricity(electricity),
+		Trends:               trends.build(),
 	})
 }
@@ -3,6 +3,7 @@ package handlers
 import (
 	"carbon-footprint-tracker/models"
 	"database/sql"
+	"fmt"
 	"net/http"
 	"strconv"
 	"time"
@@ -26,8 +27,34 @@ type ElectricConsumptionRequest struct {
 	ElectricityBillCostINR    float64 `json:"electricity_bill_cost_inr"`
 	ElectricalAppliancesCount int     `json:"electrical_appliances_count"`
 
-	SolarGeneratedKWH float64 `json:"solar_generated_kwh"`
-	Remarks           string  `json:"remarks"`
+	SolarGeneratedKWH    float64 `json:"solar_generated_kwh"`
+	SolarSelfConsumedKWH float64 `json:"solar_self_consumed_kwh"`
+	SolarExportedKWH     float64 `json:"solar_exported_kwh"`
+	Remarks              string  `json:"remarks"`
+}
+
+// splitSolar completes the self-consumed/exported split of e's solar
+// generation when only part of it was given, and checks that it adds up.
+func splitSolar(e *models.ElectricConsumption) error {
+	self, exported := e.SolarSelfConsumedKWH.Float64, e.SolarExportedKWH.Float64
+	if !e.SolarGeneratedKWH.Valid {
+		if self+exported > 0 {
+			e.SolarGeneratedKWH = toNullFloat64(self + exported)
+		}
+		return nil
+	}
+
+	generated := e.SolarGeneratedKWH.Float64
+	if self+exported > generated*(1+1e-9) {
+		return fmt.Errorf("solar_self_consumed_kwh and solar_exported_kwh add up to more than solar_generated_kwh (%g)", generated)
+	}
+	switch {
+	case e.SolarSelfConsumedKWH.Valid && !e.SolarExportedKWH.Valid:
+		e.SolarExportedKWH = toNullFloat64(generated - self)
+	case !e.SolarSelfConsumedKWH.Valid && e.SolarExportedKWH.Valid:
+		e.SolarSelfConsumedKWH = toNullFloat64(generated - exported)
+	}
+	return nil
 }
 
 func toNullFloat64(f float64) sql.NullFloat64 {
@@ -62,6 +89,26 @@ func AddElectricConsumption(c *gin.Context) {
 		return
 	}
 
+	e := newElectricConsumption(req)
+	if err := splitSolar(&e); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !requireLocation(c, e.Location) {
+		return
+	}
+
+	if err := e.Create(requestActor(c)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add electric consumption", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Electric consumption added successfully", "id": e.ID})
+}
+
+// newElectricConsumption builds a electric consumption from req, filling in the defaults for omitted fields.
+func newElectricConsumption(req ElectricConsumptionRequest) models.ElectricConsumption {
 	if req.Location == "" {
 		req.Location = "Overall"
 	}
@@ -69,7 +116,7 @@ func AddElectricConsumption(c *gin.Context) {
 		req.Date = time.Now()
 	}
 
-	e := models.ElectricConsumption{
+	return models.ElectricConsumption{
 		Date:                      req.Date,
 		Location:                  req.Location,
 		Source:                    req.Source,
@@ -83,24 +130,48 @@ func AddElectricConsumption(c *gin.Context) {
 		ElectricityBillCostINR:    toNullFloat64(req.ElectricityBillCostINR),
 		ElectricalAppliancesCount: toNullInt32(req.ElectricalAppliancesCount),
 		SolarGeneratedKWH:         toNullFloat64(req.SolarGeneratedKWH),
+		SolarSelfConsumedKWH:      toNullFloat64(req.SolarSelfConsumedKWH),
+		SolarExportedKWH:          toNullFloat64(req.SolarExportedKWH),
 		Remarks:                   toNullString(req.Remarks),
 	}
+}
 
-	if err := e.Create(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add electric consumption", "details": err.Error()})
+// ImportElectricConsumptions creates electric consumption from a CSV upload, see importCSV.
+func ImportElectricConsumptions(c *gin.Context) {
+	footprinter, ok := importFootprinter(c)
+	if !ok {
 		return
 	}
+	importCSV(c, "electric consumption", func(db models.Querier, req ElectricConsumptionRequest) error {
+		e := newElectricConsumption(req)
+		if err := splitSolar(&e); err != nil {
+			return err
+		}
+		if !inLocationScope(c, e.Location) {
+			return errOutsideLocations(e.Location)
+		}
+		return e.CreateWith(db, requestActor(c), footprinter)
+	})
+}
 
-	c.JSON(http.StatusCreated, gin.H{"message": "Electric consumption added successfully", "id": e.ID})
+// ExportElectricConsumptions streams electric consumption entries as CSV or XLSX, see exportModule.
+func ExportElectricConsumptions(c *gin.Context) {
+	exportModule(c, models.TableElectricConsumption)
 }
 
 func GetElectricConsumptions(c *gin.Context) {
-	consumptions, err := models.GetAllElectricConsumptions()
+	opts, err := parseListOptions(c, models.TableElectricConsumption)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	consumptions, total, err := models.ListElectricConsumptions(opts)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve electric consumptions", "details": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, consumptions)
+	c.JSON(http.StatusOK, ListResponse{Data: consumptions, Page: opts.Page, PageSize: opts.PageSize, Total: total})
 }
 
 func UpdateElectricConsumption(c *gin.Context) {
@@ -111,7 +182,7 @@ func UpdateElectricConsumption(c *gin.Context) {
 		return
 	}
 
-	e, err := models.GetElectricConsumptionByID(id)
+	e, err := models.GetElectricConsumptionByID(requestOrgID(c), id)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Electric consumption entry not found"})
@@ -121,6 +192,8 @@ func UpdateElectricConsumption(c *gin.Context) {
 		return
 	}
 
+	location := e.Location
+
 	var req ElectricConsumptionRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -144,9 +217,23 @@ func UpdateElectricConsumption(c *gin.Context) {
 	e.ElectricityBillCostINR = toNullFloat64(req.ElectricityBillCostINR)
 	e.ElectricalAppliancesCount = toNullInt32(req.ElectricalAppliancesCount)
 	e.SolarGeneratedKWH = toNullFloat64(req.SolarGeneratedKWH)
+	e.SolarSelfConsumedKWH = toNullFloat64(req.SolarSelfConsumedKWH)
+	e.SolarExportedKWH = toNullFloat64(req.SolarExportedKWH)
 	e.Remarks = toNullString(req.Remarks)
+	if err := splitSolar(e); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !requireLocationChange(c, location, e.Location) {
+		return
+	}
 
-	if err := e.Update(); err != nil {
+	if err := e.Update(requestActor(c)); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Electric consumption entry not found"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update electric consumption", "details": err.Error()})
 		return
 	}
@@ -162,10 +249,23 @@ func DeleteElectricConsumption(c *gin.Context) {
 		return
 	}
 
-	if err := models.DeleteElectricConsumption(id); err != nil {
+	if !requireRecordLocation(c, models.TableElectricConsumption, id) {
+		return
+	}
+
+	if err := models.DeleteElectricConsumption(requestActor(c), id); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Electric consumption entry not found"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete electric consumption", "details": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Electric consumption deleted successfully"})
 }
+
+// RestoreElectricConsumption undeletes an electric consumption entry, see restoreRecord.
+func RestoreElectricConsumption(c *gin.Context) {
+	restoreRecord(c, models.TableElectricConsumption, "electric consumption")
+}
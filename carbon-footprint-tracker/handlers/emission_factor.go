@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"carbon-footprint-tracker/models"
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type EmissionFactorRequest struct {
+	Source    string    `json:"source" binding:"required"`
+	Category  string    `json:"category" binding:"required"`
+	Unit      string    `json:"unit" binding:"required"`
+	Value     *float64  `json:"value" binding:"required"` // Pointer so that 0 and negative factors are accepted
+	ValidFrom time.Time `json:"valid_from" binding:"required"`
+	ValidTo   time.Time `json:"valid_to"` // Zero for an open-ended factor
+	Reference string    `json:"reference"`
+}
+
+func toNullTime(t time.Time) sql.NullTime {
+	if !t.IsZero() {
+		return sql.NullTime{Time: t, Valid: true}
+	}
+	return sql.NullTime{}
+}
+
+func truncateToDate(t time.Time) time.Time {
+	if t.IsZero() {
+		return t
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// applyEmissionFactorRequest copies req onto ef and validates the validity period.
+func applyEmissionFactorRequest(ef *models.EmissionFactor, req EmissionFactorRequest) (int, gin.H) {
+	ef.Source = req.Source
+	ef.Category = req.Category
+	ef.Unit = req.Unit
+	ef.Value = *req.Value
+	ef.ValidFrom = truncateToDate(req.ValidFrom)
+	ef.ValidTo = toNullTime(truncateToDate(req.ValidTo))
+	ef.Reference = toNullString(req.Reference)
+
+	if ef.ValidTo.Valid && ef.ValidTo.Time.Before(ef.ValidFrom) {
+		return http.StatusBadRequest, gin.H{"error": "valid_to must not be before valid_from"}
+	}
+
+	overlaps, err := ef.HasOverlap()
+	if err != nil {
+		return http.StatusInternalServerError, gin.H{"error": "Failed to validate emission factor period", "details": err.Error()}
+	}
+	if overlaps {
+		return http.StatusConflict, gin.H{"error": "Another factor for this category and source is valid during this period"}
+	}
+	return 0, nil
+}
+
+func AddEmissionFactor(c *gin.Context) {
+	var req EmissionFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ef := models.EmissionFactor{}
+	if status, body := applyEmissionFactorRequest(&ef, req); body != nil {
+		c.JSON(status, body)
+		return
+	}
+
+	if err := ef.Create(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add emission factor", "details": err.Error()})
+		return
+	}
+
+	recomputeFootprintsInBackground()
+	c.JSON(http.StatusCreated, gin.H{"message": "Emission factor added successfully", "id": ef.ID})
+}
+
+func GetEmissionFactors(c *gin.Context) {
+	factors, err := models.GetAllEmissionFactors()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve emission factors", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, factors)
+}
+
+func UpdateEmissionFactor(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	ef, err := models.GetEmissionFactorByID(id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Emission factor not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve emission factor", "details": err.Error()})
+		return
+	}
+
+	var req EmissionFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if status, body := applyEmissionFactorRequest(ef, req); body != nil {
+		c.JSON(status, body)
+		return
+	}
+
+	if err := ef.Update(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update emission factor", "details": err.Error()})
+		return
+	}
+
+	recomputeFootprintsInBackground()
+	c.JSON(http.StatusOK, gin.H{"message": "Emission factor updated successfully"})
+}
+
+func DeleteEmissionFactor(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	if err := models.DeleteEmissionFactor(id); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Emission factor not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete emission factor", "details": err.Error()})
+		return
+	}
+
+	recomputeFootprintsInBackground()
+	c.JSON(http.StatusOK, gin.H{"message": "Emission factor deleted successfully"})
+}
+
+// RecomputeFootprints refreshes the stored footprint of every activity record
+// using the current factor library.
+func RecomputeFootprints(c *gin.Context) {
+	updated, err := models.RecomputeFootprints()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to recompute footprints", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Footprints recomputed successfully", "updated": updated})
+}
+
+// footprintRecomputes holds the pending background recompute. Factor changes
+// made while one is pending are covered by it, so a burst of changes costs a
+// single run after the one in progress.
+var footprintRecomputes = make(chan struct{}, 1)
+
+// StartFootprintRecomputer starts the worker running the recomputes queued by
+// recomputeFootprintsInBackground, one at a time. If some records have no
+// stored footprint yet, e.g. as they were written before footprints were
+// stored per scope, it queues one straight away to fill them in.
+func StartFootprintRecomputer() {
+	go func() {
+		for range footprintRecomputes {
+			updated, err := models.RecomputeFootprints()
+			if err != nil {
+				log.Printf("Failed to recompute footprints: %v", err)
+				continue
+			}
+			log.Printf("Recomputed footprints for %d records", updated)
+		}
+	}()
+
+	missing, err := models.FootprintsMissing()
+	if err != nil {
+		log.Printf("Failed to check for records without a footprint: %v", err)
+		return
+	}
+	if missing {
+		log.Println("Some records have no stored footprint, recomputing footprints")
+		recomputeFootprintsInBackground()
+	}
+}
+
+// recomputeFootprintsInBackground queues a refresh of the stored footprints
+// after the factor library changes, without holding up the request.
+func recomputeFootprintsInBackground() {
+	select {
+	case footprintRecomputes <- struct{}{}:
+	default: // One is already pending
+	}
+}
@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"carbon-footprint-tracker/export"
+	"carbon-footprint-tracker/models"
+	"database/sql/driver"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// exportSheet streams the records of one module as spreadsheet rows. Columns
+// are the JSON fields of the model, so nullable values come out as plain
+// cells, stored footprints as the co2e_kg column and fields hidden from the
+// API, such as the owning organisation, not at all.
+type exportSheet struct {
+	name   string
+	header []string
+	write  func(o models.ListOptions, w export.Writer) error
+}
+
+func newExportSheet[T any](name string, each func(models.ListOptions, func(T) error) error) exportSheet {
+	return exportSheet{
+		name:   name,
+		header: exportHeader(reflect.TypeOf((*T)(nil)).Elem()),
+		write: func(o models.ListOptions, w export.Writer) error {
+			return each(o, func(record T) error {
+				return w.Row(exportValues(reflect.ValueOf(record), nil))
+			})
+		},
+	}
+}
+
+// moduleExports holds the export sheet of each data entry module.
+var moduleExports = map[string]exportSheet{
+	models.TableElectricConsumption: newExportSheet("Electric Consumption", models.EachElectricConsumption),
+	models.TablePopulation:          newExportSheet("Population", models.EachPopulation),
+	models.TableTransport:           newExportSheet("Transport", models.EachTransport),
+	models.TableWaterConsumption:    newExportSheet("Water Consumption", models.EachWaterConsumption),
+	models.TableWaterTreatment:      newExportSheet("Water Treatment", models.EachWaterTreatment),
+	models.TableWaste:               newExportSheet("Waste", models.EachWasteEntry),
+	models.TableAccommodation:       newExportSheet("Accommodation", models.EachAccommodation),
+	models.TableGoodsPurchased:      newExportSheet("Goods Purchased", models.EachGoodsPurchased),
+	models.TableFoodConsumption:     newExportSheet("Food Consumption", models.EachFoodConsumption),
+}
+
+func exportHeader(t reflect.Type) []string {
+	var header []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			header = append(header, exportHeader(f.Type)...)
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		header = append(header, name)
+	}
+	return header
+}
+
+var valuerType = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+
+// exportValues appends the cell values of record to values, unwrapping
+// sql.Null* fields to their value or nil.
+func exportValues(record reflect.Value, values []interface{}) []interface{} {
+	for i := 0; i < record.NumField(); i++ {
+		f := record.Field(i)
+		if record.Type().Field(i).Anonymous && f.Kind() == reflect.Struct {
+			values = exportValues(f, values)
+			continue
+		}
+		if record.Type().Field(i).Tag.Get("json") == "-" {
+			continue
+		}
+		if f.Type().Implements(valuerType) {
+			v, err := f.Interface().(driver.Valuer).Value()
+			if err != nil {
+				v = nil
+			}
+			values = append(values, v)
+			continue
+		}
+		values = append(values, f.Interface())
+	}
+	return values
+}
+
+// parseExportFormat reads the format query parameter, which defaults to csv.
+func parseExportFormat(c *gin.Context) (string, error) {
+	format := strings.ToLower(c.DefaultQuery("format", export.FormatCSV))
+	if format != export.FormatCSV && format != export.FormatXLSX {
+		return "", fmt.Errorf("Invalid format '%s', expected csv or xlsx", format)
+	}
+	return format, nil
+}
+
+// exportModule streams every record of a module matching the list filters
+// (see parseListOptions) as CSV or XLSX. Paging parameters are ignored.
+func exportModule(c *gin.Context, table string) {
+	format, err := parseExportFormat(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	opts, err := parseListOptions(c, table)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	opts.Page, opts.PageSize = 0, 0
+
+	sheet := moduleExports[table]
+	filename := fmt.Sprintf("%s_%s.%s", table, time.Now().Format(filterDateLayout), format)
+	streamExport(c, format, filename, func(w export.Writer) error {
+		if err := w.Sheet(sheet.name, sheet.header); err != nil {
+			return err
+		}
+		return sheet.write(opts, w)
+	})
+}
+
+// ExportReport streams an XLSX workbook with a summary sheet of kgCO2e per
+// component followed by one sheet per module, filtered by from, to and
+// location like the dashboard.
+func ExportReport(c *gin.Context) {
+	if format := c.DefaultQuery("format", export.FormatXLSX); format != export.FormatXLSX {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The combined report is only available as xlsx"})
+		return
+	}
+	filter, err := parseActivityFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	totals, err := models.SumFootprints(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get emission totals for report", "details": err.Error()})
+		return
+	}
+
+	opts := models.ListOptions{ActivityFilter: filter}
+	filename := fmt.Sprintf("carbon_footprint_report_%s.xlsx", time.Now().Format(filterDateLayout))
+	streamExport(c, export.FormatXLSX, filename, func(w export.Writer) error {
+		if err := w.Sheet("Summary", []string{"component", "co2e_kg"}); err != nil {
+			return err
+		}
+		total := 0.0
+		for _, table := range models.ActivityTables {
+			total += totals[table]
+			if err := w.Row([]interface{}{componentTables[table], totals[table]}); err != nil {
+				return err
+			}
+		}
+		if err := w.Row([]interface{}{"Total", total}); err != nil {
+			return err
+		}
+
+		for _, table := range models.ModuleTables {
+			sheet := moduleExports[table]
+			if err := w.Sheet(sheet.name, sheet.header); err != nil {
+				return err
+			}
+			if err := sheet.write(opts, w); err != nil {
+				return fmt.Errorf("%s: %w", table, err)
+			}
+		}
+		return nil
+	})
+}
+
+// streamExport writes the export straight to the response. Errors before the
+// first byte is sent are reported as JSON; after that the download can only
+// be cut short, so they are logged.
+func streamExport(c *gin.Context, format, filename string, write func(w export.Writer) error) {
+	c.Header("Content-Type", export.ContentType(format))
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+
+	w, err := export.NewWriter(format, c.Writer)
+	if err == nil {
+		if err = write(w); err == nil {
+			err = w.Close()
+		}
+	}
+	if err == nil {
+		return
+	}
+
+	if !c.Writer.Written() {
+		c.Writer.Header().Del("Content-Disposition")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export data", "details": err.Error()})
+		return
+	}
+	log.Printf("Export %s failed after the response started: %v", filename, err)
+	c.Abort()
+}
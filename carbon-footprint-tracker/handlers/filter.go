@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"carbon-footprint-tracker/models"
+	"errors"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const filterDateLayout = "2006-01-02"
+
+// parseActivityFilter reads the from, to (both YYYY-MM-DD, inclusive) and
+// location query parameters shared by the reporting endpoints. Results are
+// always limited to the request's organisation and the user's assigned
+// locations, if any.
+func parseActivityFilter(c *gin.Context) (models.ActivityFilter, error) {
+	f := models.ActivityFilter{OrgID: requestOrgID(c)}
+
+	var err error
+	if f.From, f.To, err = parseDateRange(c); err != nil {
+		return f, err
+	}
+
+	f.Location = c.Query("location")
+	f.Locations = locationScope(c)
+	return f, nil
+}
+
+// parseDateRange reads the from and to query parameters (both YYYY-MM-DD,
+// inclusive). The returned to is exclusive, as model filters expect, and
+// either is zero when not given.
+func parseDateRange(c *gin.Context) (from, to time.Time, err error) {
+	if v := c.Query("from"); v != "" {
+		if from, err = time.Parse(filterDateLayout, v); err != nil {
+			return from, to, errors.New("Invalid 'from' date, expected YYYY-MM-DD")
+		}
+	}
+	if v := c.Query("to"); v != "" {
+		if to, err = time.Parse(filterDateLayout, v); err != nil {
+			return from, to, errors.New("Invalid 'to' date, expected YYYY-MM-DD")
+		}
+		// Model filters treat to as exclusive, so include the whole day.
+		to = to.AddDate(0, 0, 1)
+	}
+	if !from.IsZero() && !to.IsZero() && !from.Before(to) {
+		return from, to, errors.New("'from' must not be after 'to'")
+	}
+	return from, to, nil
+}
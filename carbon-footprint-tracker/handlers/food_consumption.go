@@ -30,11 +30,27 @@ func AddFoodConsumption(c *gin.Context) {
 		return
 	}
 
+	f := newFoodConsumption(req)
+
+	if !requireLocation(c, f.Location) {
+		return
+	}
+
+	if err := f.Create(requestActor(c)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add food consumption", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Food consumption added successfully", "id": f.ID})
+}
+
+// newFoodConsumption builds a food consumption entry from req, filling in the defaults for omitted fields.
+func newFoodConsumption(req FoodConsumptionRequest) models.FoodConsumption {
 	if req.Date.IsZero() {
 		req.Date = time.Now()
 	}
 
-	f := models.FoodConsumption{
+	return models.FoodConsumption{
 		Date:                     req.Date,
 		Location:                 req.Location,
 		FoodItem:                 req.FoodItem,
@@ -46,22 +62,41 @@ func AddFoodConsumption(c *gin.Context) {
 		FuelUsedQuantity:         toNullFloat64(req.FuelUsedQuantity),
 		Remarks:                  toNullString(req.Remarks),
 	}
+}
 
-	if err := f.Create(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add food consumption", "details": err.Error()})
+// ImportFoodConsumptions creates food consumption from a CSV upload, see importCSV.
+func ImportFoodConsumptions(c *gin.Context) {
+	footprinter, ok := importFootprinter(c)
+	if !ok {
 		return
 	}
+	importCSV(c, "food consumption", func(db models.Querier, req FoodConsumptionRequest) error {
+		f := newFoodConsumption(req)
+		if !inLocationScope(c, f.Location) {
+			return errOutsideLocations(f.Location)
+		}
+		return f.CreateWith(db, requestActor(c), footprinter)
+	})
+}
 
-	c.JSON(http.StatusCreated, gin.H{"message": "Food consumption added successfully", "id": f.ID})
+// ExportFoodConsumptions streams food consumption entries as CSV or XLSX, see exportModule.
+func ExportFoodConsumptions(c *gin.Context) {
+	exportModule(c, models.TableFoodConsumption)
 }
 
 func GetFoodConsumptions(c *gin.Context) {
-	consumptions, err := models.GetAllFoodConsumptions()
+	opts, err := parseListOptions(c, models.TableFoodConsumption)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	consumptions, total, err := models.ListFoodConsumptions(opts)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve food consumptions", "details": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, consumptions)
+	c.JSON(http.StatusOK, ListResponse{Data: consumptions, Page: opts.Page, PageSize: opts.PageSize, Total: total})
 }
 
 func UpdateFoodConsumption(c *gin.Context) {
@@ -72,7 +107,7 @@ func UpdateFoodConsumption(c *gin.Context) {
 		return
 	}
 
-	f, err := models.GetFoodConsumptionByID(id)
+	f, err := models.GetFoodConsumptionByID(requestOrgID(c), id)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Food consumption entry not found"})
@@ -82,6 +117,8 @@ func UpdateFoodConsumption(c *gin.Context) {
 		return
 	}
 
+	location := f.Location
+
 	var req FoodConsumptionRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -105,7 +142,15 @@ func UpdateFoodConsumption(c *gin.Context) {
 	f.FuelUsedQuantity = toNullFloat64(req.FuelUsedQuantity)
 	f.Remarks = toNullString(req.Remarks)
 
-	if err := f.Update(); err != nil {
+	if !requireLocationChange(c, location, f.Location) {
+		return
+	}
+
+	if err := f.Update(requestActor(c)); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Food consumption entry not found"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update food consumption", "details": err.Error()})
 		return
 	}
@@ -121,10 +166,23 @@ func DeleteFoodConsumption(c *gin.Context) {
 		return
 	}
 
-	if err := models.DeleteFoodConsumption(id); err != nil {
+	if !requireRecordLocation(c, models.TableFoodConsumption, id) {
+		return
+	}
+
+	if err := models.DeleteFoodConsumption(requestActor(c), id); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Food consumption entry not found"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete food consumption", "details": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Food consumption deleted successfully"})
 }
+
+// RestoreFoodConsumption undeletes a food consumption entry, see restoreRecord.
+func RestoreFoodConsumption(c *gin.Context) {
+	restoreRecord(c, models.TableFoodConsumption, "food consumption")
+}
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"carbon-footprint-tracker/config"
+	"carbon-footprint-tracker/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// generatorPowerFactor converts a set's kVA rating to the kW it is rated
+	// to deliver, as generator sets are rated at 0.8.
+	generatorPowerFactor = 0.8
+
+	// A month is flagged when its specific fuel consumption exceeds the
+	// benchmark by more than this share, or its load is below lowLoadPercent,
+	// where sets burn disproportionately more fuel per kWh.
+	sfcTolerance   = 0.25
+	lowLoadPercent = 30.0
+)
+
+// Flags on generator months.
+const (
+	GeneratorFlagHighSFC     = "high_sfc"          // Fuel per kWh well above the benchmark
+	GeneratorFlagLowLoad     = "low_load"          // Running far below its rating
+	GeneratorFlagAboveRating = "load_above_rating" // More energy than the rating allows in the hours run, likely a data error
+)
+
+type GeneratorAnalytics struct {
+	Generators []GeneratorReport `json:"generators"`
+}
+
+// GeneratorReport is one generator set, told apart by its location, source
+// and rated capacity.
+type GeneratorReport struct {
+	Location     string                 `json:"location"`
+	Source       string                 `json:"source"`
+	CapacityKVA  *float64               `json:"dg_capacity_kva"`         // null when not recorded
+	BenchmarkSFC *float64               `json:"benchmark_sfc_l_per_kwh"` // null when the source has none
+	Months       []GeneratorMonthReport `json:"months"`
+}
+
+// sfcBenchmark returns the specific fuel consumption expected of a set
+// running on source, or nil if none is configured.
+func sfcBenchmark(source string) *float64 {
+	benchmark := config.GeneratorSFCBenchmark
+	if source == "Biofuel Generator" {
+		benchmark = config.GeneratorSFCBenchmarkBiofuel
+	}
+	if benchmark == 0 {
+		return nil
+	}
+	return &benchmark
+}
+
+// GeneratorMonthReport holds a set's totals and efficiency for a month. Each
+// ratio is null when no record has both of its figures.
+type GeneratorMonthReport struct {
+	Month              string   `json:"month"` // YYYY-MM
+	Records            int      `json:"records"`
+	RunningHours       float64  `json:"running_hours"`
+	FuelConsumedLiters float64  `json:"fuel_consumed_liters"`
+	EnergyGeneratedKWH float64  `json:"energy_generated_kwh"`
+	CO2eKg             float64  `json:"co2e_kg"`
+	SFC                *float64 `json:"sfc_l_per_kwh"`       // Specific fuel consumption
+	LoadFactorPct      *float64 `json:"load_factor_percent"` // Average output against the kW rating while running
+	CO2ePerKWH         *float64 `json:"co2e_kg_per_kwh"`
+	Flags              []string `json:"flags"`
+}
+
+// ratio returns a / b, or nil when b is zero.
+func ratio(a, b float64) *float64 {
+	if b == 0 {
+		return nil
+	}
+	r := a / b
+	return &r
+}
+
+// newGeneratorMonthReport reports the month m of a set, flagging it against
+// the set's benchmark unless that is nil.
+func newGeneratorMonthReport(m models.GeneratorMonth, benchmark *float64) GeneratorMonthReport {
+	r := GeneratorMonthReport{
+		Month:              m.Month.Format("2006-01"),
+		Records:            m.Records,
+		RunningHours:       m.RunningHours,
+		FuelConsumedLiters: m.FuelLiters,
+		EnergyGeneratedKWH: m.EnergyKWH,
+		CO2eKg:             m.CO2eKg,
+		SFC:                ratio(m.FuelForEnergyLiters, m.EnergyForFuelKWH),
+		CO2ePerKWH:         ratio(m.CO2eForEnergyKg, m.EnergyForCO2eKWH),
+		Flags:              []string{},
+	}
+	if m.CapacityKVA.Valid {
+		if load := ratio(m.EnergyForHoursKWH, m.CapacityKVA.Float64*generatorPowerFactor*m.HoursForEnergy); load != nil {
+			*load *= 100
+			r.LoadFactorPct = load
+		}
+	}
+
+	if r.SFC != nil && benchmark != nil && *r.SFC > *benchmark*(1+sfcTolerance) {
+		r.Flags = append(r.Flags, GeneratorFlagHighSFC)
+	}
+	if r.LoadFactorPct != nil && *r.LoadFactorPct < lowLoadPercent {
+		r.Flags = append(r.Flags, GeneratorFlagLowLoad)
+	}
+	if r.LoadFactorPct != nil && *r.LoadFactorPct > 100 {
+		r.Flags = append(r.Flags, GeneratorFlagAboveRating)
+	}
+	return r
+}
+
+// GetGeneratorAnalytics reports the efficiency of the diesel and biofuel
+// generator sets per month for the from/to/location period: specific fuel
+// consumption, load factor against the rated kVA and emissions per kWh, with
+// flags for months running well below the benchmark for their fuel, see
+// config.GeneratorSFCBenchmark and config.GeneratorSFCBenchmarkBiofuel.
+func GetGeneratorAnalytics(c *gin.Context) {
+	filter, err := parseActivityFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	months, err := models.GeneratorMonths(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get generator data for analytics", "details": err.Error()})
+		return
+	}
+
+	analytics := GeneratorAnalytics{Generators: []GeneratorReport{}}
+	var current *GeneratorReport
+	for _, m := range months {
+		// Months arrive ordered by set, so a set's months are consecutive.
+		if current == nil || current.Location != m.Location || current.Source != m.Source || !sameCapacity(current.CapacityKVA, m) {
+			analytics.Generators = append(analytics.Generators, GeneratorReport{Location: m.Location, Source: m.Source, BenchmarkSFC: sfcBenchmark(m.Source)})
+			current = &analytics.Generators[len(analytics.Generators)-1]
+			if m.CapacityKVA.Valid {
+				capacity := m.CapacityKVA.Float64
+				current.CapacityKVA = &capacity
+			}
+		}
+		current.Months = append(current.Months, newGeneratorMonthReport(m, current.BenchmarkSFC))
+	}
+
+	c.JSON(http.StatusOK, analytics)
+}
+
+func sameCapacity(capacity *float64, m models.GeneratorMonth) bool {
+	if capacity == nil || !m.CapacityKVA.Valid {
+		return capacity == nil && !m.CapacityKVA.Valid
+	}
+	return *capacity == m.CapacityKVA.Float64
+}
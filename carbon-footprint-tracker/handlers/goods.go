@@ -35,6 +35,22 @@ func AddGoodsPurchased(c *gin.Context) {
 		return
 	}
 
+	g := newGoodsPurchased(req)
+
+	if !requireLocation(c, g.Location) {
+		return
+	}
+
+	if err := g.Create(requestActor(c)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add goods purchased", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Goods purchased added successfully", "id": g.ID})
+}
+
+// newGoodsPurchased builds a goods purchase from req, filling in the defaults for omitted fields.
+func newGoodsPurchased(req GoodsPurchasedRequest) models.GoodsPurchased {
 	if req.Location == "" {
 		req.Location = "Overall"
 	}
@@ -42,7 +58,7 @@ func AddGoodsPurchased(c *gin.Context) {
 		req.Date = time.Now()
 	}
 
-	g := models.GoodsPurchased{
+	return models.GoodsPurchased{
 		Date:                req.Date,
 		Location:            req.Location,
 		ItemName:            req.ItemName,
@@ -59,22 +75,41 @@ func AddGoodsPurchased(c *gin.Context) {
 		IsRecyclable:        toNullBool(req.IsRecyclable),
 		Remarks:             toNullString(req.Remarks),
 	}
+}
 
-	if err := g.Create(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add goods purchased", "details": err.Error()})
+// ImportGoodsPurchased creates goods purchased from a CSV upload, see importCSV.
+func ImportGoodsPurchased(c *gin.Context) {
+	footprinter, ok := importFootprinter(c)
+	if !ok {
 		return
 	}
+	importCSV(c, "goods purchased", func(db models.Querier, req GoodsPurchasedRequest) error {
+		g := newGoodsPurchased(req)
+		if !inLocationScope(c, g.Location) {
+			return errOutsideLocations(g.Location)
+		}
+		return g.CreateWith(db, requestActor(c), footprinter)
+	})
+}
 
-	c.JSON(http.StatusCreated, gin.H{"message": "Goods purchased added successfully", "id": g.ID})
+// ExportGoodsPurchased streams goods purchased as CSV or XLSX, see exportModule.
+func ExportGoodsPurchased(c *gin.Context) {
+	exportModule(c, models.TableGoodsPurchased)
 }
 
 func GetGoodsPurchased(c *gin.Context) {
-	goods, err := models.GetAllGoodsPurchased()
+	opts, err := parseListOptions(c, models.TableGoodsPurchased)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	goods, total, err := models.ListGoodsPurchased(opts)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve goods purchased", "details": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, goods)
+	c.JSON(http.StatusOK, ListResponse{Data: goods, Page: opts.Page, PageSize: opts.PageSize, Total: total})
 }
 
 func UpdateGoodsPurchased(c *gin.Context) {
@@ -85,7 +120,7 @@ func UpdateGoodsPurchased(c *gin.Context) {
 		return
 	}
 
-	g, err := models.GetGoodsPurchasedByID(id)
+	g, err := models.GetGoodsPurchasedByID(requestOrgID(c), id)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Goods purchased entry not found"})
@@ -95,6 +130,8 @@ func UpdateGoodsPurchased(c *gin.Context) {
 		return
 	}
 
+	location := g.Location
+
 	var req GoodsPurchasedRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -125,7 +162,15 @@ func UpdateGoodsPurchased(c *gin.Context) {
 	g.IsRecyclable = toNullBool(req.IsRecyclable)
 	g.Remarks = toNullString(req.Remarks)
 
-	if err := g.Update(); err != nil {
+	if !requireLocationChange(c, location, g.Location) {
+		return
+	}
+
+	if err := g.Update(requestActor(c)); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Goods purchased entry not found"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update goods purchased", "details": err.Error()})
 		return
 	}
@@ -141,10 +186,23 @@ func DeleteGoodsPurchased(c *gin.Context) {
 		return
 	}
 
-	if err := models.DeleteGoodsPurchased(id); err != nil {
+	if !requireRecordLocation(c, models.TableGoodsPurchased, id) {
+		return
+	}
+
+	if err := models.DeleteGoodsPurchased(requestActor(c), id); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Goods purchased entry not found"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete goods purchased", "details": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Goods purchased deleted successfully"})
 }
+
+// RestoreGoodsPurchased undeletes goods purchased, see restoreRecord.
+func RestoreGoodsPurchased(c *gin.Context) {
+	restoreRecord(c, models.TableGoodsPurchased, "goods purchased")
+}
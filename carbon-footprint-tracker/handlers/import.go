@@ -0,0 +1,258 @@
+package handlers
+
+import (
+	"carbon-footprint-tracker/config"
+	"carbon-footprint-tracker/models"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+const maxImportBytes = 10 << 20 // 10 MB
+
+type ImportRowError struct {
+	Row   int    `json:"row"` // Line in the CSV file; the header is line 1
+	Error string `json:"error"`
+}
+
+type ImportResult struct {
+	DryRun   bool             `json:"dry_run"`
+	Rows     int              `json:"rows"`
+	Imported int              `json:"imported"` // Rows inserted, or that would be in a dry run
+	Errors   []ImportRowError `json:"errors"`
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// importFootprinter returns the Footprinter every record of an import is
+// written with, so that the factor library is loaded once rather than per
+// row. It responds with 500 and returns false if it can't be built.
+func importFootprinter(c *gin.Context) (models.Footprinter, bool) {
+	footprinter, err := models.NewFootprinter()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get emission factors for import", "details": err.Error()})
+		return nil, false
+	}
+	return footprinter, true
+}
+
+// importCSV creates one record per row of an uploaded CSV file. The header row
+// names the JSON fields of the module's request struct R, and each row is
+// validated the same way as the JSON endpoint. Valid rows are inserted in a
+// single transaction and invalid ones are reported; with dry_run=true the
+// transaction is rolled back so nothing is saved.
+//
+// The file is read from the "file" field of a multipart form, or else from
+// the raw request body.
+func importCSV[R any](c *gin.Context, module string, create func(db models.Querier, req R) error) {
+	dryRun := false
+	if v := c.Query("dry_run"); v != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'dry_run', expected true or false"})
+			return
+		}
+	}
+
+	body, err := openImportFile(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read CSV upload", "details": err.Error()})
+		return
+	}
+	defer body.Close()
+
+	reader := csv.NewReader(body)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read CSV header", "details": err.Error()})
+		return
+	}
+	fields, err := csvFieldIndexes(reflect.TypeOf((*R)(nil)).Elem(), header)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start import", "details": err.Error()})
+		return
+	}
+	defer tx.Rollback() // No-op once committed
+
+	result := ImportResult{DryRun: dryRun, Errors: []ImportRowError{}}
+	for {
+		record, line, err := readImportRow(reader)
+		if err == io.EOF {
+			break
+		}
+		if err != nil && !errors.Is(err, csv.ErrFieldCount) {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Malformed CSV at line %d", line), "details": err.Error()})
+			} else {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read CSV upload", "details": err.Error()})
+			}
+			return
+		}
+		result.Rows++
+		if err != nil {
+			result.Errors = append(result.Errors, ImportRowError{Row: line, Error: "expected one value per header column"})
+			continue
+		}
+
+		var req R
+		if err := decodeCSVRecord(reflect.ValueOf(&req).Elem(), header, fields, record); err != nil {
+			result.Errors = append(result.Errors, ImportRowError{Row: line, Error: err.Error()})
+			continue
+		}
+		if err := binding.Validator.ValidateStruct(&req); err != nil {
+			result.Errors = append(result.Errors, ImportRowError{Row: line, Error: err.Error()})
+			continue
+		}
+
+		// A savepoint per row keeps one failed insert from aborting the rest.
+		if _, err := tx.Exec(`SAVEPOINT import_row`); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import " + module, "details": err.Error()})
+			return
+		}
+		if err := create(tx, req); err != nil {
+			if _, rbErr := tx.Exec(`ROLLBACK TO SAVEPOINT import_row`); rbErr != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import " + module, "details": rbErr.Error()})
+				return
+			}
+			result.Errors = append(result.Errors, ImportRowError{Row: line, Error: err.Error()})
+			continue
+		}
+		if _, err := tx.Exec(`RELEASE SAVEPOINT import_row`); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import " + module, "details": err.Error()})
+			return
+		}
+		result.Imported++
+	}
+
+	if !dryRun {
+		if err := tx.Commit(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import " + module, "details": err.Error()})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// readImportRow reads the next row of r and returns the line it starts on. A
+// row with the wrong number of values is returned along with an error
+// wrapping csv.ErrFieldCount; a malformed one returns a *csv.ParseError.
+func readImportRow(r *csv.Reader) ([]string, int, error) {
+	record, err := r.Read()
+	if err != nil && !errors.Is(err, csv.ErrFieldCount) {
+		// FieldPos panics unless the row was parsed.
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return nil, parseErr.StartLine, err
+		}
+		return nil, 0, err
+	}
+	line, _ := r.FieldPos(0)
+	return record, line, err
+}
+
+func openImportFile(c *gin.Context) (io.ReadCloser, error) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportBytes)
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		file, err := c.FormFile("file")
+		if err != nil {
+			return nil, err
+		}
+		return file.Open()
+	}
+	return c.Request.Body, nil
+}
+
+// csvFieldIndexes maps each header column to the field of t with that JSON name.
+func csvFieldIndexes(t reflect.Type, header []string) ([]int, error) {
+	byName := make(map[string]int)
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" && name != "id" {
+			byName[name] = i
+		}
+	}
+
+	fields := make([]int, len(header))
+	seen := make(map[string]bool)
+	for i, column := range header {
+		column = strings.TrimSpace(strings.TrimPrefix(column, "\ufeff")) // Excel writes a BOM
+		header[i] = column
+		index, ok := byName[column]
+		if !ok {
+			return nil, fmt.Errorf("Unknown CSV column '%s'", column)
+		}
+		if seen[column] {
+			return nil, fmt.Errorf("Duplicate CSV column '%s'", column)
+		}
+		seen[column] = true
+		fields[i] = index
+	}
+	return fields, nil
+}
+
+// decodeCSVRecord sets the fields of dst from one CSV record. Empty cells
+// leave the field at its zero value, as an omitted JSON field would.
+func decodeCSVRecord(dst reflect.Value, header []string, fields []int, record []string) error {
+	for i, cell := range record {
+		cell = strings.TrimSpace(cell)
+		if cell == "" {
+			continue
+		}
+		field := dst.Field(fields[i])
+
+		if field.Type() == timeType {
+			t, err := time.Parse(filterDateLayout, cell)
+			if err != nil {
+				if t, err = time.Parse(time.RFC3339, cell); err != nil {
+					return fmt.Errorf("%s: invalid date '%s', expected YYYY-MM-DD", header[i], cell)
+				}
+			}
+			field.Set(reflect.ValueOf(t))
+			continue
+		}
+
+		switch field.Kind() {
+		case reflect.String:
+			field.SetString(cell)
+		case reflect.Int, reflect.Int32, reflect.Int64:
+			n, err := strconv.ParseInt(cell, 10, 64)
+			if err != nil {
+				return fmt.Errorf("%s: invalid integer '%s'", header[i], cell)
+			}
+			field.SetInt(n)
+		case reflect.Float64:
+			f, err := strconv.ParseFloat(cell, 64)
+			if err != nil {
+				return fmt.Errorf("%s: invalid number '%s'", header[i], cell)
+			}
+			field.SetFloat(f)
+		case reflect.Bool:
+			b, err := strconv.ParseBool(cell)
+			if err != nil {
+				return fmt.Errorf("%s: invalid boolean '%s', expected true or false", header[i], cell)
+			}
+			field.SetBool(b)
+		default:
+			return fmt.Errorf("%s: column cannot be imported", header[i])
+		}
+	}
+	return nil
+}
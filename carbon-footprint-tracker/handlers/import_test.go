@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadImportRow(t *testing.T) {
+	type row struct {
+		record []string
+		line   int
+		err    error
+	}
+	tests := []struct {
+		name string
+		csv  string
+		rows []row
+	}{
+		{
+			name: "valid rows",
+			csv:  "date,location\n2024-06-01,Hall A\n2024-06-02,Hall B\n",
+			rows: []row{{[]string{"2024-06-01", "Hall A"}, 2, nil}, {[]string{"2024-06-02", "Hall B"}, 3, nil}, {nil, 0, io.EOF}},
+		},
+		{
+			name: "quoted value over two lines",
+			csv:  "date,remarks\n2024-06-01,\"first\nsecond\"\n2024-06-02,x\n",
+			rows: []row{{[]string{"2024-06-01", "first\nsecond"}, 2, nil}, {[]string{"2024-06-02", "x"}, 4, nil}},
+		},
+		{
+			name: "wrong number of values",
+			csv:  "date,location\n2024-06-01\n",
+			rows: []row{{[]string{"2024-06-01"}, 2, csv.ErrFieldCount}},
+		},
+		{
+			name: "malformed quote",
+			csv:  "date,location\n2024-06-01,Hall A\n\"x\"y,z\n",
+			rows: []row{{[]string{"2024-06-01", "Hall A"}, 2, nil}, {nil, 3, csv.ErrQuote}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := csv.NewReader(strings.NewReader(tt.csv))
+			reader.TrimLeadingSpace = true
+			if _, err := reader.Read(); err != nil {
+				t.Fatal(err)
+			}
+			for i, want := range tt.rows {
+				record, line, err := readImportRow(reader)
+				if !errors.Is(err, want.err) {
+					t.Fatalf("row %d: err = %v, want %v", i, err, want.err)
+				}
+				var parseErr *csv.ParseError
+				if want.err == csv.ErrQuote && !errors.As(err, &parseErr) {
+					t.Errorf("row %d: err = %T, want *csv.ParseError", i, err)
+				}
+				if line != want.line || !reflect.DeepEqual(record, want.record) {
+					t.Errorf("row %d = %q at line %d, want %q at line %d", i, record, line, want.record, want.line)
+				}
+			}
+		})
+	}
+}
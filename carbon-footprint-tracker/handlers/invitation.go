@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"carbon-footprint-tracker/models"
+	"carbon-footprint-tracker/utils"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultInvitationHours = 72
+	maxInvitationHours     = 30 * 24
+)
+
+type InvitationRequest struct {
+	Email          string `json:"email" binding:"required,email"`
+	Role           string `json:"role" binding:"required"` // Name of a role, see GET /roles
+	ExpiresInHours int    `json:"expires_in_hours"`        // Defaults to 72
+}
+
+// CreateInvitation issues a single-use invitation to the request's
+// organisation. The token is returned only in this response and must be
+// passed to POST /auth/register as invite_token.
+func CreateInvitation(c *gin.Context) {
+	var req InvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !requireRole(c, req.Role) {
+		return
+	}
+	if req.ExpiresInHours == 0 {
+		req.ExpiresInHours = defaultInvitationHours
+	}
+	if req.ExpiresInHours < 1 || req.ExpiresInHours > maxInvitationHours {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expires_in_hours must be between 1 and 720"})
+		return
+	}
+
+	token, err := utils.GenerateSecureToken(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate invitation token", "details": err.Error()})
+		return
+	}
+
+	inv := models.Invitation{
+		Email:     req.Email,
+		Role:      req.Role,
+		OrgID:     requestOrgID(c),
+		InvitedBy: sql.NullInt64{Int64: int64(c.GetInt("userID")), Valid: true},
+		ExpiresAt: time.Now().Add(time.Duration(req.ExpiresInHours) * time.Hour),
+	}
+	if err := inv.Create(token); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invitation", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":    "Invitation created successfully",
+		"id":         inv.ID,
+		"token":      token,
+		"expires_at": inv.ExpiresAt,
+	})
+}
+
+func GetInvitations(c *gin.Context) {
+	invitations, err := models.GetAllInvitations(requestOrgID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve invitations", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, invitations)
+}
+
+// DeleteInvitation revokes an invitation.
+func DeleteInvitation(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	if err := models.DeleteInvitation(requestOrgID(c), id); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Invitation not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete invitation", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invitation deleted successfully"})
+}
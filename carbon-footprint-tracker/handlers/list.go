@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"carbon-footprint-tracker/models"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+// ListResponse is the body returned by the module list endpoints.
+type ListResponse struct {
+	Data     interface{} `json:"data"`
+	Page     int         `json:"page"`
+	PageSize int         `json:"page_size"`
+	Total    int         `json:"total"`
+}
+
+// parsePage reads the page (default 1) and page_size (default 50, at most
+// 500) query parameters.
+func parsePage(c *gin.Context) (page, pageSize int, err error) {
+	page, pageSize = 1, defaultPageSize
+	if v := c.Query("page"); v != "" {
+		page, err = strconv.Atoi(v)
+		if err != nil || page < 1 {
+			return 0, 0, errors.New("Invalid 'page', expected a positive integer")
+		}
+	}
+	if v := c.Query("page_size"); v != "" {
+		pageSize, err = strconv.Atoi(v)
+		if err != nil || pageSize < 1 || pageSize > maxPageSize {
+			return 0, 0, fmt.Errorf("Invalid 'page_size', expected 1 to %d", maxPageSize)
+		}
+	}
+	return page, pageSize, nil
+}
+
+// parseListOptions reads the paging, sorting and filtering query parameters of
+// a list endpoint, on top of the from/to/location filter:
+//
+//	page       1-based page number (default 1)
+//	page_size  rows per page (default 50, at most 500)
+//	sort       a sortable column, prefixed with '-' for descending (default -date)
+//	<column>   exact match on any of the module's filterable columns
+//	include_deleted  'true' to list deleted entries too, which takes models.PermDeletedRecordsManage
+func parseListOptions(c *gin.Context, table string) (models.ListOptions, error) {
+	spec := models.ListSpecs[table]
+	var o models.ListOptions
+
+	filter, err := parseActivityFilter(c)
+	if err != nil {
+		return o, err
+	}
+	o.ActivityFilter = filter
+
+	if v := c.Query("include_deleted"); v != "" {
+		if o.IncludeDeleted, err = strconv.ParseBool(v); err != nil {
+			return o, errors.New("Invalid 'include_deleted', expected true or false")
+		}
+		if o.IncludeDeleted && !hasPermission(c, models.PermDeletedRecordsManage) {
+			return o, errors.New("Listing deleted entries needs the " + models.PermDeletedRecordsManage + " permission")
+		}
+	}
+
+	if o.Page, o.PageSize, err = parsePage(c); err != nil {
+		return o, err
+	}
+
+	if sort := c.Query("sort"); sort != "" {
+		o.Sort = strings.TrimPrefix(sort, "-")
+		o.Desc = strings.HasPrefix(sort, "-")
+		if !spec.CanSort(o.Sort) {
+			return o, fmt.Errorf("Cannot sort by '%s', expected one of %s", o.Sort, strings.Join(spec.Sortable, ", "))
+		}
+	}
+
+	for _, column := range spec.Filterable {
+		if value := c.Query(column); value != "" {
+			if o.Filters == nil {
+				o.Filters = make(map[string]string)
+			}
+			o.Filters[column] = value
+		}
+	}
+	return o, nil
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"carbon-footprint-tracker/models"
+	"database/sql"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// respondTooManyAttempts refuses a login attempt made before until.
+func respondTooManyAttempts(c *gin.Context, message string, until time.Time) {
+	retryAfter := int(math.Ceil(time.Until(until).Seconds()))
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": message, "retry_after": retryAfter})
+}
+
+// recordLoginFailure counts a failed login against the client IP and, when
+// the account exists, against user.
+func recordLoginFailure(c *gin.Context, user *models.User) error {
+	if _, err := models.RecordIPLoginFailure(c.ClientIP()); err != nil {
+		return err
+	}
+	if user != nil {
+		if _, err := models.RecordAccountLoginFailure(user.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordMFAFailure counts a wrong two-factor code of userID as a failed login,
+// and towards the account lockout of models.RecordMFAFailure. It returns the
+// time until which the account is locked if it now is.
+func recordMFAFailure(c *gin.Context, userID int) (sql.NullTime, error) {
+	// First, as the login backoff it sets would replace a lockout.
+	if err := recordLoginFailure(c, &models.User{ID: userID}); err != nil {
+		return sql.NullTime{}, err
+	}
+	return models.RecordMFAFailure(userID)
+}
+
+// UnlockUser lifts a lockout caused by failed logins or two-factor codes.
+func UnlockUser(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	if _, ok := getOrgUser(c, id, "Failed to unlock user"); !ok {
+		return
+	}
+
+	if err := models.UnlockUser(id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlock user", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "User unlocked successfully"})
+}
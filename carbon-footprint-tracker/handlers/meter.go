@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"carbon-footprint-tracker/models"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type MeterRequest struct {
+	MeterNumber   string  `json:"meter_number" binding:"required,max=100"`
+	Location      string  `json:"location" binding:"required,max=255"`
+	Multiplier    float64 `json:"multiplier"`     // Defaults to 1
+	RolloverLimit float64 `json:"rollover_limit"` // Register value that wraps to zero, e.g. 100000; 0 if it doesn't
+	Remarks       string  `json:"remarks"`
+}
+
+// bindMeter reads a MeterRequest into m, responding with 400 and returning
+// false if it is invalid.
+func bindMeter(c *gin.Context, m *models.Meter) bool {
+	var req MeterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	m.MeterNumber = strings.TrimSpace(req.MeterNumber)
+	m.Location = strings.TrimSpace(req.Location)
+	if m.MeterNumber == "" || m.Location == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Meter number and location must not be empty"})
+		return false
+	}
+	if req.Multiplier == 0 {
+		req.Multiplier = 1
+	}
+	if req.Multiplier < 0 || req.RolloverLimit < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Multiplier and rollover limit must not be negative"})
+		return false
+	}
+	m.Multiplier = req.Multiplier
+	m.RolloverLimit = toNullFloat64(req.RolloverLimit)
+	m.Remarks = toNullString(req.Remarks)
+	return true
+}
+
+// meterForRequest returns the meter in the :id parameter, responding with an
+// error and returning nil if it doesn't exist or is outside the user's
+// locations.
+func meterForRequest(c *gin.Context) *models.Meter {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return nil
+	}
+	m, err := models.GetMeterByID(requestOrgID(c), id)
+	if err != nil {
+		if errors.Is(err, models.ErrMeterNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Meter not found"})
+			return nil
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve meter", "details": err.Error()})
+		return nil
+	}
+	if !requireLocation(c, m.Location) {
+		return nil
+	}
+	return m
+}
+
+func GetMeters(c *gin.Context) {
+	meters, err := models.GetAllMeters(requestOrgID(c), locationScope(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve meters", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, meters)
+}
+
+// AddMeter registers an electricity meter, whose readings are then posted to
+// AddMeterReading.
+func AddMeter(c *gin.Context) {
+	m := models.Meter{OrgID: requestOrgID(c)}
+	if !bindMeter(c, &m) || !requireLocation(c, m.Location) {
+		return
+	}
+
+	if err := m.Create(); err != nil {
+		if errors.Is(err, models.ErrMeterExists) {
+			c.JSON(http.StatusConflict, gin.H{"error": "A meter with this number already exists"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add meter", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Meter added successfully", "id": m.ID})
+}
+
+func UpdateMeter(c *gin.Context) {
+	m := meterForRequest(c)
+	if m == nil {
+		return
+	}
+	// Both the current and the new location must be within the user's locations.
+	if !bindMeter(c, m) || !requireLocation(c, m.Location) {
+		return
+	}
+
+	if err := m.Update(); err != nil {
+		switch {
+		case errors.Is(err, models.ErrMeterNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Meter not found"})
+		case errors.Is(err, models.ErrMeterExists):
+			c.JSON(http.StatusConflict, gin.H{"error": "A meter with this number already exists"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update meter", "details": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Meter updated successfully"})
+}
+
+// DeleteMeter deletes a meter registered by mistake. Meters with readings
+// are kept, so that the consumption derived from them stays traceable.
+func DeleteMeter(c *gin.Context) {
+	m := meterForRequest(c)
+	if m == nil {
+		return
+	}
+
+	if err := models.DeleteMeter(requestOrgID(c), m.ID); err != nil {
+		switch {
+		case errors.Is(err, models.ErrMeterNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Meter not found"})
+		case errors.Is(err, models.ErrMeterInUse):
+			c.JSON(http.StatusConflict, gin.H{"error": "Meter has readings and can't be deleted"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete meter", "details": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Meter deleted successfully"})
+}
+
+type MeterReadingRequest struct {
+	Date    time.Time `json:"date"`                       // Defaults to today
+	Reading *float64  `json:"reading" binding:"required"` // Cumulative register reading
+
+	// When the meter was replaced since the previous reading: the old
+	// register's final reading and the new register's reading when fitted,
+	// which defaults to 0.
+	ReplacedFinalReading *float64 `json:"replaced_final_reading"`
+	ReplacedStartReading *float64 `json:"replaced_start_reading"`
+}
+
+func toNullFloat64Ptr(f *float64) sql.NullFloat64 {
+	if f == nil {
+		return sql.NullFloat64{}
+	}
+	return sql.NullFloat64{Float64: *f, Valid: true}
+}
+
+func GetMeterReadings(c *gin.Context) {
+	m := meterForRequest(c)
+	if m == nil {
+		return
+	}
+
+	readings, err := models.GetMeterReadings(requestOrgID(c), m.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve meter readings", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, readings)
+}
+
+// AddMeterReading records a cumulative reading of a meter, see
+// models.RecordMeterReading. The response holds the consumption computed
+// since the previous reading, whether the register rolled over and any flag.
+func AddMeterReading(c *gin.Context) {
+	m := meterForRequest(c)
+	if m == nil {
+		return
+	}
+
+	var req MeterReadingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Date.IsZero() {
+		req.Date = time.Now()
+	}
+	for _, value := range []*float64{req.Reading, req.ReplacedFinalReading, req.ReplacedStartReading} {
+		if value != nil && *value < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Readings must not be negative"})
+			return
+		}
+	}
+
+	r := models.MeterReading{
+		MeterID:              m.ID,
+		Date:                 req.Date,
+		Reading:              *req.Reading,
+		ReplacedFinalReading: toNullFloat64Ptr(req.ReplacedFinalReading),
+		ReplacedStartReading: toNullFloat64Ptr(req.ReplacedStartReading),
+	}
+	if err := models.RecordMeterReading(requestActor(c), &r); err != nil {
+		switch {
+		case errors.Is(err, models.ErrMeterNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Meter not found"})
+		case errors.Is(err, models.ErrReadingOutOfOrder):
+			c.JSON(http.StatusConflict, gin.H{"error": "The meter has a later reading, readings must be recorded in date order"})
+		case errors.Is(err, models.ErrReplacementIncomplete):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "replaced_final_reading is required with replaced_start_reading"})
+		case errors.Is(err, models.ErrReplacementBackwards):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "The replaced meter's final reading must not be below the previous reading, nor the new meter's reading below its start reading"})
+		case errors.Is(err, models.ErrReadingOverLimit):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Readings must be below the meter's rollover limit"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add meter reading", "details": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Meter reading added successfully", "reading": r})
+}
@@ -0,0 +1,400 @@
+package handlers
+
+import (
+	"carbon-footprint-tracker/config"
+	"carbon-footprint-tracker/models"
+	"carbon-footprint-tracker/utils"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	mfaIssuer         = "Carbon Footprint Tracker" // Shown in authenticator apps
+	recoveryCodeCount = 10
+)
+
+type MFACodeRequest struct {
+	Code string `json:"code" binding:"required"` // TOTP code, or a recovery code where accepted
+}
+
+type DisableMFARequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+type MFALoginRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+// verifyMFACode checks a TOTP code, or failing that a recovery code, for
+// userID. Each TOTP code and recovery code is accepted only once.
+func verifyMFACode(userID int, secret, code string) (bool, error) {
+	if step, ok := utils.ValidateTOTP(secret, code, time.Now()); ok {
+		return models.AcceptTOTPStep(userID, step)
+	}
+	return models.UseRecoveryCode(userID, code)
+}
+
+// respondWithMFAChallenge finishes the password step of a login for a user
+// with two-factor authentication. Instead of tokens, the client gets a
+// short-lived challenge token to send back with a code to VerifyMFALogin.
+// Failure counters are left alone until the code is accepted, so that a
+// correct password doesn't give a fresh round of code guesses.
+func respondWithMFAChallenge(c *gin.Context, user *models.User) {
+	token, _, err := utils.GenerateMFAChallenge(user.ID, user.TokenVersion)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":      "Two-factor authentication code required",
+		"mfa_required": true,
+		"mfa_token":    token,
+		"expires_in":   int(utils.MFAChallengeTTL.Seconds()),
+	})
+}
+
+// VerifyMFALogin completes a login with the challenge token from LoginUser and
+// a TOTP or recovery code. A challenge can be used once. Every wrong code
+// counts as a failed login, so codes are throttled like wrong passwords, and
+// models.MFALockoutAttempts of them lock the account.
+func VerifyMFALogin(c *gin.Context) {
+	var req MFALoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	claims, err := utils.ParseMFAChallenge(req.MFAToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Two-factor challenge is invalid or expired, please log in again"})
+		return
+	}
+	challengeExpiresAt := time.Unix(claims.ExpiresAt, 0)
+
+	state, err := models.GetTokenState(claims.UserID, claims.Id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Two-factor challenge is invalid or expired, please log in again"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify code", "details": err.Error()})
+		return
+	}
+	if state.Revoked || state.TokenVersion != claims.TokenVersion || !state.MFAEnabled {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Two-factor challenge is invalid or expired, please log in again"})
+		return
+	}
+
+	mfa, err := models.GetMFAState(claims.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify code", "details": err.Error()})
+		return
+	}
+	if mfa.LockedUntil.Valid && time.Now().Before(mfa.LockedUntil.Time) {
+		respondTooManyAttempts(c, "Account is temporarily locked after failed login attempts, try again later", mfa.LockedUntil.Time)
+		return
+	}
+
+	ok, err := verifyMFACode(claims.UserID, mfa.Secret.String, req.Code)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify code", "details": err.Error()})
+		return
+	}
+	if !ok {
+		lockedUntil, err := recordMFAFailure(c, claims.UserID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify code", "details": err.Error()})
+			return
+		}
+		if lockedUntil.Valid {
+			if err := models.RevokeAccessToken(claims.Id, challengeExpiresAt); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify code", "details": err.Error()})
+				return
+			}
+			respondTooManyAttempts(c, "Too many wrong codes, account is temporarily locked", lockedUntil.Time)
+			return
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid two-factor authentication code"})
+		return
+	}
+
+	// The challenge is spent; challenge jtis share the access token denylist.
+	if err := models.RevokeAccessToken(claims.Id, challengeExpiresAt); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to login", "details": err.Error()})
+		return
+	}
+	if err := models.ClearLoginFailures(claims.UserID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to login", "details": err.Error()})
+		return
+	}
+
+	user, err := models.GetUserByID(claims.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to login", "details": err.Error()})
+		return
+	}
+	refreshToken, err := models.IssueRefreshToken(config.DB, user.ID, "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token", "details": err.Error()})
+		return
+	}
+	if err := models.RecordLogin(user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to login", "details": err.Error()})
+		return
+	}
+
+	respondWithTokens(c, "Login successful", user, refreshToken)
+}
+
+// SetupMFA starts TOTP enrollment for the logged in user. The secret and its
+// otpauth:// URI (to show as a QR code) are returned; enrollment completes
+// once EnableMFA receives a code generated from them.
+func SetupMFA(c *gin.Context) {
+	user, err := models.GetUserByID(c.GetInt("userID"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set up two-factor authentication", "details": err.Error()})
+		return
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set up two-factor authentication", "details": err.Error()})
+		return
+	}
+	if err := models.StartMFAEnrollment(user.ID, secret); err != nil {
+		if errors.Is(err, models.ErrMFAAlreadyEnabled) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set up two-factor authentication", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"secret":      secret,
+		"otpauth_uri": utils.TOTPProvisioningURI(mfaIssuer, user.Email, secret),
+	})
+}
+
+// EnableMFA confirms enrollment with a code from the user's authenticator app
+// and returns their recovery codes. They are shown only this once.
+func EnableMFA(c *gin.Context) {
+	var req MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID := c.GetInt("userID")
+
+	mfa, err := models.GetMFAState(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable two-factor authentication", "details": err.Error()})
+		return
+	}
+	if mfa.Enabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+	if !mfa.Secret.Valid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor setup has not been started"})
+		return
+	}
+	step, ok := utils.ValidateTOTP(mfa.Secret.String, req.Code, time.Now())
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid two-factor authentication code"})
+		return
+	}
+
+	codes, err := utils.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable two-factor authentication", "details": err.Error()})
+		return
+	}
+	if err := models.EnableMFA(userID, step, codes); err != nil {
+		if errors.Is(err, models.ErrMFAAlreadyEnabled) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable two-factor authentication", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication enabled", "recovery_codes": codes})
+}
+
+// DisableMFA turns off two-factor authentication for the logged in user,
+// given their password and a current code. Users whose role requires it
+// can't turn it off. Wrong passwords and codes count as failed logins, see
+// VerifyMFALogin, so that a stolen access token isn't enough to guess them.
+func DisableMFA(c *gin.Context) {
+	var req DisableMFARequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID := c.GetInt("userID")
+
+	if config.MFARequired(c.GetString("userRole")) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Two-factor authentication is required for your role"})
+		return
+	}
+
+	mfa, err := models.GetMFAState(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable two-factor authentication", "details": err.Error()})
+		return
+	}
+	if !mfa.Enabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+		return
+	}
+	if mfa.LockedUntil.Valid && time.Now().Before(mfa.LockedUntil.Time) {
+		respondTooManyAttempts(c, "Account is temporarily locked after failed login attempts, try again later", mfa.LockedUntil.Time)
+		return
+	}
+	hash, err := models.GetPasswordHash(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable two-factor authentication", "details": err.Error()})
+		return
+	}
+	if !utils.CheckPasswordHash(req.Password, hash) {
+		if err := recordLoginFailure(c, &models.User{ID: userID}); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable two-factor authentication", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Password is incorrect"})
+		return
+	}
+	ok, err := verifyMFACode(userID, mfa.Secret.String, req.Code)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable two-factor authentication", "details": err.Error()})
+		return
+	}
+	if !ok {
+		lockedUntil, err := recordMFAFailure(c, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable two-factor authentication", "details": err.Error()})
+			return
+		}
+		if lockedUntil.Valid {
+			respondTooManyAttempts(c, "Too many wrong codes, account is temporarily locked", lockedUntil.Time)
+			return
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid two-factor authentication code"})
+		return
+	}
+
+	if err := models.DisableMFA(userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable two-factor authentication", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of the logged in user,
+// given a current TOTP code, and returns the new ones. Wrong codes are
+// throttled as in DisableMFA.
+func RegenerateRecoveryCodes(c *gin.Context) {
+	var req MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID := c.GetInt("userID")
+
+	mfa, err := models.GetMFAState(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to regenerate recovery codes", "details": err.Error()})
+		return
+	}
+	if !mfa.Enabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+		return
+	}
+	if mfa.LockedUntil.Valid && time.Now().Before(mfa.LockedUntil.Time) {
+		respondTooManyAttempts(c, "Account is temporarily locked after failed login attempts, try again later", mfa.LockedUntil.Time)
+		return
+	}
+	step, ok := utils.ValidateTOTP(mfa.Secret.String, req.Code, time.Now())
+	if ok {
+		ok, err = models.AcceptTOTPStep(userID, step)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to regenerate recovery codes", "details": err.Error()})
+			return
+		}
+	}
+	if !ok {
+		lockedUntil, err := recordMFAFailure(c, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to regenerate recovery codes", "details": err.Error()})
+			return
+		}
+		if lockedUntil.Valid {
+			respondTooManyAttempts(c, "Too many wrong codes, account is temporarily locked", lockedUntil.Time)
+			return
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid two-factor authentication code"})
+		return
+	}
+
+	codes, err := utils.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to regenerate recovery codes", "details": err.Error()})
+		return
+	}
+	if err := models.ReplaceRecoveryCodes(userID, codes); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to regenerate recovery codes", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Recovery codes regenerated", "recovery_codes": codes})
+}
+
+// GetMFAStatus reports whether the logged in user has two-factor
+// authentication, whether their role requires it, and how many recovery
+// codes they have left.
+func GetMFAStatus(c *gin.Context) {
+	userID := c.GetInt("userID")
+	mfa, err := models.GetMFAState(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get two-factor status", "details": err.Error()})
+		return
+	}
+	remaining, err := models.CountRecoveryCodes(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get two-factor status", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":                  mfa.Enabled,
+		"required":                 config.MFARequired(c.GetString("userRole")),
+		"recovery_codes_remaining": remaining,
+	})
+}
+
+// ResetUserMFA turns off two-factor authentication for a user who has lost
+// their authenticator and recovery codes. If their role requires it, they
+// will have to set it up again on their next login.
+func ResetUserMFA(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	if _, ok := getOrgUser(c, id, "Failed to reset two-factor authentication"); !ok {
+		return
+	}
+
+	if err := models.DisableMFA(id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset two-factor authentication", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication reset successfully"})
+}
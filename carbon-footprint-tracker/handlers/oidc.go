@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"carbon-footprint-tracker/config"
+	"carbon-footprint-tracker/models"
+	"carbon-footprint-tracker/oidc"
+	"carbon-footprint-tracker/utils"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+var oidcProvider *oidc.Provider
+
+// SetOIDCProvider enables single sign-on through p. Without it the
+// /auth/oidc routes respond with 404.
+func SetOIDCProvider(p *oidc.Provider) {
+	oidcProvider = p
+}
+
+// oidcLoginCookie keeps the signed login state, see utils.OIDCLoginClaims,
+// while the user is at the identity provider.
+const oidcLoginCookie = "oidc_login"
+
+func requireOIDC(c *gin.Context) bool {
+	if oidcProvider == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Single sign-on is not configured"})
+		return false
+	}
+	return true
+}
+
+func setOIDCLoginCookie(c *gin.Context, value string, maxAge int) {
+	c.SetSameSite(http.SameSiteLaxMode) // Sent along on the provider's redirect back
+	c.SetCookie(oidcLoginCookie, value, maxAge, "/auth/oidc", "", strings.HasPrefix(oidcProvider.RedirectURL, "https://"), true)
+}
+
+// OIDCLogin sends the browser to the identity provider to sign in, which
+// returns it to OIDCCallback.
+func OIDCLogin(c *gin.Context) {
+	if !requireOIDC(c) {
+		return
+	}
+
+	login, signed, err := utils.GenerateOIDCLogin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start single sign-on", "details": err.Error()})
+		return
+	}
+	authURL, err := oidcProvider.AuthCodeURL(c.Request.Context(), login.State, login.Nonce, login.Verifier)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Identity provider is unavailable", "details": err.Error()})
+		return
+	}
+
+	setOIDCLoginCookie(c, signed, int(utils.OIDCLoginTTL.Seconds()))
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// OIDCCallback completes a single sign-on. The user's groups decide their
+// role, see oidc.Config.Role; users signing in for the first time are
+// created. The tokens are sent on to config.SSORedirectURL in the URL
+// fragment, or returned like LoginUser's when it isn't set.
+func OIDCCallback(c *gin.Context) {
+	if !requireOIDC(c) {
+		return
+	}
+
+	signed, err := c.Cookie(oidcLoginCookie)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No single sign-on in progress, please start again"})
+		return
+	}
+	setOIDCLoginCookie(c, "", -1)
+	login, err := utils.ParseOIDCLogin(signed)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Single sign-on has expired, please start again"})
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(c.Query("state")), []byte(login.State)) != 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Single sign-on state doesn't match, please start again"})
+		return
+	}
+	if reason := c.Query("error"); reason != "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Identity provider refused the sign-in", "details": fmt.Sprintf("%s: %s", reason, c.Query("error_description"))})
+		return
+	}
+	code := c.Query("code")
+	if code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing authorization code"})
+		return
+	}
+
+	identity, err := oidcProvider.Exchange(c.Request.Context(), code, login.Verifier, login.Nonce)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to verify the sign-in with the identity provider", "details": err.Error()})
+		return
+	}
+	role := oidcProvider.Role(identity.Groups)
+	if role == "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Your account isn't permitted to use this application"})
+		return
+	}
+
+	user, err := models.ProvisionOIDCUser(models.OIDCLogin{
+		Subject:       identity.Subject,
+		Email:         identity.Email,
+		EmailVerified: identity.EmailVerified,
+		Name:          identity.Name,
+		Role:          role,
+	})
+	if err != nil {
+		if errors.Is(err, models.ErrOIDCEmailInUse) {
+			c.JSON(http.StatusConflict, gin.H{"error": "An account with this email address already exists and can't be linked to this sign-in"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign in", "details": err.Error()})
+		return
+	}
+
+	refreshToken, err := models.IssueRefreshToken(config.DB, user.ID, "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token", "details": err.Error()})
+		return
+	}
+	if err := models.RecordLogin(user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign in", "details": err.Error()})
+		return
+	}
+
+	if config.SSORedirectURL == "" {
+		respondWithTokens(c, "Login successful", user, refreshToken)
+		return
+	}
+	response, err := tokenResponse(user, refreshToken)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token", "details": err.Error()})
+		return
+	}
+	locations, err := json.Marshal(response["locations"])
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token", "details": err.Error()})
+		return
+	}
+	fragment := url.Values{"locations": {string(locations)}} // JSON, null if unrestricted
+	for _, key := range []string{"token", "expires_in", "refresh_token", "role", "org_id"} {
+		fragment.Set(key, fmt.Sprint(response[key]))
+	}
+	c.Redirect(http.StatusFound, config.SSORedirectURL+"#"+fragment.Encode())
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"carbon-footprint-tracker/models"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type OrganisationRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+func GetOrganisations(c *gin.Context) {
+	organisations, err := models.GetAllOrganisations()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve organisations", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, organisations)
+}
+
+// AddOrganisation creates an empty organisation. Its first admin is invited
+// by a super-admin acting within it, see middleware.OrganisationHeader.
+func AddOrganisation(c *gin.Context) {
+	var req OrganisationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Organisation name must not be empty"})
+		return
+	}
+
+	org := models.Organisation{Name: name}
+	if err := org.Create(); err != nil {
+		if errors.Is(err, models.ErrOrganisationExists) {
+			c.JSON(http.StatusConflict, gin.H{"error": "An organisation with this name already exists"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add organisation", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Organisation added successfully", "id": org.ID})
+}
+
+// UpdateOrganisation renames an organisation.
+func UpdateOrganisation(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var req OrganisationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Organisation name must not be empty"})
+		return
+	}
+
+	org := models.Organisation{ID: id, Name: name}
+	if err := org.Update(); err != nil {
+		switch {
+		case errors.Is(err, models.ErrOrganisationNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Organisation not found"})
+		case errors.Is(err, models.ErrOrganisationExists):
+			c.JSON(http.StatusConflict, gin.H{"error": "An organisation with this name already exists"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update organisation", "details": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Organisation updated successfully"})
+}
+
+// DeleteOrganisation deletes an organisation. Its users and records have to
+// be deleted first, so that no data is lost by accident.
+func DeleteOrganisation(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+	if id == requestOrgID(c) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The organisation you are acting in can't be deleted"})
+		return
+	}
+
+	if err := models.DeleteOrganisation(id); err != nil {
+		switch {
+		case errors.Is(err, models.ErrOrganisationNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Organisation not found"})
+		case errors.Is(err, models.ErrOrganisationInUse):
+			c.JSON(http.StatusConflict, gin.H{"error": "Organisation still has users or data, delete them first"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete organisation", "details": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Organisation deleted successfully"})
+}
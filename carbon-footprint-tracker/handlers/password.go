@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"carbon-footprint-tracker/config"
+	"carbon-footprint-tracker/mailer"
+	"carbon-footprint-tracker/models"
+	"carbon-footprint-tracker/utils"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+)
+
+var mail mailer.Mailer = mailer.LogMailer{}
+
+// SetMailer sets the mailer used to deliver password reset emails. It
+// defaults to writing them to the log.
+func SetMailer(m mailer.Mailer) {
+	mail = m
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+// ChangePassword sets a new password for the logged in user. Every session of
+// the user is ended, and the caller gets a fresh pair of tokens to carry on.
+func ChangePassword(c *gin.Context) {
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := models.GetUserByID(c.GetInt("userID"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change password", "details": err.Error()})
+		return
+	}
+	if user.SSO() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "This account signs in through the identity provider and has no password"})
+		return
+	}
+	hash, err := models.GetPasswordHash(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change password", "details": err.Error()})
+		return
+	}
+	if !utils.CheckPasswordHash(req.CurrentPassword, hash) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Current password is incorrect"})
+		return
+	}
+	if req.NewPassword == req.CurrentPassword {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "New password must differ from the current password"})
+		return
+	}
+	if err := utils.ValidatePasswordStrength(req.NewPassword, user.Name, user.Email); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := user.UpdatePassword(req.NewPassword); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change password", "details": err.Error()})
+		return
+	}
+	refreshToken, err := models.IssueRefreshToken(config.DB, user.ID, "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token", "details": err.Error()})
+		return
+	}
+
+	respondWithTokens(c, "Password changed successfully", user, refreshToken)
+}
+
+// ForgotPassword emails a reset token to the given address. The response is
+// the same whether or not an account exists, so that it can't be used to
+// find out which addresses are registered.
+func ForgotPassword(c *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := gin.H{"message": "If an account exists for that email, a password reset link has been sent"}
+
+	user, err := models.GetUserByEmail(req.Email)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusOK, response)
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to request password reset", "details": err.Error()})
+		return
+	}
+	if user.SSO() {
+		c.JSON(http.StatusOK, response) // Passwords of single sign-on users are managed by the identity provider
+		return
+	}
+
+	token, err := models.CreatePasswordReset(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to request password reset", "details": err.Error()})
+		return
+	}
+
+	// Sent in the background so that response times don't give away
+	// whether the account exists.
+	msg := passwordResetMessage(user, token)
+	go func() {
+		if err := mail.Send(msg); err != nil {
+			log.Printf("Failed to send password reset email to user %d: %v", user.ID, err)
+		}
+	}()
+
+	c.JSON(http.StatusOK, response)
+}
+
+func passwordResetMessage(user *models.User, token string) mailer.Message {
+	link := "Use this token to reset your password: " + token
+	if config.PasswordResetURL != "" {
+		link = "Reset your password here: " + config.PasswordResetURL + "?token=" + url.QueryEscape(token)
+	}
+	return mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your Carbon Footprint Tracker password",
+		Body: fmt.Sprintf("Hello %s,\n\nA password reset was requested for your account.\n\n%s\n\n"+
+			"This expires in %d minutes. If you did not request it, you can ignore this email.\n",
+			user.Name, link, int(models.PasswordResetTTL.Minutes())),
+	}
+}
+
+// ResetPassword sets a new password using an emailed reset token. Every
+// session of the user is ended, so they have to log in again.
+func ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := models.GetPasswordResetUser(req.Token)
+	if err != nil {
+		if errors.Is(err, models.ErrPasswordResetInvalid) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Reset token is invalid, expired or already used"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password", "details": err.Error()})
+		return
+	}
+	if err := utils.ValidatePasswordStrength(req.NewPassword, user.Name, user.Email); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := models.ResetPassword(req.Token, req.NewPassword); err != nil {
+		if errors.Is(err, models.ErrPasswordResetInvalid) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Reset token is invalid, expired or already used"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset successfully, please log in again"})
+}
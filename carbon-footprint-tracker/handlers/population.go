@@ -17,6 +17,22 @@ func AddPopulation(c *gin.Context) {
 		return
 	}
 
+	p := newPopulation(req)
+
+	if !requireLocation(c, p.Location) {
+		return
+	}
+
+	if err := p.Create(requestActor(c)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add population stats", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Population stats added successfully", "id": p.ID})
+}
+
+// newPopulation fills in the defaults for omitted fields of req.
+func newPopulation(req models.Population) models.Population {
 	if req.Location == "" {
 		req.Location = "Overall"
 	}
@@ -24,22 +40,38 @@ func AddPopulation(c *gin.Context) {
 	if req.Date.IsZero() {
 		req.Date = time.Now()
 	}
+	return req
+}
 
-	if err := req.Create(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add population stats", "details": err.Error()})
-		return
-	}
+// ImportPopulation creates population stats from a CSV upload, see importCSV.
+func ImportPopulation(c *gin.Context) {
+	importCSV(c, "population stats", func(db models.Querier, req models.Population) error {
+		p := newPopulation(req)
+		if !inLocationScope(c, p.Location) {
+			return errOutsideLocations(p.Location)
+		}
+		return p.CreateWith(db, requestActor(c))
+	})
+}
 
-	c.JSON(http.StatusCreated, gin.H{"message": "Population stats added successfully", "id": req.ID})
+// ExportPopulation streams population stats as CSV or XLSX, see exportModule.
+func ExportPopulation(c *gin.Context) {
+	exportModule(c, models.TablePopulation)
 }
 
 func GetPopulationStats(c *gin.Context) {
-	populations, err := models.GetAllPopulations()
+	opts, err := parseListOptions(c, models.TablePopulation)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	populations, total, err := models.ListPopulations(opts)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve population stats", "details": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, populations)
+	c.JSON(http.StatusOK, ListResponse{Data: populations, Page: opts.Page, PageSize: opts.PageSize, Total: total})
 }
 
 func UpdatePopulation(c *gin.Context) {
@@ -50,7 +82,7 @@ func UpdatePopulation(c *gin.Context) {
 		return
 	}
 
-	p, err := models.GetPopulationByID(id)
+	p, err := models.GetPopulationByID(requestOrgID(c), id)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Population stats not found"})
@@ -60,6 +92,8 @@ func UpdatePopulation(c *gin.Context) {
 		return
 	}
 
+	location := p.Location
+
 	var req models.Population
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -79,7 +113,15 @@ func UpdatePopulation(c *gin.Context) {
 		p.Location = req.Location
 	}
 
-	if err := p.Update(); err != nil {
+	if !requireLocationChange(c, location, p.Location) {
+		return
+	}
+
+	if err := p.Update(requestActor(c)); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Population stats not found"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update population stats", "details": err.Error()})
 		return
 	}
@@ -95,10 +137,23 @@ func DeletePopulation(c *gin.Context) {
 		return
 	}
 
-	if err := models.DeletePopulation(id); err != nil {
+	if !requireRecordLocation(c, models.TablePopulation, id) {
+		return
+	}
+
+	if err := models.DeletePopulation(requestActor(c), id); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Population stats not found"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete population stats", "details": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Population stats deleted successfully"})
 }
+
+// RestorePopulation undeletes population stats, see restoreRecord.
+func RestorePopulation(c *gin.Context) {
+	restoreRecord(c, models.TablePopulation, "population stats")
+}
@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"carbon-footprint-tracker/emissions"
+	"carbon-footprint-tracker/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetScopeReport returns GHG Protocol Scope 1/2/3 totals, with Scope 3 split
+// by category, for the from/to/location period. They are summed from the
+// footprints stored on each record, so they use the factors those were
+// computed with.
+func GetScopeReport(c *gin.Context) {
+	filter, err := parseActivityFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	totals, err := models.SumScopeFootprints(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get scope totals for scope report", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, emissions.ScopeTotals(totals))
+}
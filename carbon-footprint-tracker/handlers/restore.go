@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"carbon-footprint-tracker/models"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// restoreRecord undeletes the record with the id in the path from table.
+// Deleted records can be restored until they are purged, see
+// config.DeletedRetentionDays. name is what the module calls a record.
+func restoreRecord(c *gin.Context, table, name string) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	if !requireRecordLocation(c, table, id) {
+		return
+	}
+
+	restored, err := models.RestoreRecord(requestActor(c), table, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore " + name, "details": err.Error()})
+		return
+	}
+	if !restored {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No deleted " + name + " with this ID"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": strings.ToUpper(name[:1]) + name[1:] + " restored successfully"})
+}
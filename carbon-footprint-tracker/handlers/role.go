@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"carbon-footprint-tracker/models"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+)
+
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{1,49}$`)
+
+type RoleRequest struct {
+	Name        string   `json:"name"` // Only when creating; lowercase letters, digits and underscores
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions" binding:"required"`
+}
+
+// validatePermissions checks that every permission is known and can be
+// granted to a custom role.
+func validatePermissions(permissions []string) error {
+	for _, p := range permissions {
+		if !models.IsValidPermission(p) {
+			return fmt.Errorf("Unknown permission '%s'", p)
+		}
+		if models.IsSuperAdminPermission(p) {
+			return fmt.Errorf("Permission '%s' is reserved for super-admins", p)
+		}
+	}
+	return nil
+}
+
+// requireRole responds with 400 and returns false if role doesn't exist, and
+// with 403 if it grants a permission the user doesn't hold, so that nobody can
+// hand out more than they have themselves.
+func requireRole(c *gin.Context, role string) bool {
+	r, err := models.GetRole(role)
+	if err != nil {
+		if errors.Is(err, models.ErrRoleNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role specified"})
+			return false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check role", "details": err.Error()})
+		return false
+	}
+	for _, p := range r.Permissions {
+		if !hasPermission(c, p) {
+			c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("You can't grant role '%s', which holds permission '%s' that you don't", role, p)})
+			return false
+		}
+	}
+	return true
+}
+
+func GetRoles(c *gin.Context) {
+	roles, err := models.GetAllRoles()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve roles", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, roles)
+}
+
+// GetPermissions lists every permission that can be granted to a role.
+func GetPermissions(c *gin.Context) {
+	c.JSON(http.StatusOK, models.Permissions)
+}
+
+func AddRole(c *gin.Context) {
+	var req RoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !roleNamePattern.MatchString(req.Name) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role name, expected 2 to 50 lowercase letters, digits or underscores"})
+		return
+	}
+	if err := validatePermissions(req.Permissions); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	role := models.Role{
+		Name:        req.Name,
+		Description: toNullString(req.Description),
+		Permissions: req.Permissions,
+	}
+	if err := role.Create(); err != nil {
+		if errors.Is(err, models.ErrRoleExists) {
+			c.JSON(http.StatusConflict, gin.H{"error": "A role with that name already exists"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add role", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Role added successfully", "name": role.Name})
+}
+
+// UpdateRole replaces the description and permissions of a role. Changes
+// apply to its users from their next request. The admin role always keeps
+// every permission.
+func UpdateRole(c *gin.Context) {
+	name := c.Param("name")
+
+	var req RoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Name != "" && req.Name != name {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Roles can't be renamed"})
+		return
+	}
+	if name == models.RoleSuperAdmin || name == models.RoleAdmin {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The permissions of the " + name + " role are fixed"})
+		return
+	}
+	if err := validatePermissions(req.Permissions); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	role := models.Role{
+		Name:        name,
+		Description: toNullString(req.Description),
+		Permissions: req.Permissions,
+	}
+	if err := role.Update(); err != nil {
+		if errors.Is(err, models.ErrRoleNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Role updated successfully"})
+}
+
+// DeleteRole deletes a custom role. Roles still held by users, and the
+// built-in roles, can't be deleted.
+func DeleteRole(c *gin.Context) {
+	if err := models.DeleteRole(c.Param("name")); err != nil {
+		switch {
+		case errors.Is(err, models.ErrRoleNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+		case errors.Is(err, models.ErrSystemRole):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Built-in roles can't be deleted"})
+		case errors.Is(err, models.ErrRoleInUse):
+			c.JSON(http.StatusConflict, gin.H{"error": "Role is assigned to users, give them another role first"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete role", "details": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Role deleted successfully"})
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"carbon-footprint-tracker/models"
+	"database/sql"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requestOrgID returns the organisation the request acts in, which every
+// query it makes must be limited to.
+func requestOrgID(c *gin.Context) int {
+	return c.GetInt("orgID")
+}
+
+// requestActor returns who makes the request's changes, for the audit log.
+func requestActor(c *gin.Context) models.Actor {
+	return models.Actor{OrgID: requestOrgID(c), UserID: c.GetInt("userID"), APIKeyID: c.GetInt("apiKeyID")}
+}
+
+// locationScope returns the locations the user is limited to, or nil if they
+// can access every location.
+func locationScope(c *gin.Context) []string {
+	locations, _ := c.Get("userLocations")
+	scope, _ := locations.([]string)
+	return scope
+}
+
+// inLocationScope reports whether the user may access records at location.
+func inLocationScope(c *gin.Context, location string) bool {
+	scope := locationScope(c)
+	if scope == nil {
+		return true
+	}
+	for _, l := range scope {
+		if l == location {
+			return true
+		}
+	}
+	return false
+}
+
+func errOutsideLocations(location string) error {
+	return fmt.Errorf("Location '%s' is outside your assigned locations", location)
+}
+
+// requireLocation responds with 403 and returns false if location is outside
+// the user's locations.
+func requireLocation(c *gin.Context, location string) bool {
+	if inLocationScope(c, location) {
+		return true
+	}
+	c.JSON(http.StatusForbidden, gin.H{"error": errOutsideLocations(location).Error()})
+	return false
+}
+
+// requireLocationChange is requireLocation for a record being updated from
+// location current to updated: both must be within the user's locations, so
+// that records can be neither edited at nor moved to other locations.
+func requireLocationChange(c *gin.Context, current, updated string) bool {
+	return requireLocation(c, current) && requireLocation(c, updated)
+}
+
+// requireRecordLocation is requireLocation for the stored record with id in
+// table. It responds with 404 if a limited user asks for a record that
+// doesn't exist; for other users nothing is looked up.
+func requireRecordLocation(c *gin.Context, table string, id int) bool {
+	if locationScope(c) == nil {
+		return true
+	}
+	location, err := models.RecordLocation(requestOrgID(c), table, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Entry not found"})
+			return false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check entry location", "details": err.Error()})
+		return false
+	}
+	return requireLocation(c, location)
+}
+
+// hasPermission reports whether the user's role grants permission, for
+// options of an endpoint that need more than the route requires.
+func hasPermission(c *gin.Context, permission string) bool {
+	granted, _ := c.Get("userPermissions")
+	permissions, _ := granted.([]string)
+	for _, p := range permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
@@ -32,6 +32,22 @@ func AddTransportData(c *gin.Context) {
 		return
 	}
 
+	t := newTransport(req)
+
+	if !requireLocation(c, t.EventAreaLocation) {
+		return
+	}
+
+	if err := t.Create(requestActor(c)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add transport data", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Transport data added successfully", "id": t.ID})
+}
+
+// newTransport builds a transport entry from req, filling in the defaults for omitted fields.
+func newTransport(req TransportRequest) models.Transport {
 	if req.EventAreaLocation == "" {
 		req.EventAreaLocation = "Overall"
 	}
@@ -39,7 +55,7 @@ func AddTransportData(c *gin.Context) {
 		req.Date = time.Now()
 	}
 
-	t := models.Transport{
+	return models.Transport{
 		Date:                     req.Date,
 		EventAreaLocation:        req.EventAreaLocation,
 		VehicleType:              req.VehicleType,
@@ -53,22 +69,41 @@ func AddTransportData(c *gin.Context) {
 		FuelEfficiencyKMPerLiter: toNullFloat64(req.FuelEfficiencyKMPerLiter),
 		Remarks:                  toNullString(req.Remarks),
 	}
+}
 
-	if err := t.Create(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add transport data", "details": err.Error()})
+// ImportTransportData creates transport data from a CSV upload, see importCSV.
+func ImportTransportData(c *gin.Context) {
+	footprinter, ok := importFootprinter(c)
+	if !ok {
 		return
 	}
+	importCSV(c, "transport data", func(db models.Querier, req TransportRequest) error {
+		t := newTransport(req)
+		if !inLocationScope(c, t.EventAreaLocation) {
+			return errOutsideLocations(t.EventAreaLocation)
+		}
+		return t.CreateWith(db, requestActor(c), footprinter)
+	})
+}
 
-	c.JSON(http.StatusCreated, gin.H{"message": "Transport data added successfully", "id": t.ID})
+// ExportTransportData streams transport data as CSV or XLSX, see exportModule.
+func ExportTransportData(c *gin.Context) {
+	exportModule(c, models.TableTransport)
 }
 
 func GetTransportData(c *gin.Context) {
-	transports, err := models.GetAllTransports()
+	opts, err := parseListOptions(c, models.TableTransport)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	transports, total, err := models.ListTransports(opts)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve transport data", "details": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, transports)
+	c.JSON(http.StatusOK, ListResponse{Data: transports, Page: opts.Page, PageSize: opts.PageSize, Total: total})
 }
 
 func UpdateTransportData(c *gin.Context) {
@@ -79,7 +114,7 @@ func UpdateTransportData(c *gin.Context) {
 		return
 	}
 
-	t, err := models.GetTransportByID(id)
+	t, err := models.GetTransportByID(requestOrgID(c), id)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Transport data not found"})
@@ -89,6 +124,8 @@ func UpdateTransportData(c *gin.Context) {
 		return
 	}
 
+	location := t.EventAreaLocation
+
 	var req TransportRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -118,7 +155,15 @@ func UpdateTransportData(c *gin.Context) {
 	t.FuelEfficiencyKMPerLiter = toNullFloat64(req.FuelEfficiencyKMPerLiter)
 	t.Remarks = toNullString(req.Remarks)
 
-	if err := t.Update(); err != nil {
+	if !requireLocationChange(c, location, t.EventAreaLocation) {
+		return
+	}
+
+	if err := t.Update(requestActor(c)); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Transport data not found"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update transport data", "details": err.Error()})
 		return
 	}
@@ -134,10 +179,23 @@ func DeleteTransportData(c *gin.Context) {
 		return
 	}
 
-	if err := models.DeleteTransport(id); err != nil {
+	if !requireRecordLocation(c, models.TableTransport, id) {
+		return
+	}
+
+	if err := models.DeleteTransport(requestActor(c), id); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Transport data not found"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete transport data", "details": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Transport data deleted successfully"})
 }
+
+// RestoreTransportData undeletes transport data, see restoreRecord.
+func RestoreTransportData(c *gin.Context) {
+	restoreRecord(c, models.TableTransport, "transport data")
+}
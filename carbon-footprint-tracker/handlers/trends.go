@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"carbon-footprint-tracker/models"
+	"sort"
+	"time"
+)
+
+const (
+	GranularityDay   = "day"
+	GranularityWeek  = "week"
+	GranularityMonth = "month"
+)
+
+type TrendPoint struct {
+	Period string  `json:"period"` // Start of the bucket, YYYY-MM-DD
+	CO2e   float64 `json:"co2e"`
+}
+
+type DashboardTrends struct {
+	Granularity string                  `json:"granularity"`
+	Total       []TrendPoint            `json:"total"`
+	Components  map[string][]TrendPoint `json:"components"`
+}
+
+func isValidGranularity(g string) bool {
+	return g == GranularityDay || g == GranularityWeek || g == GranularityMonth
+}
+
+// trendBuilder assembles the per-component series bucketed in SQL, see
+// models.FootprintSeries, into DashboardTrends. The buckets are used as they
+// come; periods between them without records are added with zero emissions.
+type trendBuilder struct {
+	granularity string
+	buckets     map[string]map[time.Time]float64
+}
+
+func newTrendBuilder(granularity string) *trendBuilder {
+	return &trendBuilder{
+		granularity: granularity,
+		buckets:     make(map[string]map[time.Time]float64),
+	}
+}
+
+func (tb *trendBuilder) add(component string, points []models.FootprintPoint) {
+	buckets := make(map[time.Time]float64, len(points))
+	for _, p := range points {
+		buckets[p.Period] = p.CO2eKg
+	}
+	tb.buckets[component] = buckets
+}
+
+// next returns the start of the period after the one starting at start.
+func (tb *trendBuilder) next(start time.Time) time.Time {
+	switch tb.granularity {
+	case GranularityWeek:
+		return start.AddDate(0, 0, 7)
+	case GranularityMonth:
+		return start.AddDate(0, 1, 0)
+	default:
+		return start.AddDate(0, 0, 1)
+	}
+}
+
+// periods returns the start of every period from the first to the last one
+// with records, in order.
+func (tb *trendBuilder) periods() []time.Time {
+	seen := make(map[time.Time]bool)
+	for _, buckets := range tb.buckets {
+		for start := range buckets {
+			seen[start] = true
+		}
+	}
+	if len(seen) == 0 {
+		return nil
+	}
+	periods := make([]time.Time, 0, len(seen))
+	for start := range seen {
+		periods = append(periods, start)
+	}
+	sort.Slice(periods, func(i, j int) bool { return periods[i].Before(periods[j]) })
+
+	last := periods[len(periods)-1]
+	for start := tb.next(periods[0]); start.Before(last); start = tb.next(start) {
+		if !seen[start] {
+			seen[start] = true
+			periods = append(periods, start)
+		}
+	}
+	sort.Slice(periods, func(i, j int) bool { return periods[i].Before(periods[j]) })
+	return periods
+}
+
+func (tb *trendBuilder) build() *DashboardTrends {
+	periods := tb.periods()
+	trends := &DashboardTrends{
+		Granularity: tb.granularity,
+		Total:       make([]TrendPoint, len(periods)),
+		Components:  make(map[string][]TrendPoint),
+	}
+	for i, start := range periods {
+		trends.Total[i].Period = start.Format(filterDateLayout)
+	}
+	for component, buckets := range tb.buckets {
+		points := make([]TrendPoint, len(periods))
+		for i, start := range periods {
+			points[i] = TrendPoint{Period: start.Format(filterDateLayout), CO2e: buckets[start]}
+			trends.Total[i].CO2e += buckets[start]
+		}
+		trends.Components[component] = points
+	}
+	return trends
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"carbon-footprint-tracker/models"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTrendBuilder(t *testing.T) {
+	day := func(year int, month time.Month, d int) time.Time {
+		return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name        string
+		granularity string
+		series      map[string][]models.FootprintPoint
+		total       []TrendPoint
+		components  map[string][]TrendPoint
+	}{
+		{
+			name:        "no records",
+			granularity: GranularityDay,
+			series:      map[string][]models.FootprintPoint{"Waste": nil},
+			total:       []TrendPoint{},
+			components:  map[string][]TrendPoint{"Waste": {}},
+		},
+		{
+			name:        "months with a gap",
+			granularity: GranularityMonth,
+			series: map[string][]models.FootprintPoint{
+				"Electrical": {{Period: day(2024, 1, 1), CO2eKg: 10}, {Period: day(2024, 4, 1), CO2eKg: 40}},
+				"Waste":      {{Period: day(2024, 2, 1), CO2eKg: 2}},
+				"Transport":  nil,
+			},
+			total: []TrendPoint{{"2024-01-01", 10}, {"2024-02-01", 2}, {"2024-03-01", 0}, {"2024-04-01", 40}},
+			components: map[string][]TrendPoint{
+				"Electrical": {{"2024-01-01", 10}, {"2024-02-01", 0}, {"2024-03-01", 0}, {"2024-04-01", 40}},
+				"Waste":      {{"2024-01-01", 0}, {"2024-02-01", 2}, {"2024-03-01", 0}, {"2024-04-01", 0}},
+				"Transport":  {{"2024-01-01", 0}, {"2024-02-01", 0}, {"2024-03-01", 0}, {"2024-04-01", 0}},
+			},
+		},
+		{
+			name:        "weeks start where SQL puts them",
+			granularity: GranularityWeek,
+			series: map[string][]models.FootprintPoint{
+				"Electrical": {{Period: day(2024, 12, 23), CO2eKg: 5}, {Period: day(2025, 1, 6), CO2eKg: 7}},
+			},
+			total: []TrendPoint{{"2024-12-23", 5}, {"2024-12-30", 0}, {"2025-01-06", 7}},
+			components: map[string][]TrendPoint{
+				"Electrical": {{"2024-12-23", 5}, {"2024-12-30", 0}, {"2025-01-06", 7}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tb := newTrendBuilder(tt.granularity)
+			for component, points := range tt.series {
+				tb.add(component, points)
+			}
+			got := tb.build()
+			if got.Granularity != tt.granularity {
+				t.Errorf("granularity = %s, want %s", got.Granularity, tt.granularity)
+			}
+			if !reflect.DeepEqual(got.Total, tt.total) {
+				t.Errorf("total = %v, want %v", got.Total, tt.total)
+			}
+			if !reflect.DeepEqual(got.Components, tt.components) {
+				t.Errorf("components = %v, want %v", got.Components, tt.components)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"carbon-footprint-tracker/models"
+	"carbon-footprint-tracker/utils"
 	"database/sql"
 	"net/http"
 	"strconv"
@@ -9,8 +10,32 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// getOrgUser returns the user with id for an admin to manage. Users of other
+// organisations are reported as not found, and super-admins can only be
+// managed by super-admins. On failure it responds, using failure as the
+// message for database errors, and returns false.
+func getOrgUser(c *gin.Context, id int, failure string) (*models.User, bool) {
+	user, err := models.GetUserByID(id)
+	if err == nil && user.OrgID != requestOrgID(c) {
+		err = sql.ErrNoRows
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": failure, "details": err.Error()})
+		return nil, false
+	}
+	if user.Role == models.RoleSuperAdmin && c.GetString("userRole") != models.RoleSuperAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only super-admins can manage super-admin accounts"})
+		return nil, false
+	}
+	return user, true
+}
+
 func GetUsers(c *gin.Context) {
-	users, err := models.GetAllUsers()
+	users, err := models.GetAllUsers(requestOrgID(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve users", "details": err.Error()})
 		return
@@ -25,8 +50,11 @@ func AddUser(c *gin.Context) {
 		return
 	}
 
-	if req.Role != "admin" && req.Role != "staff" && req.Role != "viewer" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role specified"})
+	if !requireRole(c, req.Role) {
+		return
+	}
+	if err := utils.ValidatePasswordStrength(req.Password, req.Name, req.Email); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -35,6 +63,7 @@ func AddUser(c *gin.Context) {
 		Email:        req.Email,
 		PasswordHash: req.Password,
 		Role:         req.Role,
+		OrgID:        requestOrgID(c),
 	}
 
 	if err := user.Create(); err != nil {
@@ -53,13 +82,8 @@ func UpdateUser(c *gin.Context) {
 		return
 	}
 
-	user, err := models.GetUserByID(id)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve user", "details": err.Error()})
+	user, ok := getOrgUser(c, id, "Failed to retrieve user")
+	if !ok {
 		return
 	}
 
@@ -79,15 +103,17 @@ func UpdateUser(c *gin.Context) {
 	if req.Email != "" {
 		user.Email = req.Email
 	}
+	roleChanged := false
 	if req.Role != "" {
-		if req.Role != "admin" && req.Role != "staff" && req.Role != "viewer" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role specified"})
+		if !requireRole(c, req.Role) {
 			return
 		}
+		roleChanged = req.Role != user.Role
 		user.Role = req.Role
 	}
 
-	if err := user.Update(); err != nil {
+	// Tokens carry the old role, so make the user log in again.
+	if err := user.Update(roleChanged); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user", "details": err.Error()})
 		return
 	}
@@ -103,7 +129,11 @@ func DeleteUser(c *gin.Context) {
 		return
 	}
 
-	if err := models.DeleteUser(id); err != nil {
+	if _, ok := getOrgUser(c, id, "Failed to delete user"); !ok {
+		return
+	}
+
+	if err := models.DeleteUser(requestOrgID(c), id); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user", "details": err.Error()})
 		return
 	}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"carbon-footprint-tracker/models"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type UserLocationsRequest struct {
+	Locations []string `json:"locations" binding:"dive,max=255"` // Empty to allow every location
+}
+
+// GetUserLocations returns the locations a user is limited to. An empty list
+// means they can access every location.
+func GetUserLocations(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	user, ok := getOrgUser(c, id, "Failed to retrieve user locations")
+	if !ok {
+		return
+	}
+	locations, err := models.GetUserLocations(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve user locations", "details": err.Error()})
+		return
+	}
+	if locations == nil {
+		locations = []string{}
+	}
+	scoped, err := user.LocationScoped()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve user locations", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"user_id": user.ID, "locations": locations, "applies": scoped})
+}
+
+// SetUserLocations replaces the locations a staff or viewer user is limited
+// to. They can then only see and change records at those locations. Users
+// whose role holds the locations:all permission are never limited, but keep
+// their locations in case they lose it.
+// Changes apply to the user from their next request.
+//
+// A user limited to assigned locations can, like for API keys, only assign
+// locations within their own, and only to users limited to those too.
+func SetUserLocations(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req UserLocationsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	locations := make([]string, 0, len(req.Locations))
+	for _, location := range req.Locations {
+		if location = strings.TrimSpace(location); location == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Locations must not be empty"})
+			return
+		}
+		if !requireLocation(c, location) {
+			return
+		}
+		locations = append(locations, location)
+	}
+	if len(locations) == 0 && locationScope(c) != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You are limited to assigned locations, so the user must be too"})
+		return
+	}
+
+	user, ok := getOrgUser(c, id, "Failed to update user locations")
+	if !ok || !requireUserInLocationScope(c, user) {
+		return
+	}
+	if err := models.SetUserLocations(id, locations); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user locations", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User locations updated successfully", "locations": locations})
+}
+
+// requireUserInLocationScope responds with 403 and returns false if the
+// request is limited to assigned locations and user can access any location
+// outside them, so that limited users can't change the access of users with
+// wider access than theirs.
+func requireUserInLocationScope(c *gin.Context, user *models.User) bool {
+	if locationScope(c) == nil {
+		return true
+	}
+	scoped, err := user.LocationScoped()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check user locations", "details": err.Error()})
+		return false
+	}
+	locations, err := models.GetUserLocations(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check user locations", "details": err.Error()})
+		return false
+	}
+	if !scoped || len(locations) == 0 {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You are limited to assigned locations, so you can't change the locations of a user who isn't"})
+		return false
+	}
+	for _, location := range locations {
+		if !inLocationScope(c, location) {
+			c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("User has access to location '%s', which is outside your assigned locations", location)})
+			return false
+		}
+	}
+	return true
+}
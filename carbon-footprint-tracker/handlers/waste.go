@@ -29,6 +29,22 @@ func AddWasteEntry(c *gin.Context) {
 		return
 	}
 
+	w := newWaste(req)
+
+	if !requireLocation(c, w.CollectionLocation) {
+		return
+	}
+
+	if err := w.Create(requestActor(c)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add waste entry", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Waste entry added successfully", "id": w.ID})
+}
+
+// newWaste builds a waste entry from req, filling in the defaults for omitted fields.
+func newWaste(req WasteRequest) models.Waste {
 	if req.CollectionLocation == "" {
 		req.CollectionLocation = "Overall" // Default location
 	}
@@ -36,7 +52,7 @@ func AddWasteEntry(c *gin.Context) {
 		req.Date = time.Now()
 	}
 
-	w := models.Waste{
+	return models.Waste{
 		Date:               req.Date,
 		CollectionLocation: req.CollectionLocation,
 		WasteType:          req.WasteType,
@@ -47,22 +63,41 @@ func AddWasteEntry(c *gin.Context) {
 		Destination:        toNullString(req.Destination),
 		Remarks:            toNullString(req.Remarks),
 	}
+}
 
-	if err := w.Create(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add waste entry", "details": err.Error()})
+// ImportWasteEntries creates waste entries from a CSV upload, see importCSV.
+func ImportWasteEntries(c *gin.Context) {
+	footprinter, ok := importFootprinter(c)
+	if !ok {
 		return
 	}
+	importCSV(c, "waste entries", func(db models.Querier, req WasteRequest) error {
+		w := newWaste(req)
+		if !inLocationScope(c, w.CollectionLocation) {
+			return errOutsideLocations(w.CollectionLocation)
+		}
+		return w.CreateWith(db, requestActor(c), footprinter)
+	})
+}
 
-	c.JSON(http.StatusCreated, gin.H{"message": "Waste entry added successfully", "id": w.ID})
+// ExportWasteEntries streams waste entries as CSV or XLSX, see exportModule.
+func ExportWasteEntries(c *gin.Context) {
+	exportModule(c, models.TableWaste)
 }
 
 func GetWasteData(c *gin.Context) {
-	wastes, err := models.GetAllWasteEntries()
+	opts, err := parseListOptions(c, models.TableWaste)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	wastes, total, err := models.ListWasteEntries(opts)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve waste data", "details": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, wastes)
+	c.JSON(http.StatusOK, ListResponse{Data: wastes, Page: opts.Page, PageSize: opts.PageSize, Total: total})
 }
 
 func UpdateWasteEntry(c *gin.Context) {
@@ -73,7 +108,7 @@ func UpdateWasteEntry(c *gin.Context) {
 		return
 	}
 
-	w, err := models.GetWasteByID(id)
+	w, err := models.GetWasteByID(requestOrgID(c), id)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Waste entry not found"})
@@ -83,6 +118,8 @@ func UpdateWasteEntry(c *gin.Context) {
 		return
 	}
 
+	location := w.CollectionLocation
+
 	var req WasteRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -105,7 +142,15 @@ func UpdateWasteEntry(c *gin.Context) {
 	w.Destination = toNullString(req.Destination)
 	w.Remarks = toNullString(req.Remarks)
 
-	if err := w.Update(); err != nil {
+	if !requireLocationChange(c, location, w.CollectionLocation) {
+		return
+	}
+
+	if err := w.Update(requestActor(c)); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Waste entry not found"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update waste entry", "details": err.Error()})
 		return
 	}
@@ -121,10 +166,23 @@ func DeleteWasteEntry(c *gin.Context) {
 		return
 	}
 
-	if err := models.DeleteWaste(id); err != nil {
+	if !requireRecordLocation(c, models.TableWaste, id) {
+		return
+	}
+
+	if err := models.DeleteWaste(requestActor(c), id); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Waste entry not found"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete waste entry", "details": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Waste entry deleted successfully"})
 }
+
+// RestoreWasteEntry undeletes a waste entry, see restoreRecord.
+func RestoreWasteEntry(c *gin.Context) {
+	restoreRecord(c, models.TableWaste, "waste entry")
+}
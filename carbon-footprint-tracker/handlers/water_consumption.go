@@ -28,6 +28,22 @@ func AddWaterConsumption(c *gin.Context) {
 		return
 	}
 
+	w := newWaterConsumption(req)
+
+	if !requireLocation(c, w.Location) {
+		return
+	}
+
+	if err := w.Create(requestActor(c)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add water consumption", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Water consumption added successfully", "id": w.ID})
+}
+
+// newWaterConsumption builds a water consumption reading from req, filling in the defaults for omitted fields.
+func newWaterConsumption(req WaterConsumptionRequest) models.WaterConsumption {
 	if req.Location == "" {
 		req.Location = "Overall"
 	}
@@ -35,7 +51,7 @@ func AddWaterConsumption(c *gin.Context) {
 		req.Date = time.Now()
 	}
 
-	w := models.WaterConsumption{
+	return models.WaterConsumption{
 		Date:                    req.Date,
 		Location:                req.Location,
 		WaterSource:             toNullString(req.WaterSource),
@@ -45,22 +61,41 @@ func AddWaterConsumption(c *gin.Context) {
 		UsageType:               toNullString(req.UsageType),
 		Remarks:                 toNullString(req.Remarks),
 	}
+}
 
-	if err := w.Create(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add water consumption", "details": err.Error()})
+// ImportWaterConsumptions creates water consumption from a CSV upload, see importCSV.
+func ImportWaterConsumptions(c *gin.Context) {
+	footprinter, ok := importFootprinter(c)
+	if !ok {
 		return
 	}
+	importCSV(c, "water consumption", func(db models.Querier, req WaterConsumptionRequest) error {
+		w := newWaterConsumption(req)
+		if !inLocationScope(c, w.Location) {
+			return errOutsideLocations(w.Location)
+		}
+		return w.CreateWith(db, requestActor(c), footprinter)
+	})
+}
 
-	c.JSON(http.StatusCreated, gin.H{"message": "Water consumption added successfully", "id": w.ID})
+// ExportWaterConsumptions streams water consumption readings as CSV or XLSX, see exportModule.
+func ExportWaterConsumptions(c *gin.Context) {
+	exportModule(c, models.TableWaterConsumption)
 }
 
 func GetWaterConsumptions(c *gin.Context) {
-	readings, err := models.GetAllWaterConsumptions()
+	opts, err := parseListOptions(c, models.TableWaterConsumption)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	readings, total, err := models.ListWaterConsumptions(opts)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve water consumptions", "details": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, readings)
+	c.JSON(http.StatusOK, ListResponse{Data: readings, Page: opts.Page, PageSize: opts.PageSize, Total: total})
 }
 
 func UpdateWaterConsumption(c *gin.Context) {
@@ -71,7 +106,7 @@ func UpdateWaterConsumption(c *gin.Context) {
 		return
 	}
 
-	w, err := models.GetWaterConsumptionByID(id)
+	w, err := models.GetWaterConsumptionByID(requestOrgID(c), id)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Water consumption reading not found"})
@@ -81,6 +116,8 @@ func UpdateWaterConsumption(c *gin.Context) {
 		return
 	}
 
+	location := w.Location
+
 	var req WaterConsumptionRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -100,7 +137,15 @@ func UpdateWaterConsumption(c *gin.Context) {
 	w.UsageType = toNullString(req.UsageType)
 	w.Remarks = toNullString(req.Remarks)
 
-	if err := w.Update(); err != nil {
+	if !requireLocationChange(c, location, w.Location) {
+		return
+	}
+
+	if err := w.Update(requestActor(c)); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Water consumption reading not found"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update water consumption", "details": err.Error()})
 		return
 	}
@@ -116,10 +161,23 @@ func DeleteWaterConsumption(c *gin.Context) {
 		return
 	}
 
-	if err := models.DeleteWaterConsumption(id); err != nil {
+	if !requireRecordLocation(c, models.TableWaterConsumption, id) {
+		return
+	}
+
+	if err := models.DeleteWaterConsumption(requestActor(c), id); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Water consumption reading not found"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete water consumption", "details": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Water consumption deleted successfully"})
 }
+
+// RestoreWaterConsumption undeletes a water consumption entry, see restoreRecord.
+func RestoreWaterConsumption(c *gin.Context) {
+	restoreRecord(c, models.TableWaterConsumption, "water consumption")
+}
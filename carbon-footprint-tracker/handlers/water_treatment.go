@@ -29,11 +29,27 @@ func AddWaterTreatment(c *gin.Context) {
 		return
 	}
 
+	wt := newWaterTreatment(req)
+
+	if !requireLocation(c, wt.Location) {
+		return
+	}
+
+	if err := wt.Create(requestActor(c)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add water treatment", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Water treatment added successfully", "id": wt.ID})
+}
+
+// newWaterTreatment builds a water treatment entry from req, filling in the defaults for omitted fields.
+func newWaterTreatment(req WaterTreatmentRequest) models.WaterTreatment {
 	if req.Date.IsZero() {
 		req.Date = time.Now()
 	}
 
-	wt := models.WaterTreatment{
+	return models.WaterTreatment{
 		Date:                        req.Date,
 		Location:                    req.Location,
 		TreatedLitersPerDay:         toNullFloat64(req.TreatedLitersPerDay),
@@ -44,22 +60,41 @@ func AddWaterTreatment(c *gin.Context) {
 		ChemicalsUsedQuantityKG:     toNullFloat64(req.ChemicalsUsedQuantityKG),
 		Remarks:                     toNullString(req.Remarks),
 	}
+}
 
-	if err := wt.Create(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add water treatment", "details": err.Error()})
+// ImportWaterTreatments creates water treatment from a CSV upload, see importCSV.
+func ImportWaterTreatments(c *gin.Context) {
+	footprinter, ok := importFootprinter(c)
+	if !ok {
 		return
 	}
+	importCSV(c, "water treatment", func(db models.Querier, req WaterTreatmentRequest) error {
+		wt := newWaterTreatment(req)
+		if !inLocationScope(c, wt.Location) {
+			return errOutsideLocations(wt.Location)
+		}
+		return wt.CreateWith(db, requestActor(c), footprinter)
+	})
+}
 
-	c.JSON(http.StatusCreated, gin.H{"message": "Water treatment added successfully", "id": wt.ID})
+// ExportWaterTreatments streams water treatment entries as CSV or XLSX, see exportModule.
+func ExportWaterTreatments(c *gin.Context) {
+	exportModule(c, models.TableWaterTreatment)
 }
 
 func GetWaterTreatments(c *gin.Context) {
-	treatments, err := models.GetAllWaterTreatments()
+	opts, err := parseListOptions(c, models.TableWaterTreatment)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	treatments, total, err := models.ListWaterTreatments(opts)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve water treatments", "details": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, treatments)
+	c.JSON(http.StatusOK, ListResponse{Data: treatments, Page: opts.Page, PageSize: opts.PageSize, Total: total})
 }
 
 func UpdateWaterTreatment(c *gin.Context) {
@@ -70,7 +105,7 @@ func UpdateWaterTreatment(c *gin.Context) {
 		return
 	}
 
-	wt, err := models.GetWaterTreatmentByID(id)
+	wt, err := models.GetWaterTreatmentByID(requestOrgID(c), id)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Water treatment entry not found"})
@@ -80,6 +115,8 @@ func UpdateWaterTreatment(c *gin.Context) {
 		return
 	}
 
+	location := wt.Location
+
 	var req WaterTreatmentRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -98,7 +135,15 @@ func UpdateWaterTreatment(c *gin.Context) {
 	wt.ChemicalsUsedQuantityKG = toNullFloat64(req.ChemicalsUsedQuantityKG)
 	wt.Remarks = toNullString(req.Remarks)
 
-	if err := wt.Update(); err != nil {
+	if !requireLocationChange(c, location, wt.Location) {
+		return
+	}
+
+	if err := wt.Update(requestActor(c)); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Water treatment entry not found"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update water treatment", "details": err.Error()})
 		return
 	}
@@ -114,10 +159,23 @@ func DeleteWaterTreatment(c *gin.Context) {
 		return
 	}
 
-	if err := models.DeleteWaterTreatment(id); err != nil {
+	if !requireRecordLocation(c, models.TableWaterTreatment, id) {
+		return
+	}
+
+	if err := models.DeleteWaterTreatment(requestActor(c), id); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Water treatment entry not found"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete water treatment", "details": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Water treatment deleted successfully"})
 }
+
+// RestoreWaterTreatment undeletes a water treatment entry, see restoreRecord.
+func RestoreWaterTreatment(c *gin.Context) {
+	restoreRecord(c, models.TableWaterTreatment, "water treatment")
+}
@@ -0,0 +1,13 @@
+package mailer
+
+import (
+	"log"
+)
+
+// LogMailer writes messages to the application log, for local development.
+type LogMailer struct{}
+
+func (LogMailer) Send(msg Message) error {
+	log.Printf("Mail to %s: %s\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
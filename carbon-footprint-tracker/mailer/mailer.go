@@ -0,0 +1,66 @@
+// Package mailer sends transactional email such as password reset links.
+package mailer
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string // Plain text
+}
+
+// Mailer delivers messages.
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// FromEnv builds the mailer selected by MAILER:
+//
+//	log   (default) writes messages to the application log
+//	file  writes each message to a .eml file in MAILER_DIR
+//	smtp  sends through SMTP_HOST:SMTP_PORT as MAIL_FROM, authenticating
+//	      with SMTP_USERNAME and SMTP_PASSWORD when set
+func FromEnv() (Mailer, error) {
+	switch kind := strings.ToLower(os.Getenv("MAILER")); kind {
+	case "", "log":
+		return LogMailer{}, nil
+	case "file":
+		dir := os.Getenv("MAILER_DIR")
+		if dir == "" {
+			dir = "mail"
+		}
+		return NewFileMailer(dir)
+	case "smtp":
+		port := os.Getenv("SMTP_PORT")
+		if port == "" {
+			port = "587"
+		}
+		return NewSMTPMailer(os.Getenv("SMTP_HOST"), port, os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD"), os.Getenv("MAIL_FROM"))
+	default:
+		return nil, fmt.Errorf("unknown MAILER %q, expected log, file or smtp", kind)
+	}
+}
+
+// format renders msg as an RFC 5322 message.
+func format(from string, msg Message) []byte {
+	var b strings.Builder
+	if from != "" {
+		fmt.Fprintf(&b, "From: %s\r\n", from)
+	}
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+// headerSafe reports whether s can be placed in a header without injecting
+// further headers.
+func headerSafe(s string) bool {
+	return !strings.ContainsAny(s, "\r\n")
+}
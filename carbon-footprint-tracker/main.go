@@ -2,9 +2,15 @@ package main
 
 import (
 	"carbon-footprint-tracker/config"
+	"carbon-footprint-tracker/emissions"
 	"carbon-footprint-tracker/handlers"
+	"carbon-footprint-tracker/mailer"
 	"carbon-footprint-tracker/middleware"
+	"carbon-footprint-tracker/models"
+	"carbon-footprint-tracker/oidc"
+	"fmt"
 	"log"
+	"os"
 	"time"
 
 	"github.com/gin-contrib/cors"
@@ -16,137 +22,326 @@ func main() {
 	config.LoadConfig()
 	defer config.DB.Close() // Ensure DB connection is closed when main exits
 
+	// Subcommands, e.g. `carbon-footprint-tracker migrate`
+	if len(os.Args) > 1 {
+		if err := runCommand(os.Args[1], os.Args[2:]); err != nil {
+			log.Fatalf("%s: %v", os.Args[1], err)
+		}
+		return
+	}
+
+	if config.AutoMigrate {
+		if err := migrate(); err != nil {
+			log.Fatalf("Failed to apply database migrations: %v", err)
+		}
+	}
+
+	// Activity records store their footprint when written, and it is
+	// recomputed in the background when the factor library changes
+	models.SetFootprinterSource(emissions.CurrentFootprinter)
+	handlers.StartFootprintRecomputer()
+
+	// Password reset emails go through MAILER (log by default)
+	m, err := mailer.FromEnv()
+	if err != nil {
+		log.Fatalf("Failed to configure mailer: %v", err)
+	}
+	handlers.SetMailer(m)
+
+	// Single sign-on through the identity provider at OIDC_ISSUER, if set
+	provider, err := oidc.FromEnv()
+	if err != nil {
+		log.Fatalf("Failed to configure single sign-on: %v", err)
+	}
+	if provider != nil {
+		if err := checkSSORoles(provider); err != nil {
+			log.Fatalf("Failed to configure single sign-on: %v", err)
+		}
+		handlers.SetOIDCProvider(provider)
+	}
+
+	// Deleted module records can be restored until the retention window ends
+	startPurgeJob()
+
 	// Initialize Gin router
 	router := gin.Default()
+	if err := router.SetTrustedProxies(config.TrustedProxies); err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
 
 	router.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"http://127.0.0.1:8000", "http://localhost:8000", "http://localhost:5500", "http://127.0.0.1:5500"},
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", middleware.OrganisationHeader},
 		ExposeHeaders:    []string{"Content-Length"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
 
-	// Public Routes (No authentication required)
+	// Public Routes (No authentication required, except logout, password change and MFA management)
 	authRoutes := router.Group("/auth")
 	{
 		authRoutes.POST("/register", handlers.RegisterUser)
 		authRoutes.POST("/login", handlers.LoginUser)
+		authRoutes.POST("/login/mfa", handlers.VerifyMFALogin)
+		authRoutes.POST("/refresh", handlers.RefreshToken)
+		authRoutes.POST("/logout", middleware.AuthRequired(), handlers.LogoutUser)
+		authRoutes.POST("/password/forgot", handlers.ForgotPassword)
+		authRoutes.POST("/password/reset", handlers.ResetPassword)
+		authRoutes.POST("/password/change", middleware.AuthRequired(), handlers.ChangePassword)
+		authRoutes.GET("/oidc/login", handlers.OIDCLogin)
+		authRoutes.GET("/oidc/callback", handlers.OIDCCallback)
+
+		mfaRoutes := authRoutes.Group("/mfa")
+		mfaRoutes.Use(middleware.AuthRequired())
+		{
+			mfaRoutes.GET("", handlers.GetMFAStatus)
+			mfaRoutes.POST("/setup", handlers.SetupMFA)
+			mfaRoutes.POST("/enable", handlers.EnableMFA)
+			mfaRoutes.POST("/disable", handlers.DisableMFA)
+			mfaRoutes.POST("/recovery_codes", handlers.RegenerateRecoveryCodes)
+		}
 	}
 
-	// Authenticated Routes (Requires JWT token)
+	// Authenticated Routes (Requires JWT token or API key)
 	authenticated := router.Group("/")
 	authenticated.Use(middleware.AuthRequired())
 	{
-		// User Management (Admin only)
+		// User Management
 		userRoutes := authenticated.Group("/users")
-		userRoutes.Use(middleware.AuthorizeRoles("admin"))
+		userRoutes.Use(middleware.RequirePermission(models.PermUsersManage))
 		{
 			userRoutes.GET("", handlers.GetUsers)
 			userRoutes.POST("", handlers.AddUser)
 			userRoutes.PUT("/:id", handlers.UpdateUser)
 			userRoutes.DELETE("/:id", handlers.DeleteUser)
+			userRoutes.DELETE("/:id/mfa", handlers.ResetUserMFA)
+			userRoutes.POST("/:id/unlock", handlers.UnlockUser)
+			userRoutes.GET("/:id/locations", handlers.GetUserLocations)
+			userRoutes.PUT("/:id/locations", handlers.SetUserLocations)
+		}
+
+		// Invitations
+		invitationRoutes := authenticated.Group("/invitations")
+		invitationRoutes.Use(middleware.RequirePermission(models.PermInvitationsManage))
+		{
+			invitationRoutes.GET("", handlers.GetInvitations)
+			invitationRoutes.POST("", handlers.CreateInvitation)
+			invitationRoutes.DELETE("/:id", handlers.DeleteInvitation)
+		}
+
+		// API keys for unattended clients
+		apiKeyRoutes := authenticated.Group("/api_keys")
+		apiKeyRoutes.Use(middleware.RequirePermission(models.PermAPIKeysManage))
+		{
+			apiKeyRoutes.GET("", handlers.GetAPIKeys)
+			apiKeyRoutes.POST("", handlers.CreateAPIKey)
+			apiKeyRoutes.DELETE("/:id", handlers.RevokeAPIKey)
 		}
 
 		// Electric Consumption
 		electricRoutes := authenticated.Group("/electric")
-		electricRoutes.Use(middleware.AuthorizeRoles("admin", "staff"))
 		{
-			electricRoutes.GET("", handlers.GetElectricConsumptions)
-			electricRoutes.POST("", handlers.AddElectricConsumption)
-			electricRoutes.PUT("/:id", handlers.UpdateElectricConsumption)
-			electricRoutes.DELETE("/:id", handlers.DeleteElectricConsumption)
+			read, write, restore := modulePermissions("electric")
+			electricRoutes.GET("", read, handlers.GetElectricConsumptions)
+			electricRoutes.GET("/export", read, handlers.ExportElectricConsumptions)
+			electricRoutes.POST("", write, handlers.AddElectricConsumption)
+			electricRoutes.POST("/import", write, handlers.ImportElectricConsumptions)
+			electricRoutes.PUT("/:id", write, handlers.UpdateElectricConsumption)
+			electricRoutes.DELETE("/:id", write, handlers.DeleteElectricConsumption)
+			electricRoutes.POST("/:id/restore", restore, handlers.RestoreElectricConsumption)
+
+			// Meters, whose cumulative readings are recorded as grid consumption
+			electricRoutes.GET("/meters", read, handlers.GetMeters)
+			electricRoutes.POST("/meters", write, handlers.AddMeter)
+			electricRoutes.PUT("/meters/:id", write, handlers.UpdateMeter)
+			electricRoutes.DELETE("/meters/:id", write, handlers.DeleteMeter)
+			electricRoutes.GET("/meters/:id/readings", read, handlers.GetMeterReadings)
+			electricRoutes.POST("/meters/:id/readings", write, handlers.AddMeterReading)
+
+			electricRoutes.GET("/generators/analytics", read, handlers.GetGeneratorAnalytics)
 		}
 
 		// Population
 		populationRoutes := authenticated.Group("/population")
-		populationRoutes.Use(middleware.AuthorizeRoles("admin", "staff"))
 		{
-			populationRoutes.GET("", handlers.GetPopulationStats)
-			populationRoutes.POST("", handlers.AddPopulation)
-			populationRoutes.PUT("/:id", handlers.UpdatePopulation)
-			populationRoutes.DELETE("/:id", handlers.DeletePopulation)
+			read, write, restore := modulePermissions("population")
+			populationRoutes.GET("", read, handlers.GetPopulationStats)
+			populationRoutes.GET("/export", read, handlers.ExportPopulation)
+			populationRoutes.POST("", write, handlers.AddPopulation)
+			populationRoutes.POST("/import", write, handlers.ImportPopulation)
+			populationRoutes.PUT("/:id", write, handlers.UpdatePopulation)
+			populationRoutes.DELETE("/:id", write, handlers.DeletePopulation)
+			populationRoutes.POST("/:id/restore", restore, handlers.RestorePopulation)
 		}
 
 		// Transport
 		transportRoutes := authenticated.Group("/transport")
-		transportRoutes.Use(middleware.AuthorizeRoles("admin", "staff"))
 		{
-			transportRoutes.GET("", handlers.GetTransportData)
-			transportRoutes.POST("", handlers.AddTransportData)
-			transportRoutes.PUT("/:id", handlers.UpdateTransportData)
-			transportRoutes.DELETE("/:id", handlers.DeleteTransportData)
+			read, write, restore := modulePermissions("transport")
+			transportRoutes.GET("", read, handlers.GetTransportData)
+			transportRoutes.GET("/export", read, handlers.ExportTransportData)
+			transportRoutes.POST("", write, handlers.AddTransportData)
+			transportRoutes.POST("/import", write, handlers.ImportTransportData)
+			transportRoutes.PUT("/:id", write, handlers.UpdateTransportData)
+			transportRoutes.DELETE("/:id", write, handlers.DeleteTransportData)
+			transportRoutes.POST("/:id/restore", restore, handlers.RestoreTransportData)
 		}
 
 		// Water Consumption (Usage)
 		waterConsumptionRoutes := authenticated.Group("/water_consumption")
-		waterConsumptionRoutes.Use(middleware.AuthorizeRoles("admin", "staff"))
 		{
-			waterConsumptionRoutes.GET("", handlers.GetWaterConsumptions)
-			waterConsumptionRoutes.POST("", handlers.AddWaterConsumption)
-			waterConsumptionRoutes.PUT("/:id", handlers.UpdateWaterConsumption)
-			waterConsumptionRoutes.DELETE("/:id", handlers.DeleteWaterConsumption)
+			read, write, restore := modulePermissions("water_consumption")
+			waterConsumptionRoutes.GET("", read, handlers.GetWaterConsumptions)
+			waterConsumptionRoutes.GET("/export", read, handlers.ExportWaterConsumptions)
+			waterConsumptionRoutes.POST("", write, handlers.AddWaterConsumption)
+			waterConsumptionRoutes.POST("/import", write, handlers.ImportWaterConsumptions)
+			waterConsumptionRoutes.PUT("/:id", write, handlers.UpdateWaterConsumption)
+			waterConsumptionRoutes.DELETE("/:id", write, handlers.DeleteWaterConsumption)
+			waterConsumptionRoutes.POST("/:id/restore", restore, handlers.RestoreWaterConsumption)
 		}
 
 		// Water Treatment (NEW Module)
 		waterTreatmentRoutes := authenticated.Group("/water_treatment")
-		waterTreatmentRoutes.Use(middleware.AuthorizeRoles("admin", "staff"))
 		{
-			waterTreatmentRoutes.GET("", handlers.GetWaterTreatments)
-			waterTreatmentRoutes.POST("", handlers.AddWaterTreatment)
-			waterTreatmentRoutes.PUT("/:id", handlers.UpdateWaterTreatment)
-			waterTreatmentRoutes.DELETE("/:id", handlers.DeleteWaterTreatment)
+			read, write, restore := modulePermissions("water_treatment")
+			waterTreatmentRoutes.GET("", read, handlers.GetWaterTreatments)
+			waterTreatmentRoutes.GET("/export", read, handlers.ExportWaterTreatments)
+			waterTreatmentRoutes.POST("", write, handlers.AddWaterTreatment)
+			waterTreatmentRoutes.POST("/import", write, handlers.ImportWaterTreatments)
+			waterTreatmentRoutes.PUT("/:id", write, handlers.UpdateWaterTreatment)
+			waterTreatmentRoutes.DELETE("/:id", write, handlers.DeleteWaterTreatment)
+			waterTreatmentRoutes.POST("/:id/restore", restore, handlers.RestoreWaterTreatment)
 		}
 
 		// Waste Generation
 		wasteRoutes := authenticated.Group("/waste")
-		wasteRoutes.Use(middleware.AuthorizeRoles("admin", "staff"))
 		{
-			wasteRoutes.GET("", handlers.GetWasteData)
-			wasteRoutes.POST("", handlers.AddWasteEntry)
-			wasteRoutes.PUT("/:id", handlers.UpdateWasteEntry)
-			wasteRoutes.DELETE("/:id", handlers.DeleteWasteEntry)
+			read, write, restore := modulePermissions("waste")
+			wasteRoutes.GET("", read, handlers.GetWasteData)
+			wasteRoutes.GET("/export", read, handlers.ExportWasteEntries)
+			wasteRoutes.POST("", write, handlers.AddWasteEntry)
+			wasteRoutes.POST("/import", write, handlers.ImportWasteEntries)
+			wasteRoutes.PUT("/:id", write, handlers.UpdateWasteEntry)
+			wasteRoutes.DELETE("/:id", write, handlers.DeleteWasteEntry)
+			wasteRoutes.POST("/:id/restore", restore, handlers.RestoreWasteEntry)
 		}
 
 		// Accommodation
 		accommodationRoutes := authenticated.Group("/accommodation")
-		accommodationRoutes.Use(middleware.AuthorizeRoles("admin", "staff"))
 		{
-			accommodationRoutes.GET("", handlers.GetAccommodationData)
-			accommodationRoutes.POST("", handlers.AddAccommodationData)
-			accommodationRoutes.PUT("/:id", handlers.UpdateAccommodationData)
-			accommodationRoutes.DELETE("/:id", handlers.DeleteAccommodationData)
+			read, write, restore := modulePermissions("accommodation")
+			accommodationRoutes.GET("", read, handlers.GetAccommodationData)
+			accommodationRoutes.GET("/export", read, handlers.ExportAccommodationData)
+			accommodationRoutes.POST("", write, handlers.AddAccommodationData)
+			accommodationRoutes.POST("/import", write, handlers.ImportAccommodationData)
+			accommodationRoutes.PUT("/:id", write, handlers.UpdateAccommodationData)
+			accommodationRoutes.DELETE("/:id", write, handlers.DeleteAccommodationData)
+			accommodationRoutes.POST("/:id/restore", restore, handlers.RestoreAccommodationData)
 		}
 
 		// Goods Purchased
 		goodsRoutes := authenticated.Group("/goods")
-		goodsRoutes.Use(middleware.AuthorizeRoles("admin", "staff"))
 		{
-			goodsRoutes.GET("", handlers.GetGoodsPurchased)
-			goodsRoutes.POST("", handlers.AddGoodsPurchased)
-			goodsRoutes.PUT("/:id", handlers.UpdateGoodsPurchased)
-			goodsRoutes.DELETE("/:id", handlers.DeleteGoodsPurchased)
+			read, write, restore := modulePermissions("goods")
+			goodsRoutes.GET("", read, handlers.GetGoodsPurchased)
+			goodsRoutes.GET("/export", read, handlers.ExportGoodsPurchased)
+			goodsRoutes.POST("", write, handlers.AddGoodsPurchased)
+			goodsRoutes.POST("/import", write, handlers.ImportGoodsPurchased)
+			goodsRoutes.PUT("/:id", write, handlers.UpdateGoodsPurchased)
+			goodsRoutes.DELETE("/:id", write, handlers.DeleteGoodsPurchased)
+			goodsRoutes.POST("/:id/restore", restore, handlers.RestoreGoodsPurchased)
 		}
 
 		// Food Consumption (NEW Module)
 		foodConsumptionRoutes := authenticated.Group("/food_consumption")
-		foodConsumptionRoutes.Use(middleware.AuthorizeRoles("admin", "staff"))
 		{
-			foodConsumptionRoutes.GET("", handlers.GetFoodConsumptions)
-			foodConsumptionRoutes.POST("", handlers.AddFoodConsumption)
-			foodConsumptionRoutes.PUT("/:id", handlers.UpdateFoodConsumption)
-			foodConsumptionRoutes.DELETE("/:id", handlers.DeleteFoodConsumption)
+			read, write, restore := modulePermissions("food_consumption")
+			foodConsumptionRoutes.GET("", read, handlers.GetFoodConsumptions)
+			foodConsumptionRoutes.GET("/export", read, handlers.ExportFoodConsumptions)
+			foodConsumptionRoutes.POST("", write, handlers.AddFoodConsumption)
+			foodConsumptionRoutes.POST("/import", write, handlers.ImportFoodConsumptions)
+			foodConsumptionRoutes.PUT("/:id", write, handlers.UpdateFoodConsumption)
+			foodConsumptionRoutes.DELETE("/:id", write, handlers.DeleteFoodConsumption)
+			foodConsumptionRoutes.POST("/:id/restore", restore, handlers.RestoreFoodConsumption)
+		}
+
+		// Emission Factor Library
+		emissionFactorRoutes := authenticated.Group("/emission_factors")
+		{
+			emissionFactorRoutes.GET("", middleware.RequirePermission(models.PermEmissionFactorsRead), handlers.GetEmissionFactors)
+			emissionFactorRoutes.POST("", middleware.RequirePermission(models.PermEmissionFactorsWrite), handlers.AddEmissionFactor)
+			emissionFactorRoutes.PUT("/:id", middleware.RequirePermission(models.PermEmissionFactorsWrite), handlers.UpdateEmissionFactor)
+			emissionFactorRoutes.DELETE("/:id", middleware.RequirePermission(models.PermEmissionFactorsWrite), handlers.DeleteEmissionFactor)
+			emissionFactorRoutes.POST("/recompute", middleware.RequirePermission(models.PermEmissionFactorsWrite), handlers.RecomputeFootprints)
+		}
+
+		// Reports
+		reportRoutes := authenticated.Group("/reports")
+		{
+			reportRoutes.GET("/scopes", middleware.RequirePermission(models.PermReportsRead), handlers.GetScopeReport)
+			reportRoutes.GET("/export", middleware.RequirePermission(models.PermReportsExport), handlers.ExportReport)
 		}
 
-		// Dashboard (Admin and Viewer roles)
+		// Dashboard
 		dashboardRoutes := authenticated.Group("/dashboard")
-		dashboardRoutes.Use(middleware.AuthorizeRoles("admin", "staff", "viewer"))
+		dashboardRoutes.Use(middleware.RequirePermission(models.PermDashboardRead))
 		{
 			dashboardRoutes.GET("", handlers.GetDashboardSummary)
 		}
+
+		// Roles and their permissions. They are shared by every organisation,
+		// so only super-admins change them; admins read them to assign roles.
+		roleRoutes := authenticated.Group("/roles")
+		{
+			roleRoutes.GET("", middleware.RequirePermission(models.PermUsersManage), handlers.GetRoles)
+			roleRoutes.GET("/permissions", middleware.RequirePermission(models.PermRolesManage), handlers.GetPermissions)
+			roleRoutes.POST("", middleware.RequirePermission(models.PermRolesManage), handlers.AddRole)
+			roleRoutes.PUT("/:name", middleware.RequirePermission(models.PermRolesManage), handlers.UpdateRole)
+			roleRoutes.DELETE("/:name", middleware.RequirePermission(models.PermRolesManage), handlers.DeleteRole)
+		}
+
+		// Audit log of changes to module records
+		authenticated.GET("/audit", middleware.RequirePermission(models.PermAuditRead), handlers.GetAuditLog)
+
+		// Organisations (super-admins only)
+		organisationRoutes := authenticated.Group("/organisations")
+		organisationRoutes.Use(middleware.RequirePermission(models.PermOrganisationsManage))
+		{
+			organisationRoutes.GET("", handlers.GetOrganisations)
+			organisationRoutes.POST("", handlers.AddOrganisation)
+			organisationRoutes.PUT("/:id", handlers.UpdateOrganisation)
+			organisationRoutes.DELETE("/:id", handlers.DeleteOrganisation)
+		}
 	}
 
 	// Run the server
 	log.Fatal(router.Run(":8080")) // Listen and serve on 0.0.0.0:8080
 }
+
+// modulePermissions returns middleware requiring the read and the write
+// permission of a data entry module, and for restoring deleted entries the
+// write permission together with models.PermDeletedRecordsManage.
+func modulePermissions(module string) (read, write, restore gin.HandlerFunc) {
+	return middleware.RequirePermission(models.ReadPermission(module)),
+		middleware.RequirePermission(models.WritePermission(module)),
+		middleware.RequirePermission(models.WritePermission(module), models.PermDeletedRecordsManage)
+}
+
+// checkSSORoles checks that the roles single sign-on can grant exist. The
+// superadmin role is never granted that way.
+func checkSSORoles(provider *oidc.Provider) error {
+	for _, role := range provider.Roles() {
+		if role == models.RoleSuperAdmin {
+			return fmt.Errorf("the %s role can't be granted through single sign-on", role)
+		}
+		exists, err := models.RoleExists(role)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("unknown role %q", role)
+		}
+	}
+	return nil
+}
@@ -2,14 +2,21 @@ package middleware
 
 import (
 	"carbon-footprint-tracker/config"
+	"carbon-footprint-tracker/models"
 	"carbon-footprint-tracker/utils"
+	"database/sql"
+	"errors"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/dgrijalva/jwt-go"
 	"github.com/gin-gonic/gin"
 )
 
+// AuthRequired authenticates the request by the Bearer access token or API
+// key (see models.APIKey) in its Authorization header.
 func AuthRequired() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		tokenString := c.GetHeader("Authorization")
@@ -20,6 +27,10 @@ func AuthRequired() gin.HandlerFunc {
 		}
 
 		tokenString = strings.TrimPrefix(tokenString, "Bearer ")
+		if strings.HasPrefix(tokenString, models.APIKeyPrefix) {
+			authenticateAPIKey(c, tokenString)
+			return
+		}
 
 		claims := &utils.Claims{}
 		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
@@ -32,12 +43,125 @@ func AuthRequired() gin.HandlerFunc {
 			return
 		}
 
+		// Re-check the account so that logout, role, permission and location changes and deletions take effect immediately.
+		state, err := models.GetTokenState(claims.UserID, claims.Id)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: Account no longer exists"})
+				c.Abort()
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify token", "details": err.Error()})
+			c.Abort()
+			return
+		}
+		if state.Revoked || state.TokenVersion != claims.TokenVersion || state.OrgID != claims.OrgID {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: Token has been revoked"})
+			c.Abort()
+			return
+		}
+
+		// Accounts that must use two-factor authentication can only reach the
+		// /auth routes, to set it up or log out, until they have. The identity
+		// provider is responsible for it on single sign-on.
+		if config.MFARequired(state.Role) && !state.MFAEnabled && !state.SSO && !strings.HasPrefix(c.FullPath(), "/auth/") {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Two-factor authentication must be set up for this account", "mfa_setup_required": true})
+			c.Abort()
+			return
+		}
+
+		orgID, ok := requestOrganisation(c, state.Role, claims.OrgID)
+		if !ok {
+			c.Abort()
+			return
+		}
+
 		c.Set("userID", claims.UserID)
-		c.Set("userRole", claims.Role)
+		c.Set("orgID", orgID) // Every query of the request is limited to this organisation
+		c.Set("userRole", state.Role)
+		c.Set("userPermissions", state.Permissions)
+		c.Set("userLocations", state.LocationScope())
+		c.Set("tokenID", claims.Id)
+		c.Set("tokenExpiresAt", time.Unix(claims.ExpiresAt, 0))
 		c.Next()
 	}
 }
 
+// authenticateAPIKey is AuthRequired for API keys. The request acts within
+// the key's organisation, with the key's permissions and locations; there is
+// no user, so the /auth routes can't be used.
+func authenticateAPIKey(c *gin.Context, key string) {
+	if strings.HasPrefix(c.FullPath(), "/auth/") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API keys can't be used for account routes"})
+		c.Abort()
+		return
+	}
+
+	apiKey, err := models.AuthenticateAPIKey(key)
+	if err != nil {
+		if errors.Is(err, models.ErrAPIKeyInvalid) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: Invalid, expired or revoked API key"})
+			c.Abort()
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify API key", "details": err.Error()})
+		c.Abort()
+		return
+	}
+
+	orgID, ok := requestOrganisation(c, "", apiKey.OrgID)
+	if !ok {
+		c.Abort()
+		return
+	}
+
+	var locations []string // Every location
+	if len(apiKey.Locations) > 0 {
+		locations = apiKey.Locations
+	}
+	c.Set("apiKeyID", apiKey.ID)
+	c.Set("orgID", orgID)
+	c.Set("userPermissions", apiKey.Permissions)
+	c.Set("userLocations", locations)
+	c.Next()
+}
+
+// OrganisationHeader lets super-admins act within another organisation than
+// their own, e.g. to invite its first admin.
+const OrganisationHeader = "X-Organisation-ID"
+
+// requestOrganisation returns the organisation the request acts in: the
+// user's own, or for super-admins the one named by OrganisationHeader. It
+// responds with an error and returns false if that organisation is invalid.
+func requestOrganisation(c *gin.Context, role string, orgID int) (int, bool) {
+	header := c.GetHeader(OrganisationHeader)
+	if header == "" {
+		return orgID, true
+	}
+	if role != models.RoleSuperAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only super-admins can act within another organisation"})
+		return 0, false
+	}
+
+	id, err := strconv.Atoi(header)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + OrganisationHeader + " header"})
+		return 0, false
+	}
+	exists, err := models.OrganisationExists(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check organisation", "details": err.Error()})
+		return 0, false
+	}
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organisation not found"})
+		return 0, false
+	}
+	return id, true
+}
+
+// AuthorizeRoles allows only users holding one of allowedRoles. Routes check
+// permissions with RequirePermission instead, so that custom roles work.
 func AuthorizeRoles(allowedRoles ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userRole, exists := c.Get("userRole")
@@ -65,3 +189,41 @@ func AuthorizeRoles(allowedRoles ...string) gin.HandlerFunc {
 		c.Abort()
 	}
 }
+
+// RequirePermission allows only users whose role grants every one of
+// permissions (see models.Permissions).
+func RequirePermission(permissions ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		granted, exists := c.Get("userPermissions")
+		if !exists {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Permission information not found in context"})
+			c.Abort()
+			return
+		}
+
+		grantedList, ok := granted.([]string)
+		if !ok {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid permission type in context"})
+			c.Abort()
+			return
+		}
+
+		for _, permission := range permissions {
+			if !hasPermission(grantedList, permission) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden: Missing permission " + permission})
+				c.Abort()
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+func hasPermission(granted []string, permission string) bool {
+	for _, p := range granted {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
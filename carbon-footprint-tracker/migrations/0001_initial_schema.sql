@@ -0,0 +1,224 @@
+-- Initial schema matching the Go models.
+-- Tables are created only if missing so that existing databases whose tables
+-- already match the models can adopt migrations without losing data.
+
+-- Users
+CREATE TABLE IF NOT EXISTS users (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    email VARCHAR(255) NOT NULL UNIQUE,
+    password_hash VARCHAR(255) NOT NULL,
+    role VARCHAR(50) NOT NULL CHECK (role IN ('admin', 'staff', 'viewer')),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Emission Factor Library
+-- A factor applies to records dated between valid_from and valid_to (inclusive, NULL = open-ended).
+CREATE TABLE IF NOT EXISTS emission_factors (
+    id SERIAL PRIMARY KEY,
+    source VARCHAR(255) NOT NULL, -- e.g., 'Grid', 'Diesel', 'Landfill'
+    category VARCHAR(100) NOT NULL, -- e.g., 'Electricity', 'Fuel', 'Waste'
+    unit VARCHAR(50) NOT NULL, -- e.g., 'kgCO2e/kWh'
+    value DOUBLE PRECISION NOT NULL,
+    valid_from DATE NOT NULL,
+    valid_to DATE,
+    reference TEXT,
+    CHECK (valid_to IS NULL OR valid_to >= valid_from)
+);
+CREATE INDEX IF NOT EXISTS idx_emission_factors_lookup ON emission_factors (category, source, valid_from);
+
+-- Population
+CREATE TABLE IF NOT EXISTS population (
+    id SERIAL PRIMARY KEY,
+    registered_count INT NOT NULL CHECK (registered_count >= 0),
+    floating_count INT NOT NULL DEFAULT 0 CHECK (floating_count >= 0),
+    date DATE NOT NULL,
+    location VARCHAR(255) NOT NULL DEFAULT 'Overall'
+);
+CREATE INDEX IF NOT EXISTS idx_population_date ON population (date);
+CREATE INDEX IF NOT EXISTS idx_population_location_date ON population (location, date);
+
+-- Electric Consumption
+CREATE TABLE IF NOT EXISTS electric_consumption (
+    id SERIAL PRIMARY KEY,
+    date DATE NOT NULL,
+    location VARCHAR(255) NOT NULL DEFAULT 'Overall',
+    source VARCHAR(100) NOT NULL, -- 'Main Board', 'Diesel Generator', 'Biofuel Generator', 'Solar Generation'
+    dg_capacity_kva DOUBLE PRECISION CHECK (dg_capacity_kva >= 0),
+    running_time_hours DOUBLE PRECISION CHECK (running_time_hours >= 0),
+    fuel_consumed_liters DOUBLE PRECISION CHECK (fuel_consumed_liters >= 0),
+    fuel_type VARCHAR(50), -- 'Diesel', 'Biofuel'
+    energy_generated_dg_kwh DOUBLE PRECISION CHECK (energy_generated_dg_kwh >= 0),
+    grid_electricity_used_kwh DOUBLE PRECISION CHECK (grid_electricity_used_kwh >= 0),
+    electricity_bill_kwh DOUBLE PRECISION CHECK (electricity_bill_kwh >= 0),
+    electricity_bill_cost_inr DOUBLE PRECISION CHECK (electricity_bill_cost_inr >= 0),
+    electrical_appliances_count INT CHECK (electrical_appliances_count >= 0),
+    solar_generated_kwh DOUBLE PRECISION CHECK (solar_generated_kwh >= 0),
+    remarks TEXT,
+    co2e_kg DOUBLE PRECISION,
+    emission_factor_id INT REFERENCES emission_factors(id) ON DELETE SET NULL
+);
+CREATE INDEX IF NOT EXISTS idx_electric_consumption_date ON electric_consumption (date);
+CREATE INDEX IF NOT EXISTS idx_electric_consumption_location_date ON electric_consumption (location, date);
+
+-- Transport
+CREATE TABLE IF NOT EXISTS transport (
+    id SERIAL PRIMARY KEY,
+    date DATE NOT NULL,
+    event_area_location VARCHAR(255) NOT NULL,
+    vehicle_type VARCHAR(100) NOT NULL,
+    fuel_type VARCHAR(50) NOT NULL, -- 'Diesel', 'Petrol', 'Biofuel', 'Electric'
+    vehicle_number VARCHAR(50),
+    start_location VARCHAR(255),
+    end_location VARCHAR(255),
+    distance_km DOUBLE PRECISION NOT NULL CHECK (distance_km >= 0),
+    fuel_liters DOUBLE PRECISION NOT NULL CHECK (fuel_liters >= 0),
+    people_travelled_count INT CHECK (people_travelled_count >= 0),
+    fuel_efficiency_km_per_liter DOUBLE PRECISION CHECK (fuel_efficiency_km_per_liter >= 0),
+    remarks TEXT,
+    co2e_kg DOUBLE PRECISION,
+    emission_factor_id INT REFERENCES emission_factors(id) ON DELETE SET NULL
+);
+CREATE INDEX IF NOT EXISTS idx_transport_date ON transport (date);
+CREATE INDEX IF NOT EXISTS idx_transport_location_date ON transport (event_area_location, date);
+
+-- Water Consumption
+CREATE TABLE IF NOT EXISTS water_consumption (
+    id SERIAL PRIMARY KEY,
+    date DATE NOT NULL,
+    location VARCHAR(255) NOT NULL DEFAULT 'Overall',
+    water_source VARCHAR(100),
+    cumulative_meter_reading DOUBLE PRECISION CHECK (cumulative_meter_reading >= 0),
+    total_consumption_kld DOUBLE PRECISION NOT NULL CHECK (total_consumption_kld >= 0),
+    per_capita_consumption_lpd DOUBLE PRECISION CHECK (per_capita_consumption_lpd >= 0),
+    usage_type VARCHAR(100),
+    remarks TEXT,
+    co2e_kg DOUBLE PRECISION,
+    emission_factor_id INT REFERENCES emission_factors(id) ON DELETE SET NULL
+);
+CREATE INDEX IF NOT EXISTS idx_water_consumption_date ON water_consumption (date);
+CREATE INDEX IF NOT EXISTS idx_water_consumption_location_date ON water_consumption (location, date);
+
+-- Water Treatment
+CREATE TABLE IF NOT EXISTS water_treatment (
+    id SERIAL PRIMARY KEY,
+    date DATE NOT NULL,
+    location VARCHAR(255) NOT NULL DEFAULT 'Overall',
+    treated_liters_per_day DOUBLE PRECISION CHECK (treated_liters_per_day >= 0),
+    ultra_filtration_liters_per_day DOUBLE PRECISION CHECK (ultra_filtration_liters_per_day >= 0),
+    percentage_water_reused DOUBLE PRECISION CHECK (percentage_water_reused BETWEEN 0 AND 100),
+    electricity_used_kwh DOUBLE PRECISION CHECK (electricity_used_kwh >= 0),
+    chemicals_used_description TEXT,
+    chemicals_used_quantity_kg DOUBLE PRECISION CHECK (chemicals_used_quantity_kg >= 0),
+    remarks TEXT,
+    co2e_kg DOUBLE PRECISION,
+    emission_factor_id INT REFERENCES emission_factors(id) ON DELETE SET NULL
+);
+CREATE INDEX IF NOT EXISTS idx_water_treatment_date ON water_treatment (date);
+CREATE INDEX IF NOT EXISTS idx_water_treatment_location_date ON water_treatment (location, date);
+
+-- Waste Generation
+CREATE TABLE IF NOT EXISTS waste (
+    id SERIAL PRIMARY KEY,
+    date DATE NOT NULL,
+    collection_location VARCHAR(255) NOT NULL,
+    waste_type VARCHAR(100) NOT NULL, -- 'Biodegradable', 'Non-Biodegradable', 'Recyclable', 'Landfill'
+    sub_category VARCHAR(100), -- 'Food', 'Garden', 'Plastic', 'Paper', 'Glass', 'Metal', 'E-waste'
+    weight_kg DOUBLE PRECISION NOT NULL CHECK (weight_kg >= 0),
+    collection_method VARCHAR(100),
+    transport_mode VARCHAR(100),
+    destination VARCHAR(100),
+    remarks TEXT,
+    co2e_kg DOUBLE PRECISION,
+    emission_factor_id INT REFERENCES emission_factors(id) ON DELETE SET NULL
+);
+CREATE INDEX IF NOT EXISTS idx_waste_date ON waste (date);
+CREATE INDEX IF NOT EXISTS idx_waste_location_date ON waste (collection_location, date);
+
+-- Accommodation
+CREATE TABLE IF NOT EXISTS accommodation (
+    id SERIAL PRIMARY KEY,
+    date DATE NOT NULL,
+    participant_guest_name VARCHAR(255),
+    category VARCHAR(100), -- 'Staff', 'Student', 'VIP', 'Volunteer'
+    people_count INT NOT NULL CHECK (people_count >= 0),
+    accommodation_facility_name VARCHAR(255) NOT NULL,
+    accommodation_type VARCHAR(100), -- 'Hotel', 'Hostel', 'Guest House', 'Campus Stay'
+    room_type VARCHAR(100), -- 'Single', 'Double', 'Dormitory'
+    no_of_rooms INT CHECK (no_of_rooms >= 0),
+    nights INT NOT NULL CHECK (nights >= 0),
+    electricity_consumption_kwh DOUBLE PRECISION CHECK (electricity_consumption_kwh >= 0),
+    water_consumption_lpd DOUBLE PRECISION CHECK (water_consumption_lpd >= 0),
+    meals_provided BOOLEAN,
+    transport_mode_to_venue VARCHAR(100),
+    remarks TEXT,
+    co2e_kg DOUBLE PRECISION,
+    emission_factor_id INT REFERENCES emission_factors(id) ON DELETE SET NULL
+);
+CREATE INDEX IF NOT EXISTS idx_accommodation_date ON accommodation (date);
+CREATE INDEX IF NOT EXISTS idx_accommodation_location_date ON accommodation (accommodation_facility_name, date);
+
+-- Goods Purchased (Procurement)
+CREATE TABLE IF NOT EXISTS goods_purchased (
+    id SERIAL PRIMARY KEY,
+    date DATE NOT NULL,
+    location VARCHAR(255) NOT NULL DEFAULT 'Overall',
+    item_name VARCHAR(255) NOT NULL,
+    category VARCHAR(100), -- 'Stationery', 'Hardware', 'Furniture', etc.
+    quantity INT NOT NULL CHECK (quantity >= 0),
+    unit VARCHAR(50), -- e.g., 'pcs', 'kg', 'liters'
+    vendor_name VARCHAR(255),
+    origin VARCHAR(100), -- 'Local', 'Imported'
+    transport_mode VARCHAR(100), -- 'Road', 'Rail', 'Air'
+    transport_distance_km DOUBLE PRECISION CHECK (transport_distance_km >= 0),
+    bill_amount_inr DOUBLE PRECISION NOT NULL CHECK (bill_amount_inr >= 0),
+    bill_attachment_url TEXT,
+    packaging_type VARCHAR(100), -- 'Plastic', 'Paper', 'None'
+    is_recyclable BOOLEAN,
+    remarks TEXT,
+    co2e_kg DOUBLE PRECISION,
+    emission_factor_id INT REFERENCES emission_factors(id) ON DELETE SET NULL
+);
+CREATE INDEX IF NOT EXISTS idx_goods_purchased_date ON goods_purchased (date);
+CREATE INDEX IF NOT EXISTS idx_goods_purchased_location_date ON goods_purchased (location, date);
+
+-- Food Consumption
+CREATE TABLE IF NOT EXISTS food_consumption (
+    id SERIAL PRIMARY KEY,
+    date DATE NOT NULL,
+    location VARCHAR(255) NOT NULL DEFAULT 'Overall', -- 'Canteen', 'Hostel', 'Event'
+    food_item VARCHAR(255) NOT NULL,
+    quantity_cooked_kg_liter DOUBLE PRECISION NOT NULL CHECK (quantity_cooked_kg_liter >= 0),
+    no_of_meals_served INT CHECK (no_of_meals_served >= 0),
+    raw_material_source VARCHAR(100), -- 'Local', 'Market', 'Imported'
+    water_used_l_washing_cooking DOUBLE PRECISION CHECK (water_used_l_washing_cooking >= 0),
+    fuel_used_type VARCHAR(50), -- 'LPG', 'Firewood', 'Electricity'
+    fuel_used_quantity DOUBLE PRECISION CHECK (fuel_used_quantity >= 0),
+    remarks TEXT,
+    co2e_kg DOUBLE PRECISION,
+    emission_factor_id INT REFERENCES emission_factors(id) ON DELETE SET NULL
+);
+CREATE INDEX IF NOT EXISTS idx_food_consumption_date ON food_consumption (date);
+CREATE INDEX IF NOT EXISTS idx_food_consumption_location_date ON food_consumption (location, date);
+
+-- Default factors, seeded only into an empty library
+INSERT INTO emission_factors (source, category, unit, value, valid_from, reference)
+SELECT v.source, v.category, v.unit, v.value, v.valid_from::date, v.reference
+FROM (VALUES
+    ('Grid', 'Electricity', 'kgCO2e/kWh', 0.45, '2000-01-01', 'Default grid factor'),
+    ('Diesel', 'Fuel', 'kgCO2e/L', 2.68, '2000-01-01', 'Default diesel combustion factor'),
+    ('Petrol', 'Fuel', 'kgCO2e/L', 2.31, '2000-01-01', 'Default petrol combustion factor'),
+    ('Biofuel', 'Fuel', 'kgCO2e/L', 1.0, '2000-01-01', 'Default biofuel combustion factor'),
+    ('LPG', 'Fuel', 'kgCO2e/kg', 2.98, '2000-01-01', 'Default LPG combustion factor'),
+    ('Firewood', 'Fuel', 'kgCO2e/kg', 1.8, '2000-01-01', 'Default firewood combustion factor'),
+    ('Treatment and Distribution', 'Water', 'kgCO2e/L', 0.00034, '2000-01-01', 'Default water supply factor'),
+    ('Food Preparation', 'Water', 'kgCO2e/L', 0.0001, '2000-01-01', 'Default kitchen water factor'),
+    ('Biodegradable', 'Waste', 'kgCO2e/kg', 0.1, '2000-01-01', 'Default composting factor'),
+    ('Recyclable', 'Waste', 'kgCO2e/kg', -0.1, '2000-01-01', 'Default recycling credit'),
+    ('Landfill', 'Waste', 'kgCO2e/kg', 0.5, '2000-01-01', 'Default landfill factor'),
+    ('E-waste', 'Waste', 'kgCO2e/kg', 2.0, '2000-01-01', 'Default e-waste factor'),
+    ('Spend', 'Goods', 'kgCO2e/INR', 0.05, '2000-01-01', 'Default spend-based factor'),
+    ('Treatment Chemicals', 'Chemicals', 'kgCO2e/kg', 1.0, '2000-01-01', 'Default treatment chemicals factor')
+) AS v (source, category, unit, value, valid_from, reference)
+WHERE NOT EXISTS (SELECT 1 FROM emission_factors);
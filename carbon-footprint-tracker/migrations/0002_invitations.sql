@@ -0,0 +1,13 @@
+-- Invitations let admins grant elevated roles; public sign-up only creates viewers.
+CREATE TABLE invitations (
+    id SERIAL PRIMARY KEY,
+    email VARCHAR(255) NOT NULL,
+    role VARCHAR(50) NOT NULL CHECK (role IN ('admin', 'staff', 'viewer')),
+    token_hash CHAR(64) NOT NULL UNIQUE, -- SHA-256 of the token sent to the invitee
+    invited_by INT REFERENCES users(id) ON DELETE SET NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    accepted_at TIMESTAMPTZ,
+    accepted_user_id INT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX idx_invitations_email ON invitations (LOWER(email));
@@ -0,0 +1,15 @@
+-- TOTP two-factor authentication. mfa_secret is set when enrollment starts
+-- and mfa_enabled once the user has confirmed a code from their app.
+ALTER TABLE users ADD COLUMN mfa_secret VARCHAR(64);
+ALTER TABLE users ADD COLUMN mfa_enabled BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE users ADD COLUMN mfa_last_step BIGINT;                        -- Last TOTP time step accepted, so codes can't be replayed
+ALTER TABLE users ADD COLUMN mfa_failed_attempts INT NOT NULL DEFAULT 0; -- Wrong codes since the last accepted one
+
+CREATE TABLE mfa_recovery_codes (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash CHAR(64) NOT NULL, -- SHA-256 of the normalized code
+    used_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, code_hash)
+);
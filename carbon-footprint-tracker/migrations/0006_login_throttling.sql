@@ -0,0 +1,15 @@
+-- Failed password attempts per account. Failures add an exponentially
+-- growing delay (locked_until) and enough of them lock the account.
+ALTER TABLE users ADD COLUMN failed_attempts INT NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN last_failed_login_at TIMESTAMPTZ;
+ALTER TABLE users ADD COLUMN locked_until TIMESTAMPTZ;
+ALTER TABLE users ADD COLUMN last_login_at TIMESTAMPTZ;
+
+-- Failed logins per client IP, whichever account they were for.
+CREATE TABLE login_ip_attempts (
+    ip VARCHAR(45) PRIMARY KEY,
+    failed_attempts INT NOT NULL DEFAULT 0,
+    last_failed_at TIMESTAMPTZ,
+    blocked_until TIMESTAMPTZ
+);
+CREATE INDEX idx_login_ip_attempts_last_failed_at ON login_ip_attempts (last_failed_at);
@@ -0,0 +1,7 @@
+-- Locations a user is limited to. Staff and viewers without any rows can
+-- access every location; admins are never limited.
+CREATE TABLE user_locations (
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    location VARCHAR(255) NOT NULL,
+    PRIMARY KEY (user_id, location)
+);
@@ -0,0 +1,43 @@
+-- Roles are named sets of permissions, managed through /roles. The three
+-- original roles are kept as system roles that can't be deleted; admin always
+-- holds every permission.
+CREATE TABLE roles (
+    name VARCHAR(50) PRIMARY KEY,
+    description TEXT,
+    is_system BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE role_permissions (
+    role VARCHAR(50) NOT NULL REFERENCES roles(name) ON DELETE CASCADE ON UPDATE CASCADE,
+    permission VARCHAR(100) NOT NULL, -- e.g. 'goods:write', see models.Permissions
+    PRIMARY KEY (role, permission)
+);
+
+INSERT INTO roles (name, description, is_system) VALUES
+    ('admin', 'Full access, including user and role management', TRUE),
+    ('staff', 'Enters and edits data for every module', TRUE),
+    ('viewer', 'Reads the dashboard and reports', TRUE);
+
+INSERT INTO role_permissions (role, permission)
+SELECT 'staff', module || ':' || access
+FROM unnest(ARRAY['electric', 'population', 'transport', 'water_consumption', 'water_treatment',
+                  'waste', 'accommodation', 'goods', 'food_consumption']) AS module,
+     unnest(ARRAY['read', 'write']) AS access;
+
+INSERT INTO role_permissions (role, permission) VALUES
+    ('staff', 'emission_factors:read'),
+    ('staff', 'reports:read'),
+    ('staff', 'reports:export'),
+    ('staff', 'dashboard:read'),
+    ('viewer', 'emission_factors:read'),
+    ('viewer', 'reports:read'),
+    ('viewer', 'reports:export'),
+    ('viewer', 'dashboard:read');
+
+-- Roles are no longer a fixed list, so reference the roles table instead.
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_role_check;
+ALTER TABLE users ADD CONSTRAINT users_role_fkey FOREIGN KEY (role) REFERENCES roles(name) ON UPDATE CASCADE;
+ALTER TABLE invitations DROP CONSTRAINT IF EXISTS invitations_role_check;
+ALTER TABLE invitations ADD CONSTRAINT invitations_role_fkey FOREIGN KEY (role) REFERENCES roles(name) ON UPDATE CASCADE ON DELETE CASCADE;
@@ -0,0 +1,64 @@
+-- One deployment serves several institutions. Users, invitations, population
+-- and activity records each belong to an organisation; emission factors and
+-- roles are shared by all of them.
+CREATE TABLE organisations (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Existing data belongs to the campus the deployment used to serve.
+INSERT INTO organisations (id, name) VALUES (1, 'Default Organisation');
+SELECT setval(pg_get_serial_sequence('organisations', 'id'), 1);
+
+-- The default only backfills existing rows; it is dropped below so that new
+-- rows must name their organisation.
+ALTER TABLE users ADD COLUMN org_id INT NOT NULL DEFAULT 1 REFERENCES organisations(id);
+ALTER TABLE invitations ADD COLUMN org_id INT NOT NULL DEFAULT 1 REFERENCES organisations(id) ON DELETE CASCADE;
+ALTER TABLE population ADD COLUMN org_id INT NOT NULL DEFAULT 1 REFERENCES organisations(id);
+ALTER TABLE electric_consumption ADD COLUMN org_id INT NOT NULL DEFAULT 1 REFERENCES organisations(id);
+ALTER TABLE transport ADD COLUMN org_id INT NOT NULL DEFAULT 1 REFERENCES organisations(id);
+ALTER TABLE water_consumption ADD COLUMN org_id INT NOT NULL DEFAULT 1 REFERENCES organisations(id);
+ALTER TABLE water_treatment ADD COLUMN org_id INT NOT NULL DEFAULT 1 REFERENCES organisations(id);
+ALTER TABLE waste ADD COLUMN org_id INT NOT NULL DEFAULT 1 REFERENCES organisations(id);
+ALTER TABLE accommodation ADD COLUMN org_id INT NOT NULL DEFAULT 1 REFERENCES organisations(id);
+ALTER TABLE goods_purchased ADD COLUMN org_id INT NOT NULL DEFAULT 1 REFERENCES organisations(id);
+ALTER TABLE food_consumption ADD COLUMN org_id INT NOT NULL DEFAULT 1 REFERENCES organisations(id);
+
+ALTER TABLE users ALTER COLUMN org_id DROP DEFAULT;
+ALTER TABLE invitations ALTER COLUMN org_id DROP DEFAULT;
+ALTER TABLE population ALTER COLUMN org_id DROP DEFAULT;
+ALTER TABLE electric_consumption ALTER COLUMN org_id DROP DEFAULT;
+ALTER TABLE transport ALTER COLUMN org_id DROP DEFAULT;
+ALTER TABLE water_consumption ALTER COLUMN org_id DROP DEFAULT;
+ALTER TABLE water_treatment ALTER COLUMN org_id DROP DEFAULT;
+ALTER TABLE waste ALTER COLUMN org_id DROP DEFAULT;
+ALTER TABLE accommodation ALTER COLUMN org_id DROP DEFAULT;
+ALTER TABLE goods_purchased ALTER COLUMN org_id DROP DEFAULT;
+ALTER TABLE food_consumption ALTER COLUMN org_id DROP DEFAULT;
+
+CREATE INDEX idx_users_org_id ON users (org_id);
+CREATE INDEX idx_invitations_org_id ON invitations (org_id);
+CREATE INDEX idx_population_org_date ON population (org_id, date);
+CREATE INDEX idx_electric_consumption_org_date ON electric_consumption (org_id, date);
+CREATE INDEX idx_transport_org_date ON transport (org_id, date);
+CREATE INDEX idx_water_consumption_org_date ON water_consumption (org_id, date);
+CREATE INDEX idx_water_treatment_org_date ON water_treatment (org_id, date);
+CREATE INDEX idx_waste_org_date ON waste (org_id, date);
+CREATE INDEX idx_accommodation_org_date ON accommodation (org_id, date);
+CREATE INDEX idx_goods_purchased_org_date ON goods_purchased (org_id, date);
+CREATE INDEX idx_food_consumption_org_date ON food_consumption (org_id, date);
+
+-- Super-admins manage organisations and the data every organisation shares:
+-- the emission factor library and the role definitions. Admins keep full
+-- control of their own organisation.
+INSERT INTO roles (name, description, is_system) VALUES
+    ('superadmin', 'Manages organisations, roles and emission factors across the deployment', TRUE);
+UPDATE roles SET description = 'Full access to their organisation, including user management' WHERE name = 'admin';
+DELETE FROM role_permissions WHERE permission IN ('organisations:manage', 'roles:manage', 'emission_factors:write');
+
+-- The deployment's first admin becomes its super-admin, so that someone keeps
+-- every ability admins used to have. Other admins stay admins of the default
+-- organisation; super-admins can promote them with PUT /users/:id.
+UPDATE users SET role = 'superadmin' WHERE id = (SELECT MIN(id) FROM users WHERE role = 'admin');
@@ -0,0 +1,17 @@
+-- Every create, update and delete of a module record, written in the same
+-- transaction as the change. user_id isn't a foreign key so that the trail
+-- outlives deleted users.
+CREATE TABLE audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    org_id INT NOT NULL REFERENCES organisations(id) ON DELETE CASCADE,
+    user_id INT, -- NULL for changes made by the system
+    action VARCHAR(10) NOT NULL CHECK (action IN ('create', 'update', 'delete')),
+    table_name VARCHAR(50) NOT NULL,
+    record_id INT NOT NULL,
+    before JSONB, -- The row before the change, NULL for creates
+    after JSONB,  -- The row after the change, NULL for deletes
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX idx_audit_log_org_created_at ON audit_log (org_id, created_at);
+CREATE INDEX idx_audit_log_org_record ON audit_log (org_id, table_name, record_id);
+CREATE INDEX idx_audit_log_org_user ON audit_log (org_id, user_id);
@@ -0,0 +1,27 @@
+-- Deleting a module record only marks it as deleted, so that it can be
+-- restored; the purge job removes it for good after the retention window.
+-- deleted_by isn't a foreign key, like audit_log.user_id.
+ALTER TABLE population ADD COLUMN deleted_at TIMESTAMPTZ, ADD COLUMN deleted_by INT;
+ALTER TABLE electric_consumption ADD COLUMN deleted_at TIMESTAMPTZ, ADD COLUMN deleted_by INT;
+ALTER TABLE transport ADD COLUMN deleted_at TIMESTAMPTZ, ADD COLUMN deleted_by INT;
+ALTER TABLE water_consumption ADD COLUMN deleted_at TIMESTAMPTZ, ADD COLUMN deleted_by INT;
+ALTER TABLE water_treatment ADD COLUMN deleted_at TIMESTAMPTZ, ADD COLUMN deleted_by INT;
+ALTER TABLE waste ADD COLUMN deleted_at TIMESTAMPTZ, ADD COLUMN deleted_by INT;
+ALTER TABLE accommodation ADD COLUMN deleted_at TIMESTAMPTZ, ADD COLUMN deleted_by INT;
+ALTER TABLE goods_purchased ADD COLUMN deleted_at TIMESTAMPTZ, ADD COLUMN deleted_by INT;
+ALTER TABLE food_consumption ADD COLUMN deleted_at TIMESTAMPTZ, ADD COLUMN deleted_by INT;
+
+-- For the purge job; live rows aren't indexed.
+CREATE INDEX idx_population_deleted_at ON population (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX idx_electric_consumption_deleted_at ON electric_consumption (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX idx_transport_deleted_at ON transport (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX idx_water_consumption_deleted_at ON water_consumption (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX idx_water_treatment_deleted_at ON water_treatment (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX idx_waste_deleted_at ON waste (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX idx_accommodation_deleted_at ON accommodation (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX idx_goods_purchased_deleted_at ON goods_purchased (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX idx_food_consumption_deleted_at ON food_consumption (deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- Restores and purges are recorded too.
+ALTER TABLE audit_log DROP CONSTRAINT audit_log_action_check;
+ALTER TABLE audit_log ADD CONSTRAINT audit_log_action_check CHECK (action IN ('create', 'update', 'delete', 'restore', 'purge'));
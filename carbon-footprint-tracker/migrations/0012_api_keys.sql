@@ -0,0 +1,21 @@
+-- Keys for unattended clients, e.g. BMS or telematics exports posting
+-- readings. Only the hash of a key is stored; its prefix is kept in plain
+-- text so that admins can tell keys apart.
+CREATE TABLE api_keys (
+    id SERIAL PRIMARY KEY,
+    org_id INT NOT NULL REFERENCES organisations(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    prefix VARCHAR(20) NOT NULL,
+    key_hash CHAR(64) NOT NULL UNIQUE, -- SHA-256 of the key
+    permissions TEXT[] NOT NULL,       -- Module permissions only, e.g. electric:write
+    locations TEXT[] NOT NULL DEFAULT '{}', -- Empty for every location
+    created_by INT REFERENCES users(id) ON DELETE SET NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    last_used_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX idx_api_keys_org ON api_keys (org_id);
+
+-- Changes made with an API key are attributed to it rather than a user.
+ALTER TABLE audit_log ADD COLUMN api_key_id INT;
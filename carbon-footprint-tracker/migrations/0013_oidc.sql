@@ -0,0 +1,4 @@
+-- Users signing in through the campus identity provider are linked to it by
+-- their subject and have no password of their own.
+ALTER TABLE users ALTER COLUMN password_hash DROP NOT NULL;
+ALTER TABLE users ADD COLUMN oidc_subject VARCHAR(255) UNIQUE;
@@ -0,0 +1,8 @@
+-- Solar generation is split into what the site used itself and what was
+-- exported to the grid, and the grid emissions it avoids are stored alongside
+-- the footprint. Existing records get avoided_co2e_kg from the footprint
+-- backfill when the server next starts, see models.FootprintsMissing.
+ALTER TABLE electric_consumption
+    ADD COLUMN solar_self_consumed_kwh DOUBLE PRECISION CHECK (solar_self_consumed_kwh >= 0),
+    ADD COLUMN solar_exported_kwh DOUBLE PRECISION CHECK (solar_exported_kwh >= 0),
+    ADD COLUMN avoided_co2e_kg DOUBLE PRECISION;
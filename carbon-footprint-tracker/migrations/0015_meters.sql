@@ -0,0 +1,37 @@
+-- Electricity meters staff read on site. Consumption between consecutive
+-- cumulative readings is recorded as grid electricity in electric_consumption.
+CREATE TABLE meters (
+    id SERIAL PRIMARY KEY,
+    org_id INT NOT NULL REFERENCES organisations(id) ON DELETE CASCADE,
+    meter_number VARCHAR(100) NOT NULL, -- As printed on the meter
+    location VARCHAR(255) NOT NULL,
+    multiplier DOUBLE PRECISION NOT NULL DEFAULT 1 CHECK (multiplier > 0), -- kWh per register unit, e.g. the CT ratio
+    rollover_limit DOUBLE PRECISION CHECK (rollover_limit > 0),             -- Register value that wraps to zero, e.g. 100000
+    remarks TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (org_id, meter_number)
+);
+
+-- A meter with readings can't be deleted, so that the consumption derived
+-- from them stays traceable.
+CREATE TABLE meter_readings (
+    id SERIAL PRIMARY KEY,
+    org_id INT NOT NULL REFERENCES organisations(id) ON DELETE CASCADE,
+    meter_id INT NOT NULL REFERENCES meters(id),
+    date DATE NOT NULL,
+    reading DOUBLE PRECISION NOT NULL CHECK (reading >= 0),
+    -- When the meter was replaced since the previous reading: the old
+    -- register's final reading and the new register's reading when fitted.
+    replaced_final_reading DOUBLE PRECISION CHECK (replaced_final_reading >= 0),
+    replaced_start_reading DOUBLE PRECISION CHECK (replaced_start_reading >= 0),
+    previous_reading_id INT REFERENCES meter_readings(id),
+    consumption_kwh DOUBLE PRECISION, -- Since the previous reading; NULL for a meter's first reading
+    rolled_over BOOLEAN NOT NULL DEFAULT FALSE,
+    flag VARCHAR(50),                 -- 'negative_delta'; flagged readings create no consumption
+    electric_consumption_id INT REFERENCES electric_consumption(id) ON DELETE SET NULL,
+    created_by INT,                   -- Like audit_log.user_id
+    api_key_id INT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX idx_meter_readings_meter_date ON meter_readings (meter_id, date);
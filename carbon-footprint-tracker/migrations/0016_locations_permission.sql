@@ -0,0 +1,9 @@
+-- Whether a user is limited to their assigned locations is decided by the
+-- locations:all permission instead of the admin and superadmin role names,
+-- so that custom roles can be given every location too. Admin and
+-- superadmin hold it like every other permission of theirs, see models.Role;
+-- the rows only record that.
+INSERT INTO role_permissions (role, permission) VALUES
+    ('admin', 'locations:all'),
+    ('superadmin', 'locations:all')
+ON CONFLICT DO NOTHING;
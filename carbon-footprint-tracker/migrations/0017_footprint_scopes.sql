@@ -0,0 +1,37 @@
+-- Each record's footprint is also stored per GHG Protocol scope, so that
+-- scope totals can be summed in SQL from the figures stored when a record was
+-- written, like co2e_kg. Existing records, and any still without co2e_kg, are
+-- filled in by the footprint recompute the server runs at startup when it
+-- finds records without them.
+ALTER TABLE electric_consumption
+    ADD COLUMN co2e_scope1_kg DOUBLE PRECISION,
+    ADD COLUMN co2e_scope2_kg DOUBLE PRECISION,
+    ADD COLUMN co2e_scope3_kg DOUBLE PRECISION;
+ALTER TABLE transport
+    ADD COLUMN co2e_scope1_kg DOUBLE PRECISION,
+    ADD COLUMN co2e_scope2_kg DOUBLE PRECISION,
+    ADD COLUMN co2e_scope3_kg DOUBLE PRECISION;
+ALTER TABLE water_consumption
+    ADD COLUMN co2e_scope1_kg DOUBLE PRECISION,
+    ADD COLUMN co2e_scope2_kg DOUBLE PRECISION,
+    ADD COLUMN co2e_scope3_kg DOUBLE PRECISION;
+ALTER TABLE water_treatment
+    ADD COLUMN co2e_scope1_kg DOUBLE PRECISION,
+    ADD COLUMN co2e_scope2_kg DOUBLE PRECISION,
+    ADD COLUMN co2e_scope3_kg DOUBLE PRECISION;
+ALTER TABLE waste
+    ADD COLUMN co2e_scope1_kg DOUBLE PRECISION,
+    ADD COLUMN co2e_scope2_kg DOUBLE PRECISION,
+    ADD COLUMN co2e_scope3_kg DOUBLE PRECISION;
+ALTER TABLE accommodation
+    ADD COLUMN co2e_scope1_kg DOUBLE PRECISION,
+    ADD COLUMN co2e_scope2_kg DOUBLE PRECISION,
+    ADD COLUMN co2e_scope3_kg DOUBLE PRECISION;
+ALTER TABLE goods_purchased
+    ADD COLUMN co2e_scope1_kg DOUBLE PRECISION,
+    ADD COLUMN co2e_scope2_kg DOUBLE PRECISION,
+    ADD COLUMN co2e_scope3_kg DOUBLE PRECISION;
+ALTER TABLE food_consumption
+    ADD COLUMN co2e_scope1_kg DOUBLE PRECISION,
+    ADD COLUMN co2e_scope2_kg DOUBLE PRECISION,
+    ADD COLUMN co2e_scope3_kg DOUBLE PRECISION;
@@ -8,6 +8,7 @@ import (
 
 type Accommodation struct {
 	ID                        int             `json:"id"`
+	OrgID                     int             `json:"-"` // Owning organisation, implied by the request
 	Date                      time.Time       `json:"date"`
 	ParticipantGuestName      sql.NullString  `json:"participant_guest_name,omitempty"`
 	Category                  sql.NullString  `json:"category,omitempty"` // 'Staff', 'Student', 'VIP', 'Volunteer'
@@ -22,59 +23,117 @@ type Accommodation struct {
 	MealsProvided             sql.NullBool    `json:"meals_provided,omitempty"`
 	TransportModeToVenue      sql.NullString  `json:"transport_mode_to_venue,omitempty"`
 	Remarks                   sql.NullString  `json:"remarks,omitempty"`
+
+	Footprint
+	Deletion
+}
+
+func (a *Accommodation) Create(by Actor) error {
+	footprinter, err := NewFootprinter()
+	if err != nil {
+		return err
+	}
+	return inTx(func(tx Querier) error { return a.CreateWith(tx, by, footprinter) })
 }
 
-func (a *Accommodation) Create() error {
+// CreateWith inserts the record for by using db, which must be a transaction
+// so that the audit log entry is written with it. Its footprint is computed
+// with footprinter, see NewFootprinter.
+func (a *Accommodation) CreateWith(db Querier, by Actor, footprinter Footprinter) error {
+	a.OrgID = by.OrgID
+	if err := a.Footprint.computeWith(footprinter, a); err != nil {
+		return err
+	}
+
 	query := `INSERT INTO accommodation (
 		date, participant_guest_name, category, people_count, accommodation_facility_name,
 		accommodation_type, room_type, no_of_rooms, nights, electricity_consumption_kwh,
-		water_consumption_lpd, meals_provided, transport_mode_to_venue, remarks
-	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14) RETURNING id`
-	return config.DB.QueryRow(query,
+		water_consumption_lpd, meals_provided, transport_mode_to_venue, remarks, co2e_kg, emission_factor_id, co2e_scope1_kg, co2e_scope2_kg, co2e_scope3_kg, org_id
+	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20) RETURNING id`
+	err := db.QueryRow(query,
 		a.Date, a.ParticipantGuestName, a.Category, a.PeopleCount, a.AccommodationFacilityName,
 		a.AccommodationType, a.RoomType, a.NoOfRooms, a.Nights, a.ElectricityConsumptionKWH,
-		a.WaterConsumptionLPD, a.MealsProvided, a.TransportModeToVenue, a.Remarks,
+		a.WaterConsumptionLPD, a.MealsProvided, a.TransportModeToVenue, a.Remarks, a.CO2eKg, a.EmissionFactorID, a.Scope1CO2eKg, a.Scope2CO2eKg, a.Scope3CO2eKg, a.OrgID,
 	).Scan(&a.ID)
+	if err != nil {
+		return err
+	}
+	return by.auditCreate(db, TableAccommodation, a.ID)
+}
+
+func GetAllAccommodations(f ActivityFilter) ([]Accommodation, error) {
+	accommodations, _, err := ListAccommodations(ListOptions{ActivityFilter: f})
+	return accommodations, err
+}
+
+// ListAccommodations returns the accommodation entries matching o and the total number of matches.
+func ListAccommodations(o ListOptions) ([]Accommodation, int, error) {
+	accommodations := []Accommodation{}
+	err := EachAccommodation(o, func(x Accommodation) error {
+		accommodations = append(accommodations, x)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total, err := o.total(ListSpecs[TableAccommodation], len(accommodations))
+	if err != nil {
+		return nil, 0, err
+	}
+	return accommodations, total, nil
 }
 
-func GetAllAccommodations() ([]Accommodation, error) {
+// EachAccommodation calls fn for each of the accommodation entries matching o,
+// streaming rows from the database rather than loading them all at once.
+func EachAccommodation(o ListOptions, fn func(Accommodation) error) error {
+	spec := ListSpecs[TableAccommodation]
+	where, args, err := o.whereClause(spec)
+	if err != nil {
+		return err
+	}
+	order, queryArgs, err := o.orderClause(spec, args)
+	if err != nil {
+		return err
+	}
 	rows, err := config.DB.Query(`SELECT
-		id, date, participant_guest_name, category, people_count, accommodation_facility_name,
+		id, org_id, date, participant_guest_name, category, people_count, accommodation_facility_name,
 		accommodation_type, room_type, no_of_rooms, nights, electricity_consumption_kwh,
-		water_consumption_lpd, meals_provided, transport_mode_to_venue, remarks
-		FROM accommodation ORDER BY date DESC`)
+		water_consumption_lpd, meals_provided, transport_mode_to_venue, remarks, co2e_kg, emission_factor_id, deleted_at, deleted_by
+		FROM accommodation`+where+order, queryArgs...)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer rows.Close()
 
-	var accommodations []Accommodation
 	for rows.Next() {
 		a := Accommodation{}
 		err := rows.Scan(
-			&a.ID, &a.Date, &a.ParticipantGuestName, &a.Category, &a.PeopleCount, &a.AccommodationFacilityName,
+			&a.ID, &a.OrgID, &a.Date, &a.ParticipantGuestName, &a.Category, &a.PeopleCount, &a.AccommodationFacilityName,
 			&a.AccommodationType, &a.RoomType, &a.NoOfRooms, &a.Nights, &a.ElectricityConsumptionKWH,
-			&a.WaterConsumptionLPD, &a.MealsProvided, &a.TransportModeToVenue, &a.Remarks,
+			&a.WaterConsumptionLPD, &a.MealsProvided, &a.TransportModeToVenue, &a.Remarks, &a.CO2eKg, &a.EmissionFactorID, &a.DeletedAt, &a.DeletedBy,
 		)
 		if err != nil {
-			return nil, err
+			return err
+		}
+		if err := fn(a); err != nil {
+			return err
 		}
-		accommodations = append(accommodations, a)
 	}
-	return accommodations, nil
+	return rows.Err()
 }
 
-func GetAccommodationByID(id int) (*Accommodation, error) {
+func GetAccommodationByID(orgID, id int) (*Accommodation, error) {
 	a := &Accommodation{}
 	query := `SELECT
-		id, date, participant_guest_name, category, people_count, accommodation_facility_name,
+		id, org_id, date, participant_guest_name, category, people_count, accommodation_facility_name,
 		accommodation_type, room_type, no_of_rooms, nights, electricity_consumption_kwh,
-		water_consumption_lpd, meals_provided, transport_mode_to_venue, remarks
-		FROM accommodation WHERE id = $1`
-	err := config.DB.QueryRow(query, id).Scan(
-		&a.ID, &a.Date, &a.ParticipantGuestName, &a.Category, &a.PeopleCount, &a.AccommodationFacilityName,
+		water_consumption_lpd, meals_provided, transport_mode_to_venue, remarks, co2e_kg, emission_factor_id, deleted_at, deleted_by
+		FROM accommodation WHERE id = $1 AND org_id = $2 AND deleted_at IS NULL`
+	err := config.DB.QueryRow(query, id, orgID).Scan(
+		&a.ID, &a.OrgID, &a.Date, &a.ParticipantGuestName, &a.Category, &a.PeopleCount, &a.AccommodationFacilityName,
 		&a.AccommodationType, &a.RoomType, &a.NoOfRooms, &a.Nights, &a.ElectricityConsumptionKWH,
-		&a.WaterConsumptionLPD, &a.MealsProvided, &a.TransportModeToVenue, &a.Remarks,
+		&a.WaterConsumptionLPD, &a.MealsProvided, &a.TransportModeToVenue, &a.Remarks, &a.CO2eKg, &a.EmissionFactorID, &a.DeletedAt, &a.DeletedBy,
 	)
 	if err != nil {
 		return nil, err
@@ -82,22 +141,25 @@ func GetAccommodationByID(id int) (*Accommodation, error) {
 	return a, nil
 }
 
-func (a *Accommodation) Update() error {
+func (a *Accommodation) Update(by Actor) error {
+	if err := a.Footprint.compute(a); err != nil {
+		return err
+	}
+
 	query := `UPDATE accommodation SET
 		date=$1, participant_guest_name=$2, category=$3, people_count=$4, accommodation_facility_name=$5,
 		accommodation_type=$6, room_type=$7, no_of_rooms=$8, nights=$9, electricity_consumption_kwh=$10,
-		water_consumption_lpd=$11, meals_provided=$12, transport_mode_to_venue=$13, remarks=$14
-		WHERE id=$15`
-	_, err := config.DB.Exec(query,
-		a.Date, a.ParticipantGuestName, a.Category, a.PeopleCount, a.AccommodationFacilityName,
-		a.AccommodationType, a.RoomType, a.NoOfRooms, a.Nights, a.ElectricityConsumptionKWH,
-		a.WaterConsumptionLPD, a.MealsProvided, a.TransportModeToVenue, a.Remarks, a.ID,
-	)
-	return err
+		water_consumption_lpd=$11, meals_provided=$12, transport_mode_to_venue=$13, remarks=$14, co2e_kg=$15, emission_factor_id=$16, co2e_scope1_kg=$17, co2e_scope2_kg=$18, co2e_scope3_kg=$19
+		WHERE id=$20 AND org_id=$21 AND deleted_at IS NULL`
+	return by.update(TableAccommodation, a.ID, func(tx Querier) (sql.Result, error) {
+		return tx.Exec(query,
+			a.Date, a.ParticipantGuestName, a.Category, a.PeopleCount, a.AccommodationFacilityName,
+			a.AccommodationType, a.RoomType, a.NoOfRooms, a.Nights, a.ElectricityConsumptionKWH,
+			a.WaterConsumptionLPD, a.MealsProvided, a.TransportModeToVenue, a.Remarks, a.CO2eKg, a.EmissionFactorID, a.Scope1CO2eKg, a.Scope2CO2eKg, a.Scope3CO2eKg, a.ID, by.OrgID,
+		)
+	})
 }
 
-func DeleteAccommodation(id int) error {
-	query := `DELETE FROM accommodation WHERE id=$1`
-	_, err := config.DB.Exec(query, id)
-	return err
+func DeleteAccommodation(by Actor, id int) error {
+	return by.delete(TableAccommodation, id)
 }
@@ -0,0 +1,129 @@
+package models
+
+import (
+	"carbon-footprint-tracker/config"
+	"carbon-footprint-tracker/utils"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// APIKeyPrefix starts every API key, which tells them apart from access
+// tokens in the Authorization header.
+const APIKeyPrefix = "cft_"
+
+var (
+	ErrAPIKeyInvalid  = errors.New("API key is invalid, expired or revoked")
+	ErrAPIKeyNotFound = errors.New("API key not found")
+)
+
+// APIKey lets an unattended client use the module routes of an organisation,
+// with its own permissions and locations instead of a user's. Only the hash of
+// the key is stored; the key itself is shown once on creation.
+type APIKey struct {
+	ID          int           `json:"id"`
+	OrgID       int           `json:"org_id"`
+	Name        string        `json:"name"`
+	Prefix      string        `json:"prefix"` // The start of the key, to identify it
+	Permissions []string      `json:"permissions"`
+	Locations   []string      `json:"locations"` // Empty for every location
+	CreatedBy   sql.NullInt64 `json:"created_by,omitempty"`
+	ExpiresAt   time.Time     `json:"expires_at"`
+	LastUsedAt  sql.NullTime  `json:"last_used_at,omitempty"`
+	RevokedAt   sql.NullTime  `json:"revoked_at,omitempty"`
+	CreatedAt   time.Time     `json:"created_at"`
+}
+
+// IsModulePermission reports whether permission is the read or write
+// permission of a data entry module, the only ones API keys can hold.
+func IsModulePermission(permission string) bool {
+	for _, module := range PermissionModules {
+		if permission == ReadPermission(module) || permission == WritePermission(module) {
+			return true
+		}
+	}
+	return false
+}
+
+// Create generates a new key, stores its hash and returns the key.
+func (k *APIKey) Create() (string, error) {
+	secret, err := utils.GenerateSecureToken(32)
+	if err != nil {
+		return "", err
+	}
+	key := APIKeyPrefix + secret
+	k.Prefix = key[:len(APIKeyPrefix)+8]
+	if k.Locations == nil {
+		k.Locations = []string{}
+	}
+
+	err = config.DB.QueryRow(`INSERT INTO api_keys (org_id, name, prefix, key_hash, permissions, locations, created_by, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id, created_at`,
+		k.OrgID, k.Name, k.Prefix, utils.HashToken(key), pq.Array(k.Permissions), pq.Array(k.Locations), k.CreatedBy, k.ExpiresAt,
+	).Scan(&k.ID, &k.CreatedAt)
+	if err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+const apiKeyColumns = `id, org_id, name, prefix, permissions, locations, created_by, expires_at, last_used_at, revoked_at, created_at`
+
+func scanAPIKey(row interface{ Scan(...interface{}) error }) (*APIKey, error) {
+	k := &APIKey{}
+	err := row.Scan(&k.ID, &k.OrgID, &k.Name, &k.Prefix, pq.Array(&k.Permissions), pq.Array(&k.Locations),
+		&k.CreatedBy, &k.ExpiresAt, &k.LastUsedAt, &k.RevokedAt, &k.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// GetAllAPIKeys returns the API keys of the organisation orgID, including
+// expired and revoked ones.
+func GetAllAPIKeys(orgID int) ([]APIKey, error) {
+	rows, err := config.DB.Query(`SELECT `+apiKeyColumns+` FROM api_keys WHERE org_id = $1 ORDER BY created_at DESC`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []APIKey{}
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *k)
+	}
+	return keys, rows.Err()
+}
+
+// AuthenticateAPIKey returns the unexpired, unrevoked API key key and records
+// that it has been used. It returns ErrAPIKeyInvalid for any other key.
+func AuthenticateAPIKey(key string) (*APIKey, error) {
+	k, err := scanAPIKey(config.DB.QueryRow(`UPDATE api_keys SET last_used_at = NOW()
+		WHERE key_hash = $1 AND revoked_at IS NULL AND expires_at > NOW()
+		RETURNING `+apiKeyColumns, utils.HashToken(key)))
+	if err == sql.ErrNoRows {
+		return nil, ErrAPIKeyInvalid
+	}
+	return k, err
+}
+
+// RevokeAPIKey revokes the API key with id of the organisation orgID. The key
+// is kept, so that the changes made with it stay attributable.
+func RevokeAPIKey(orgID, id int) error {
+	result, err := config.DB.Exec(`UPDATE api_keys SET revoked_at = NOW() WHERE id = $1 AND org_id = $2 AND revoked_at IS NULL`, id, orgID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
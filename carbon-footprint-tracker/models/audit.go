@@ -0,0 +1,243 @@
+package models
+
+import (
+	"carbon-footprint-tracker/config"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Actions recorded in the audit log. Deletes only mark records as deleted,
+// until the purge job removes them for good.
+const (
+	AuditCreate  = "create"
+	AuditUpdate  = "update"
+	AuditDelete  = "delete"
+	AuditRestore = "restore"
+	AuditPurge   = "purge"
+)
+
+// Actor is who makes a change to a module record. Every change is recorded
+// in the audit log under the actor's organisation and user or API key.
+type Actor struct {
+	OrgID    int
+	UserID   int // 0 for changes made by the system or with an API key
+	APIKeyID int // Set for changes made with an API key
+}
+
+// AuditEntry is one change to a module record. Before and After hold the
+// whole row as stored, or null for creates and purges respectively.
+type AuditEntry struct {
+	ID        int64           `json:"id"`
+	OrgID     int             `json:"org_id"`
+	UserID    sql.NullInt64   `json:"user_id"`    // Kept when the user is deleted
+	APIKeyID  sql.NullInt64   `json:"api_key_id"` // Set instead of user_id for changes made with an API key
+	Action    string          `json:"action"`     // 'create', 'update', 'delete', 'restore' or 'purge'
+	Table     string          `json:"table"`
+	RecordID  int             `json:"record_id"`
+	Before    json.RawMessage `json:"before"`
+	After     json.RawMessage `json:"after"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// inTx runs fn in a transaction, which is committed if fn succeeds.
+func inTx(fn func(tx Querier) error) error {
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // No-op once committed
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// snapshotRow returns the row with id in table as JSON, locking it for the
+// rest of the transaction if forUpdate is set.
+func (a Actor) snapshotRow(db Querier, table string, id int, forUpdate bool) (json.RawMessage, error) {
+	query := `SELECT to_jsonb(t) FROM ` + table + ` t WHERE id = $1 AND org_id = $2`
+	if forUpdate {
+		query += ` FOR UPDATE`
+	}
+	var row []byte
+	err := db.QueryRow(query, id, a.OrgID).Scan(&row)
+	return row, err
+}
+
+// userID is the actor's user as stored, NULL for the system.
+func (a Actor) userID() sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(a.UserID), Valid: a.UserID != 0}
+}
+
+func (a Actor) record(db Querier, action, table string, id int, before, after json.RawMessage) error {
+	apiKeyID := sql.NullInt64{Int64: int64(a.APIKeyID), Valid: a.APIKeyID != 0}
+	_, err := db.Exec(`INSERT INTO audit_log (org_id, user_id, api_key_id, action, table_name, record_id, before, after)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`, a.OrgID, a.userID(), apiKeyID, action, table, id, nullJSON(before), nullJSON(after))
+	if err != nil {
+		return fmt.Errorf("audit log: %w", err)
+	}
+	return nil
+}
+
+// nullJSON passes missing snapshots to the database as NULL.
+func nullJSON(row json.RawMessage) interface{} {
+	if row == nil {
+		return nil
+	}
+	return []byte(row)
+}
+
+// auditCreate records the creation of the row with id in table. db must be
+// the transaction that inserted it.
+func (a Actor) auditCreate(db Querier, table string, id int) error {
+	after, err := a.snapshotRow(db, table, id, false)
+	if err != nil {
+		return err
+	}
+	return a.record(db, AuditCreate, table, id, nil, after)
+}
+
+// update runs exec, which updates the row with id in table, in a transaction
+// together with the audit log entry of the row before and after. It returns
+// sql.ErrNoRows if there was no such row to update, e.g. because it was
+// deleted in the meantime.
+func (a Actor) update(table string, id int, exec func(tx Querier) (sql.Result, error)) error {
+	updated, err := a.change(AuditUpdate, table, id, exec)
+	if err == nil && !updated {
+		return sql.ErrNoRows
+	}
+	return err
+}
+
+// delete marks the row with id in table as deleted by the actor, see
+// RestoreRecord and PurgeDeleted. It returns sql.ErrNoRows if there was no
+// such row to delete, e.g. because it was already deleted.
+func (a Actor) delete(table string, id int) error {
+	deleted, err := a.change(AuditDelete, table, id, func(tx Querier) (sql.Result, error) {
+		return tx.Exec(`UPDATE `+table+` SET deleted_at = NOW(), deleted_by = $3
+			WHERE id = $1 AND org_id = $2 AND deleted_at IS NULL`, id, a.OrgID, a.userID())
+	})
+	if err == nil && !deleted {
+		return sql.ErrNoRows
+	}
+	return err
+}
+
+// change runs exec, which changes the row with id in table, in a transaction
+// together with the audit log entry of the row before and after. It reports
+// whether exec changed the row; nothing is recorded if it didn't, e.g.
+// because the row belongs to another organisation or has been deleted.
+func (a Actor) change(action, table string, id int, exec func(tx Querier) (sql.Result, error)) (bool, error) {
+	changed := false
+	err := inTx(func(tx Querier) error {
+		before, err := a.snapshotRow(tx, table, id, true)
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		result, err := exec(tx)
+		if err != nil {
+			return err
+		}
+		if n, err := result.RowsAffected(); err != nil || n == 0 {
+			return err
+		}
+		after, err := a.snapshotRow(tx, table, id, false)
+		if err != nil {
+			return err
+		}
+		changed = true
+		return a.record(tx, action, table, id, before, after)
+	})
+	return changed && err == nil, err
+}
+
+// AuditFilter narrows the audit log of an organisation. Zero values match
+// any user, API key, table, record or action; From is inclusive and To
+// exclusive.
+type AuditFilter struct {
+	OrgID    int
+	UserID   int
+	APIKeyID int
+	Table    string
+	RecordID int
+	Action   string
+	From     time.Time
+	To       time.Time
+	Page     int // 1-based
+	PageSize int
+}
+
+func (f AuditFilter) whereClause() (string, []interface{}) {
+	args := []interface{}{f.OrgID}
+	conditions := []string{"org_id = $1"}
+
+	add := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+	if f.UserID != 0 {
+		add("user_id = $%d", f.UserID)
+	}
+	if f.APIKeyID != 0 {
+		add("api_key_id = $%d", f.APIKeyID)
+	}
+	if f.Table != "" {
+		add("table_name = $%d", f.Table)
+	}
+	if f.RecordID != 0 {
+		add("record_id = $%d", f.RecordID)
+	}
+	if f.Action != "" {
+		add("action = $%d", f.Action)
+	}
+	if !f.From.IsZero() {
+		add("created_at >= $%d", f.From)
+	}
+	if !f.To.IsZero() {
+		add("created_at < $%d", f.To)
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// ListAuditEntries returns a page of the entries matching f, newest first,
+// and the total number of matches.
+func ListAuditEntries(f AuditFilter) ([]AuditEntry, int, error) {
+	where, args := f.whereClause()
+
+	var total int
+	if err := config.DB.QueryRow(`SELECT COUNT(*) FROM audit_log`+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	page := f.Page
+	if page < 1 {
+		page = 1
+	}
+	args = append(args, f.PageSize, (page-1)*f.PageSize)
+	rows, err := config.DB.Query(fmt.Sprintf(`SELECT id, org_id, user_id, api_key_id, action, table_name, record_id, before, after, created_at
+		FROM audit_log%s ORDER BY created_at DESC, id DESC LIMIT $%d OFFSET $%d`, where, len(args)-1, len(args)), args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	entries := []AuditEntry{}
+	for rows.Next() {
+		e := AuditEntry{}
+		var before, after []byte
+		err := rows.Scan(&e.ID, &e.OrgID, &e.UserID, &e.APIKeyID, &e.Action, &e.Table, &e.RecordID, &before, &after, &e.CreatedAt)
+		if err != nil {
+			return nil, 0, err
+		}
+		e.Before, e.After = before, after
+		entries = append(entries, e)
+	}
+	return entries, total, rows.Err()
+}
@@ -0,0 +1,11 @@
+package models
+
+import "database/sql"
+
+// Querier is implemented by both *sql.DB and *sql.Tx, so that writes can be
+// made standalone or as part of a larger transaction.
+type Querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
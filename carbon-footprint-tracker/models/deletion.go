@@ -0,0 +1,54 @@
+package models
+
+import (
+	"carbon-footprint-tracker/config"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// ModuleTables lists every table holding module records, which are deleted
+// softly and can be restored until they are purged.
+var ModuleTables = append([]string{TablePopulation}, ActivityTables...)
+
+// Deletion is set on a module record when it is deleted. Deleted records are
+// left out of queries unless asked for, see ActivityFilter.IncludeDeleted.
+type Deletion struct {
+	DeletedAt sql.NullTime  `json:"deleted_at,omitempty"`
+	DeletedBy sql.NullInt64 `json:"deleted_by,omitempty"` // Kept when the user is deleted
+}
+
+// RestoreRecord undeletes the row with id in table. It reports whether there
+// was a deleted row to restore.
+func RestoreRecord(by Actor, table string, id int) (bool, error) {
+	if _, ok := ListSpecs[table]; !ok {
+		return false, fmt.Errorf("unknown table %s", table)
+	}
+	return by.change(AuditRestore, table, id, func(tx Querier) (sql.Result, error) {
+		return tx.Exec(`UPDATE `+table+` SET deleted_at = NULL, deleted_by = NULL
+			WHERE id = $1 AND org_id = $2 AND deleted_at IS NOT NULL`, id, by.OrgID)
+	})
+}
+
+// PurgeDeleted removes the module records of every organisation deleted
+// before cutoff for good, recording each in the audit log of its
+// organisation. It returns the number of records purged.
+func PurgeDeleted(cutoff time.Time) (int, error) {
+	purged := 0
+	for _, table := range ModuleTables {
+		result, err := config.DB.Exec(`WITH purged AS (
+				DELETE FROM `+table+` t WHERE deleted_at < $1 RETURNING id, org_id, to_jsonb(t) AS row
+			)
+			INSERT INTO audit_log (org_id, user_id, action, table_name, record_id, before)
+			SELECT org_id, NULL, $2, $3, id, row FROM purged`, cutoff, AuditPurge, table)
+		if err != nil {
+			return purged, fmt.Errorf("%s: %w", table, err)
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return purged, fmt.Errorf("%s: %w", table, err)
+		}
+		purged += int(n)
+	}
+	return purged, nil
+}
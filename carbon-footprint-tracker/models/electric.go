@@ -8,6 +8,7 @@ import (
 
 type ElectricConsumption struct {
 	ID       int       `json:"id"`
+	OrgID    int       `json:"-"` // Owning organisation, implied by the request
 	Date     time.Time `json:"date"`
 	Location string    `json:"location"`
 	Source   string    `json:"source"` // 'Main Board', 'Diesel Generator', 'Biofuel Generator', 'Solar Generation'
@@ -25,64 +26,159 @@ type ElectricConsumption struct {
 	ElectricityBillCostINR    sql.NullFloat64 `json:"electricity_bill_cost_inr,omitempty"`
 	ElectricalAppliancesCount sql.NullInt32   `json:"electrical_appliances_count,omitempty"`
 
-	// Fields specific to Solar
-	SolarGeneratedKWH sql.NullFloat64 `json:"solar_generated_kwh,omitempty"`
+	// Fields specific to Solar. Generation is split into what the site used
+	// itself and what was exported to the grid under net metering.
+	SolarGeneratedKWH    sql.NullFloat64 `json:"solar_generated_kwh,omitempty"`
+	SolarSelfConsumedKWH sql.NullFloat64 `json:"solar_self_consumed_kwh,omitempty"`
+	SolarExportedKWH     sql.NullFloat64 `json:"solar_exported_kwh,omitempty"`
 
 	Remarks sql.NullString `json:"remarks,omitempty"`
+
+	Footprint
+	AvoidedCO2eKg sql.NullFloat64 `json:"avoided_co2e_kg,omitempty"` // Grid emissions displaced by the solar generation
+	Deletion
+}
+
+// computeFootprint refreshes the stored footprint and avoided emissions of e
+// using footprinter.
+func (e *ElectricConsumption) computeFootprint(footprinter Footprinter) error {
+	if err := e.Footprint.computeWith(footprinter, e); err != nil {
+		return err
+	}
+	return e.computeAvoided(footprinter)
 }
 
-func (e *ElectricConsumption) Create() error {
+func (e *ElectricConsumption) computeAvoided(footprinter Footprinter) error {
+	offsetter, ok := footprinter.(Offsetter)
+	if !ok {
+		return nil
+	}
+	avoided, err := offsetter.Avoided(e)
+	if err != nil {
+		return err
+	}
+	e.AvoidedCO2eKg = sql.NullFloat64{Float64: avoided, Valid: true}
+	return nil
+}
+
+func (e *ElectricConsumption) updateAvoided() error {
+	_, err := config.DB.Exec(`UPDATE electric_consumption SET avoided_co2e_kg=$1 WHERE id=$2`, e.AvoidedCO2eKg, e.ID)
+	return err
+}
+
+func (e *ElectricConsumption) Create(by Actor) error {
+	footprinter, err := NewFootprinter()
+	if err != nil {
+		return err
+	}
+	return inTx(func(tx Querier) error { return e.CreateWith(tx, by, footprinter) })
+}
+
+// CreateWith inserts the record for by using db, which must be a transaction
+// so that the audit log entry is written with it. Its footprint is computed
+// with footprinter, see NewFootprinter.
+func (e *ElectricConsumption) CreateWith(db Querier, by Actor, footprinter Footprinter) error {
+	e.OrgID = by.OrgID
+	if err := e.computeFootprint(footprinter); err != nil {
+		return err
+	}
+
 	query := `INSERT INTO electric_consumption (
 		date, location, source, dg_capacity_kva, running_time_hours, fuel_consumed_liters, 
 		fuel_type, energy_generated_dg_kwh, grid_electricity_used_kwh, electricity_bill_kwh, 
-		electricity_bill_cost_inr, electrical_appliances_count, solar_generated_kwh, remarks
-	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14) RETURNING id`
+		electricity_bill_cost_inr, electrical_appliances_count, solar_generated_kwh, solar_self_consumed_kwh, solar_exported_kwh,
+		remarks, co2e_kg, emission_factor_id, co2e_scope1_kg, co2e_scope2_kg, co2e_scope3_kg, avoided_co2e_kg, org_id
+	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23) RETURNING id`
 
-	return config.DB.QueryRow(query,
+	err := db.QueryRow(query,
 		e.Date, e.Location, e.Source, e.DgCapacityKVA, e.RunningTimeHours, e.FuelConsumedLiters,
 		e.FuelType, e.EnergyGeneratedDgKWH, e.GridElectricityUsedKWH, e.ElectricityBillKWH,
-		e.ElectricityBillCostINR, e.ElectricalAppliancesCount, e.SolarGeneratedKWH, e.Remarks,
+		e.ElectricityBillCostINR, e.ElectricalAppliancesCount, e.SolarGeneratedKWH, e.SolarSelfConsumedKWH, e.SolarExportedKWH,
+		e.Remarks, e.CO2eKg, e.EmissionFactorID, e.Scope1CO2eKg, e.Scope2CO2eKg, e.Scope3CO2eKg, e.AvoidedCO2eKg, e.OrgID,
 	).Scan(&e.ID)
+	if err != nil {
+		return err
+	}
+	return by.auditCreate(db, TableElectricConsumption, e.ID)
+}
+
+func GetAllElectricConsumptions(f ActivityFilter) ([]ElectricConsumption, error) {
+	consumptions, _, err := ListElectricConsumptions(ListOptions{ActivityFilter: f})
+	return consumptions, err
+}
+
+// ListElectricConsumptions returns the electric consumption entries matching o and the total number of matches.
+func ListElectricConsumptions(o ListOptions) ([]ElectricConsumption, int, error) {
+	consumptions := []ElectricConsumption{}
+	err := EachElectricConsumption(o, func(x ElectricConsumption) error {
+		consumptions = append(consumptions, x)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total, err := o.total(ListSpecs[TableElectricConsumption], len(consumptions))
+	if err != nil {
+		return nil, 0, err
+	}
+	return consumptions, total, nil
 }
 
-func GetAllElectricConsumptions() ([]ElectricConsumption, error) {
+// EachElectricConsumption calls fn for each of the electric consumption entries matching o,
+// streaming rows from the database rather than loading them all at once.
+func EachElectricConsumption(o ListOptions, fn func(ElectricConsumption) error) error {
+	spec := ListSpecs[TableElectricConsumption]
+	where, args, err := o.whereClause(spec)
+	if err != nil {
+		return err
+	}
+	order, queryArgs, err := o.orderClause(spec, args)
+	if err != nil {
+		return err
+	}
 	rows, err := config.DB.Query(`SELECT
-		id, date, location, source, dg_capacity_kva, running_time_hours, fuel_consumed_liters,
+		id, org_id, date, location, source, dg_capacity_kva, running_time_hours, fuel_consumed_liters,
 		fuel_type, energy_generated_dg_kwh, grid_electricity_used_kwh, electricity_bill_kwh,
-		electricity_bill_cost_inr, electrical_appliances_count, solar_generated_kwh, remarks
-		FROM electric_consumption ORDER BY date DESC`)
+		electricity_bill_cost_inr, electrical_appliances_count, solar_generated_kwh, solar_self_consumed_kwh, solar_exported_kwh,
+		remarks, co2e_kg, emission_factor_id, avoided_co2e_kg, deleted_at, deleted_by
+		FROM electric_consumption`+where+order, queryArgs...)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer rows.Close()
 
-	var consumptions []ElectricConsumption
 	for rows.Next() {
 		e := ElectricConsumption{}
 		err := rows.Scan(
-			&e.ID, &e.Date, &e.Location, &e.Source, &e.DgCapacityKVA, &e.RunningTimeHours,
+			&e.ID, &e.OrgID, &e.Date, &e.Location, &e.Source, &e.DgCapacityKVA, &e.RunningTimeHours,
 			&e.FuelConsumedLiters, &e.FuelType, &e.EnergyGeneratedDgKWH, &e.GridElectricityUsedKWH,
-			&e.ElectricityBillKWH, &e.ElectricityBillCostINR, &e.ElectricalAppliancesCount, &e.SolarGeneratedKWH, &e.Remarks,
+			&e.ElectricityBillKWH, &e.ElectricityBillCostINR, &e.ElectricalAppliancesCount, &e.SolarGeneratedKWH, &e.SolarSelfConsumedKWH, &e.SolarExportedKWH,
+			&e.Remarks, &e.CO2eKg, &e.EmissionFactorID, &e.AvoidedCO2eKg, &e.DeletedAt, &e.DeletedBy,
 		)
 		if err != nil {
-			return nil, err
+			return err
+		}
+		if err := fn(e); err != nil {
+			return err
 		}
-		consumptions = append(consumptions, e)
 	}
-	return consumptions, nil
+	return rows.Err()
 }
 
-func GetElectricConsumptionByID(id int) (*ElectricConsumption, error) {
+func GetElectricConsumptionByID(orgID, id int) (*ElectricConsumption, error) {
 	e := &ElectricConsumption{}
 	query := `SELECT
-		id, date, location, source, dg_capacity_kva, running_time_hours, fuel_consumed_liters,
+		id, org_id, date, location, source, dg_capacity_kva, running_time_hours, fuel_consumed_liters,
 		fuel_type, energy_generated_dg_kwh, grid_electricity_used_kwh, electricity_bill_kwh,
-		electricity_bill_cost_inr, electrical_appliances_count, solar_generated_kwh, remarks
-		FROM electric_consumption WHERE id = $1`
-	err := config.DB.QueryRow(query, id).Scan(
-		&e.ID, &e.Date, &e.Location, &e.Source, &e.DgCapacityKVA, &e.RunningTimeHours,
+		electricity_bill_cost_inr, electrical_appliances_count, solar_generated_kwh, solar_self_consumed_kwh, solar_exported_kwh,
+		remarks, co2e_kg, emission_factor_id, avoided_co2e_kg, deleted_at, deleted_by
+		FROM electric_consumption WHERE id = $1 AND org_id = $2 AND deleted_at IS NULL`
+	err := config.DB.QueryRow(query, id, orgID).Scan(
+		&e.ID, &e.OrgID, &e.Date, &e.Location, &e.Source, &e.DgCapacityKVA, &e.RunningTimeHours,
 		&e.FuelConsumedLiters, &e.FuelType, &e.EnergyGeneratedDgKWH, &e.GridElectricityUsedKWH,
-		&e.ElectricityBillKWH, &e.ElectricityBillCostINR, &e.ElectricalAppliancesCount, &e.SolarGeneratedKWH, &e.Remarks,
+		&e.ElectricityBillKWH, &e.ElectricityBillCostINR, &e.ElectricalAppliancesCount, &e.SolarGeneratedKWH, &e.SolarSelfConsumedKWH, &e.SolarExportedKWH,
+		&e.Remarks, &e.CO2eKg, &e.EmissionFactorID, &e.AvoidedCO2eKg, &e.DeletedAt, &e.DeletedBy,
 	)
 	if err != nil {
 		return nil, err
@@ -90,22 +186,65 @@ func GetElectricConsumptionByID(id int) (*ElectricConsumption, error) {
 	return e, nil
 }
 
-func (e *ElectricConsumption) Update() error {
+func (e *ElectricConsumption) Update(by Actor) error {
+	footprinter, err := NewFootprinter()
+	if err != nil {
+		return err
+	}
+	if err := e.computeFootprint(footprinter); err != nil {
+		return err
+	}
+
 	query := `UPDATE electric_consumption SET
 		date=$1, location=$2, source=$3, dg_capacity_kva=$4, running_time_hours=$5, fuel_consumed_liters=$6,
 		fuel_type=$7, energy_generated_dg_kwh=$8, grid_electricity_used_kwh=$9, electricity_bill_kwh=$10,
-		electricity_bill_cost_inr=$11, electrical_appliances_count=$12, solar_generated_kwh=$13, remarks=$14
-		WHERE id=$15`
-	_, err := config.DB.Exec(query,
-		e.Date, e.Location, e.Source, e.DgCapacityKVA, e.RunningTimeHours, e.FuelConsumedLiters,
-		e.FuelType, e.EnergyGeneratedDgKWH, e.GridElectricityUsedKWH, e.ElectricityBillKWH,
-		e.ElectricityBillCostINR, e.ElectricalAppliancesCount, e.SolarGeneratedKWH, e.Remarks, e.ID,
-	)
-	return err
+		electricity_bill_cost_inr=$11, electrical_appliances_count=$12, solar_generated_kwh=$13, solar_self_consumed_kwh=$14, solar_exported_kwh=$15,
+		remarks=$16, co2e_kg=$17, emission_factor_id=$18, co2e_scope1_kg=$19, co2e_scope2_kg=$20, co2e_scope3_kg=$21, avoided_co2e_kg=$22
+		WHERE id=$23 AND org_id=$24 AND deleted_at IS NULL`
+	return by.update(TableElectricConsumption, e.ID, func(tx Querier) (sql.Result, error) {
+		return tx.Exec(query,
+			e.Date, e.Location, e.Source, e.DgCapacityKVA, e.RunningTimeHours, e.FuelConsumedLiters,
+			e.FuelType, e.EnergyGeneratedDgKWH, e.GridElectricityUsedKWH, e.ElectricityBillKWH,
+			e.ElectricityBillCostINR, e.ElectricalAppliancesCount, e.SolarGeneratedKWH, e.SolarSelfConsumedKWH, e.SolarExportedKWH,
+			e.Remarks, e.CO2eKg, e.EmissionFactorID, e.Scope1CO2eKg, e.Scope2CO2eKg, e.Scope3CO2eKg, e.AvoidedCO2eKg, e.ID, by.OrgID,
+		)
+	})
 }
 
-func DeleteElectricConsumption(id int) error {
-	query := `DELETE FROM electric_consumption WHERE id=$1`
-	_, err := config.DB.Exec(query, id)
-	return err
+func DeleteElectricConsumption(by Actor, id int) error {
+	return by.delete(TableElectricConsumption, id)
+}
+
+// ElectricityTotals sums electricity supply and its emissions over a period.
+type ElectricityTotals struct {
+	GridKWH              float64 // Imported from the grid
+	GeneratorKWH         float64 // From diesel and biofuel generators
+	SolarGeneratedKWH    float64
+	SolarSelfConsumedKWH float64
+	SolarExportedKWH     float64
+	GridCO2eKg           float64 // Stored footprint of the Main Board records
+	GeneratorCO2eKg      float64 // Stored footprint of every other record
+	AvoidedCO2eKg        float64
+}
+
+// SumElectricity returns the electricity totals for the filter. Solar
+// generation recorded without a split counts as used on site, less whatever
+// is recorded as exported.
+func SumElectricity(f ActivityFilter) (ElectricityTotals, error) {
+	where, args := f.whereClause(activityLocationColumns[TableElectricConsumption])
+	t := ElectricityTotals{}
+	err := config.DB.QueryRow(`SELECT
+		COALESCE(SUM(grid_electricity_used_kwh), 0),
+		COALESCE(SUM(energy_generated_dg_kwh), 0),
+		COALESCE(SUM(solar_generated_kwh), 0),
+		COALESCE(SUM(COALESCE(solar_self_consumed_kwh, GREATEST(solar_generated_kwh - COALESCE(solar_exported_kwh, 0), 0))), 0),
+		COALESCE(SUM(solar_exported_kwh), 0),
+		COALESCE(SUM(co2e_kg) FILTER (WHERE source = 'Main Board'), 0),
+		COALESCE(SUM(co2e_kg) FILTER (WHERE source <> 'Main Board'), 0),
+		COALESCE(SUM(avoided_co2e_kg), 0)
+		FROM electric_consumption`+where, args...).Scan(
+		&t.GridKWH, &t.GeneratorKWH, &t.SolarGeneratedKWH, &t.SolarSelfConsumedKWH, &t.SolarExportedKWH,
+		&t.GridCO2eKg, &t.GeneratorCO2eKg, &t.AvoidedCO2eKg,
+	)
+	return t, err
 }
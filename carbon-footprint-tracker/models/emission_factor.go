@@ -0,0 +1,166 @@
+package models
+
+import (
+	"carbon-footprint-tracker/config"
+	"database/sql"
+	"time"
+)
+
+// Categories and sources identifying the factors used by the footprint calculation.
+const (
+	FactorCategoryElectricity = "Electricity"
+	FactorCategoryFuel        = "Fuel"
+	FactorCategoryWater       = "Water"
+	FactorCategoryWaste       = "Waste"
+	FactorCategoryGoods       = "Goods"
+	FactorCategoryChemicals   = "Chemicals"
+
+	FactorSourceGrid               = "Grid"
+	FactorSourceDiesel             = "Diesel"
+	FactorSourcePetrol             = "Petrol"
+	FactorSourceBiofuel            = "Biofuel"
+	FactorSourceLPG                = "LPG"
+	FactorSourceFirewood           = "Firewood"
+	FactorSourceWaterTreatmentDist = "Treatment and Distribution"
+	FactorSourceFoodWater          = "Food Preparation"
+	FactorSourceWasteBiodegradable = "Biodegradable"
+	FactorSourceWasteRecyclable    = "Recyclable"
+	FactorSourceWasteLandfill      = "Landfill"
+	FactorSourceWasteEwaste        = "E-waste"
+	FactorSourceGoodsSpend         = "Spend"
+	FactorSourceTreatmentChemicals = "Treatment Chemicals"
+)
+
+type EmissionFactor struct {
+	ID        int            `json:"id"`
+	Source    string         `json:"source"`   // e.g. 'Grid', 'Diesel', 'Landfill'
+	Category  string         `json:"category"` // 'Electricity', 'Fuel', 'Water', 'Waste', 'Goods', 'Chemicals'
+	Unit      string         `json:"unit"`     // e.g. 'kgCO2e/kWh', 'kgCO2e/L'
+	Value     float64        `json:"value"`
+	ValidFrom time.Time      `json:"valid_from"`
+	ValidTo   sql.NullTime   `json:"valid_to,omitempty"` // Open-ended when null; inclusive otherwise
+	Reference sql.NullString `json:"reference,omitempty"`
+}
+
+// EmissionFactors is a loaded factor library that can be queried by record date.
+type EmissionFactors []EmissionFactor
+
+// AppliesOn reports whether the factor was valid on the calendar day of t.
+func (ef *EmissionFactor) AppliesOn(t time.Time) bool {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	if day.Before(ef.ValidFrom) {
+		return false
+	}
+	return !ef.ValidTo.Valid || !day.After(ef.ValidTo.Time)
+}
+
+// Lookup returns the factor for category/source that was valid at t. When
+// several periods match, the one that started most recently wins.
+func (fs EmissionFactors) Lookup(category, source string, t time.Time) (*EmissionFactor, bool) {
+	var found *EmissionFactor
+	for i := range fs {
+		f := &fs[i]
+		if f.Category != category || f.Source != source || !f.AppliesOn(t) {
+			continue
+		}
+		if found == nil || f.ValidFrom.After(found.ValidFrom) {
+			found = f
+		}
+	}
+	return found, found != nil
+}
+
+// ValueAt returns the value of the factor valid at t, or fallback when the
+// library has no factor for that date.
+func (fs EmissionFactors) ValueAt(category, source string, t time.Time, fallback float64) float64 {
+	if f, ok := fs.Lookup(category, source, t); ok {
+		return f.Value
+	}
+	return fallback
+}
+
+func (ef *EmissionFactor) Create() error {
+	query := `INSERT INTO emission_factors (
+		source, category, unit, value, valid_from, valid_to, reference
+	) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`
+	return config.DB.QueryRow(query,
+		ef.Source, ef.Category, ef.Unit, ef.Value, ef.ValidFrom, ef.ValidTo, ef.Reference,
+	).Scan(&ef.ID)
+}
+
+func GetAllEmissionFactors() (EmissionFactors, error) {
+	rows, err := config.DB.Query(`SELECT
+		id, source, category, unit, value, valid_from, valid_to, reference
+		FROM emission_factors ORDER BY category, source, valid_from DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var factors EmissionFactors
+	for rows.Next() {
+		ef := EmissionFactor{}
+		err := rows.Scan(
+			&ef.ID, &ef.Source, &ef.Category, &ef.Unit, &ef.Value, &ef.ValidFrom, &ef.ValidTo, &ef.Reference,
+		)
+		if err != nil {
+			return nil, err
+		}
+		factors = append(factors, ef)
+	}
+	return factors, nil
+}
+
+func GetEmissionFactorByID(id int) (*EmissionFactor, error) {
+	ef := &EmissionFactor{}
+	query := `SELECT
+		id, source, category, unit, value, valid_from, valid_to, reference
+		FROM emission_factors WHERE id = $1`
+	err := config.DB.QueryRow(query, id).Scan(
+		&ef.ID, &ef.Source, &ef.Category, &ef.Unit, &ef.Value, &ef.ValidFrom, &ef.ValidTo, &ef.Reference,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return ef, nil
+}
+
+// HasOverlap reports whether another factor for the same category and source
+// is valid during any part of this factor's validity period.
+func (ef *EmissionFactor) HasOverlap() (bool, error) {
+	query := `SELECT EXISTS (
+		SELECT 1 FROM emission_factors
+		WHERE category = $1 AND source = $2 AND id <> $3
+		AND (valid_to IS NULL OR valid_to >= $4)
+		AND ($5::date IS NULL OR valid_from <= $5::date)
+	)`
+	var exists bool
+	err := config.DB.QueryRow(query, ef.Category, ef.Source, ef.ID, ef.ValidFrom, ef.ValidTo).Scan(&exists)
+	return exists, err
+}
+
+func (ef *EmissionFactor) Update() error {
+	query := `UPDATE emission_factors SET
+		source=$1, category=$2, unit=$3, value=$4, valid_from=$5, valid_to=$6, reference=$7
+		WHERE id=$8`
+	_, err := config.DB.Exec(query,
+		ef.Source, ef.Category, ef.Unit, ef.Value, ef.ValidFrom, ef.ValidTo, ef.Reference, ef.ID,
+	)
+	return err
+}
+
+// DeleteEmissionFactor deletes the factor with id, or returns sql.ErrNoRows if
+// there is none.
+func DeleteEmissionFactor(id int) error {
+	query := `DELETE FROM emission_factors WHERE id=$1`
+	result, err := config.DB.Exec(query, id)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ActivityFilter narrows activity queries to an organisation, a date range
+// and a location. From is inclusive and To is exclusive; zero values leave
+// that side open.
+//
+// Queries are limited to OrgID unless AllOrganisations is set, so a filter
+// that was never given an organisation matches nothing rather than the
+// records of every organisation. Likewise deleted records are left out unless
+// IncludeDeleted is set.
+type ActivityFilter struct {
+	OrgID            int
+	AllOrganisations bool // Only for maintenance across the deployment, e.g. RecomputeFootprints
+	IncludeDeleted   bool
+	From             time.Time
+	To               time.Time
+	Location         string
+	Locations        []string // When not nil, only these locations (the user's scope); empty matches nothing
+}
+
+// whereClause builds the WHERE clause for the filter. locationColumn is the
+// column holding the location for the table being queried, since not every
+// module calls it "location".
+func (f ActivityFilter) whereClause(locationColumn string) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	if !f.AllOrganisations {
+		args = append(args, f.OrgID)
+		conditions = append(conditions, fmt.Sprintf("org_id = $%d", len(args)))
+	}
+	if !f.IncludeDeleted {
+		conditions = append(conditions, "deleted_at IS NULL")
+	}
+	if !f.From.IsZero() {
+		args = append(args, f.From)
+		conditions = append(conditions, fmt.Sprintf("date >= $%d", len(args)))
+	}
+	if !f.To.IsZero() {
+		args = append(args, f.To)
+		conditions = append(conditions, fmt.Sprintf("date < $%d", len(args)))
+	}
+	if f.Location != "" {
+		args = append(args, f.Location)
+		conditions = append(conditions, fmt.Sprintf("%s = $%d", locationColumn, len(args)))
+	}
+	if f.Locations != nil {
+		args = append(args, pq.Array(f.Locations))
+		conditions = append(conditions, fmt.Sprintf("%s = ANY($%d)", locationColumn, len(args)))
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
@@ -8,6 +8,7 @@ import (
 
 type FoodConsumption struct {
 	ID                       int             `json:"id"`
+	OrgID                    int             `json:"-"` // Owning organisation, implied by the request
 	Date                     time.Time       `json:"date"`
 	Location                 string          `json:"location"` // 'Canteen', 'Hostel', 'Event'
 	FoodItem                 string          `json:"food_item"`
@@ -18,60 +19,118 @@ type FoodConsumption struct {
 	FuelUsedType             sql.NullString  `json:"fuel_used_type,omitempty"` // 'LPG', 'Firewood', 'Electricity'
 	FuelUsedQuantity         sql.NullFloat64 `json:"fuel_used_quantity,omitempty"`
 	Remarks                  sql.NullString  `json:"remarks,omitempty"`
+
+	Footprint
+	Deletion
+}
+
+func (f *FoodConsumption) Create(by Actor) error {
+	footprinter, err := NewFootprinter()
+	if err != nil {
+		return err
+	}
+	return inTx(func(tx Querier) error { return f.CreateWith(tx, by, footprinter) })
 }
 
-func (f *FoodConsumption) Create() error {
+// CreateWith inserts the record for by using db, which must be a transaction
+// so that the audit log entry is written with it. Its footprint is computed
+// with footprinter, see NewFootprinter.
+func (f *FoodConsumption) CreateWith(db Querier, by Actor, footprinter Footprinter) error {
+	f.OrgID = by.OrgID
+	if err := f.Footprint.computeWith(footprinter, f); err != nil {
+		return err
+	}
+
 	query := `INSERT INTO food_consumption (
 		date, location, food_item, quantity_cooked_kg_liter, no_of_meals_served,
 		raw_material_source, water_used_l_washing_cooking, fuel_used_type,
-		fuel_used_quantity, remarks
-	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id`
+		fuel_used_quantity, remarks, co2e_kg, emission_factor_id, co2e_scope1_kg, co2e_scope2_kg, co2e_scope3_kg, org_id
+	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16) RETURNING id`
 
-	return config.DB.QueryRow(query,
+	err := db.QueryRow(query,
 		f.Date, f.Location, f.FoodItem, f.QuantityCookedKgLiter, f.NoOfMealsServed,
 		f.RawMaterialSource, f.WaterUsedLWashingCooking, f.FuelUsedType,
-		f.FuelUsedQuantity, f.Remarks,
+		f.FuelUsedQuantity, f.Remarks, f.CO2eKg, f.EmissionFactorID, f.Scope1CO2eKg, f.Scope2CO2eKg, f.Scope3CO2eKg, f.OrgID,
 	).Scan(&f.ID)
+	if err != nil {
+		return err
+	}
+	return by.auditCreate(db, TableFoodConsumption, f.ID)
+}
+
+func GetAllFoodConsumptions(f ActivityFilter) ([]FoodConsumption, error) {
+	consumptions, _, err := ListFoodConsumptions(ListOptions{ActivityFilter: f})
+	return consumptions, err
+}
+
+// ListFoodConsumptions returns the food consumption entries matching o and the total number of matches.
+func ListFoodConsumptions(o ListOptions) ([]FoodConsumption, int, error) {
+	consumptions := []FoodConsumption{}
+	err := EachFoodConsumption(o, func(x FoodConsumption) error {
+		consumptions = append(consumptions, x)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total, err := o.total(ListSpecs[TableFoodConsumption], len(consumptions))
+	if err != nil {
+		return nil, 0, err
+	}
+	return consumptions, total, nil
 }
 
-func GetAllFoodConsumptions() ([]FoodConsumption, error) {
+// EachFoodConsumption calls fn for each of the food consumption entries matching o,
+// streaming rows from the database rather than loading them all at once.
+func EachFoodConsumption(o ListOptions, fn func(FoodConsumption) error) error {
+	spec := ListSpecs[TableFoodConsumption]
+	where, args, err := o.whereClause(spec)
+	if err != nil {
+		return err
+	}
+	order, queryArgs, err := o.orderClause(spec, args)
+	if err != nil {
+		return err
+	}
 	rows, err := config.DB.Query(`SELECT
-		id, date, location, food_item, quantity_cooked_kg_liter, no_of_meals_served,
+		id, org_id, date, location, food_item, quantity_cooked_kg_liter, no_of_meals_served,
 		raw_material_source, water_used_l_washing_cooking, fuel_used_type,
-		fuel_used_quantity, remarks
-		FROM food_consumption ORDER BY date DESC`)
+		fuel_used_quantity, remarks, co2e_kg, emission_factor_id, deleted_at, deleted_by
+		FROM food_consumption`+where+order, queryArgs...)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer rows.Close()
 
-	var consumptions []FoodConsumption
 	for rows.Next() {
 		f := FoodConsumption{}
 		err := rows.Scan(
-			&f.ID, &f.Date, &f.Location, &f.FoodItem, &f.QuantityCookedKgLiter, &f.NoOfMealsServed,
+			&f.ID, &f.OrgID, &f.Date, &f.Location, &f.FoodItem, &f.QuantityCookedKgLiter, &f.NoOfMealsServed,
 			&f.RawMaterialSource, &f.WaterUsedLWashingCooking, &f.FuelUsedType,
-			&f.FuelUsedQuantity, &f.Remarks,
+			&f.FuelUsedQuantity, &f.Remarks, &f.CO2eKg, &f.EmissionFactorID, &f.DeletedAt, &f.DeletedBy,
 		)
 		if err != nil {
-			return nil, err
+			return err
+		}
+		if err := fn(f); err != nil {
+			return err
 		}
-		consumptions = append(consumptions, f)
 	}
-	return consumptions, nil
+	return rows.Err()
 }
 
-func GetFoodConsumptionByID(id int) (*FoodConsumption, error) {
+func GetFoodConsumptionByID(orgID, id int) (*FoodConsumption, error) {
 	f := &FoodConsumption{}
 	query := `SELECT
-		id, date, location, food_item, quantity_cooked_kg_liter, no_of_meals_served,
+		id, org_id, date, location, food_item, quantity_cooked_kg_liter, no_of_meals_served,
 		raw_material_source, water_used_l_washing_cooking, fuel_used_type,
-		fuel_used_quantity, remarks
-		FROM food_consumption WHERE id = $1`
-	err := config.DB.QueryRow(query, id).Scan(
-		&f.ID, &f.Date, &f.Location, &f.FoodItem, &f.QuantityCookedKgLiter, f.NoOfMealsServed,
+		fuel_used_quantity, remarks, co2e_kg, emission_factor_id, deleted_at, deleted_by
+		FROM food_consumption WHERE id = $1 AND org_id = $2 AND deleted_at IS NULL`
+	err := config.DB.QueryRow(query, id, orgID).Scan(
+		&f.ID, &f.OrgID, &f.Date, &f.Location, &f.FoodItem, &f.QuantityCookedKgLiter, &f.NoOfMealsServed,
 		&f.RawMaterialSource, &f.WaterUsedLWashingCooking, &f.FuelUsedType,
-		&f.FuelUsedQuantity, &f.Remarks,
+		&f.FuelUsedQuantity, &f.Remarks, &f.CO2eKg, &f.EmissionFactorID, &f.DeletedAt, &f.DeletedBy,
 	)
 	if err != nil {
 		return nil, err
@@ -79,22 +138,25 @@ func GetFoodConsumptionByID(id int) (*FoodConsumption, error) {
 	return f, nil
 }
 
-func (f *FoodConsumption) Update() error {
+func (f *FoodConsumption) Update(by Actor) error {
+	if err := f.Footprint.compute(f); err != nil {
+		return err
+	}
+
 	query := `UPDATE food_consumption SET
 		date=$1, location=$2, food_item=$3, quantity_cooked_kg_liter=$4, no_of_meals_served=$5,
 		raw_material_source=$6, water_used_l_washing_cooking=$7, fuel_used_type=$8,
-		fuel_used_quantity=$9, remarks=$10
-		WHERE id=$11`
-	_, err := config.DB.Exec(query,
-		f.Date, f.Location, f.FoodItem, f.QuantityCookedKgLiter, f.NoOfMealsServed,
-		f.RawMaterialSource, f.WaterUsedLWashingCooking, f.FuelUsedType,
-		f.FuelUsedQuantity, f.Remarks, f.ID,
-	)
-	return err
+		fuel_used_quantity=$9, remarks=$10, co2e_kg=$11, emission_factor_id=$12, co2e_scope1_kg=$13, co2e_scope2_kg=$14, co2e_scope3_kg=$15
+		WHERE id=$16 AND org_id=$17 AND deleted_at IS NULL`
+	return by.update(TableFoodConsumption, f.ID, func(tx Querier) (sql.Result, error) {
+		return tx.Exec(query,
+			f.Date, f.Location, f.FoodItem, f.QuantityCookedKgLiter, f.NoOfMealsServed,
+			f.RawMaterialSource, f.WaterUsedLWashingCooking, f.FuelUsedType,
+			f.FuelUsedQuantity, f.Remarks, f.CO2eKg, f.EmissionFactorID, f.Scope1CO2eKg, f.Scope2CO2eKg, f.Scope3CO2eKg, f.ID, by.OrgID,
+		)
+	})
 }
 
-func DeleteFoodConsumption(id int) error {
-	query := `DELETE FROM food_consumption WHERE id=$1`
-	_, err := config.DB.Exec(query, id)
-	return err
+func DeleteFoodConsumption(by Actor, id int) error {
+	return by.delete(TableFoodConsumption, id)
 }
@@ -0,0 +1,335 @@
+package models
+
+import (
+	"carbon-footprint-tracker/config"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Tables holding emitting activity records.
+const (
+	TableElectricConsumption = "electric_consumption"
+	TableTransport           = "transport"
+	TableWaterConsumption    = "water_consumption"
+	TableWaterTreatment      = "water_treatment"
+	TableWaste               = "waste"
+	TableAccommodation       = "accommodation"
+	TableGoodsPurchased      = "goods_purchased"
+	TableFoodConsumption     = "food_consumption"
+)
+
+// ActivityTables lists every table carrying a stored footprint.
+var ActivityTables = []string{
+	TableElectricConsumption,
+	TableTransport,
+	TableWaterConsumption,
+	TableWaterTreatment,
+	TableWaste,
+	TableAccommodation,
+	TableGoodsPurchased,
+	TableFoodConsumption,
+}
+
+// activityLocationColumns maps each activity table to its location column.
+var activityLocationColumns = map[string]string{
+	TableElectricConsumption: "location",
+	TableTransport:           "event_area_location",
+	TableWaterConsumption:    "location",
+	TableWaterTreatment:      "location",
+	TableWaste:               "collection_location",
+	TableAccommodation:       "accommodation_facility_name",
+	TableGoodsPurchased:      "location",
+	TableFoodConsumption:     "location",
+}
+
+// Footprint is the emissions figure stored on an activity record when it is
+// written, together with the factor that contributed most to it and its split
+// by GHG Protocol scope.
+type Footprint struct {
+	CO2eKg           sql.NullFloat64 `json:"co2e_kg,omitempty"`
+	EmissionFactorID sql.NullInt64   `json:"emission_factor_id,omitempty"`
+
+	// The share of CO2eKg in each scope. They are only read in aggregate, see
+	// SumScopeFootprints.
+	Scope1CO2eKg sql.NullFloat64 `json:"-"`
+	Scope2CO2eKg sql.NullFloat64 `json:"-"`
+	Scope3CO2eKg sql.NullFloat64 `json:"-"`
+}
+
+// Footprinter computes the footprint of an activity record. record is a
+// pointer to one of the activity models.
+type Footprinter interface {
+	Footprint(record interface{}) (Footprint, error)
+}
+
+// Offsetter is implemented by Footprinters that also compute the emissions a
+// record avoids, e.g. solar generation displacing grid electricity.
+type Offsetter interface {
+	Avoided(record interface{}) (co2eKg float64, err error)
+}
+
+// newFootprinter builds a Footprinter for the current factor library. It is
+// registered at startup so that models does not depend on the calculation.
+var newFootprinter func() (Footprinter, error)
+
+// SetFootprinterSource registers the function used to build Footprinters.
+func SetFootprinterSource(fn func() (Footprinter, error)) {
+	newFootprinter = fn
+}
+
+// NewFootprinter returns a Footprinter for the factor library as it is now,
+// or nil if none is registered, in which case no footprint is stored. Writing
+// many records, e.g. in an import, should build one and pass it to each
+// CreateWith, so that the library is loaded once.
+func NewFootprinter() (Footprinter, error) {
+	if newFootprinter == nil {
+		return nil, nil
+	}
+	return newFootprinter()
+}
+
+// compute refreshes fp for record using a Footprinter for the current library.
+func (fp *Footprint) compute(record interface{}) error {
+	footprinter, err := NewFootprinter()
+	if err != nil {
+		return err
+	}
+	return fp.computeWith(footprinter, record)
+}
+
+func (fp *Footprint) computeWith(footprinter Footprinter, record interface{}) error {
+	if footprinter == nil {
+		return nil
+	}
+	computed, err := footprinter.Footprint(record)
+	if err != nil {
+		return err
+	}
+	*fp = computed
+	return nil
+}
+
+func updateFootprint(table string, id int, fp Footprint) error {
+	query := fmt.Sprintf(`UPDATE %s SET co2e_kg=$1, emission_factor_id=$2, co2e_scope1_kg=$3, co2e_scope2_kg=$4, co2e_scope3_kg=$5
+		WHERE id=$6`, table)
+	_, err := config.DB.Exec(query, fp.CO2eKg, fp.EmissionFactorID, fp.Scope1CO2eKg, fp.Scope2CO2eKg, fp.Scope3CO2eKg, id)
+	return err
+}
+
+// SumFootprints returns the stored kgCO2e per activity table for the filter.
+func SumFootprints(f ActivityFilter) (map[string]float64, error) {
+	totals := make(map[string]float64)
+	for _, table := range ActivityTables {
+		where, args := f.whereClause(activityLocationColumns[table])
+		var total float64
+		query := `SELECT COALESCE(SUM(co2e_kg), 0) FROM ` + table + where
+		if err := config.DB.QueryRow(query, args...).Scan(&total); err != nil {
+			return nil, fmt.Errorf("%s: %w", table, err)
+		}
+		totals[table] = total
+	}
+	return totals, nil
+}
+
+// ScopeFootprint is the stored kgCO2e of some records per GHG Protocol scope.
+type ScopeFootprint struct {
+	Scope1CO2eKg float64
+	Scope2CO2eKg float64
+	Scope3CO2eKg float64
+}
+
+// SumScopeFootprints returns the stored kgCO2e per scope and activity table
+// for the filter.
+func SumScopeFootprints(f ActivityFilter) (map[string]ScopeFootprint, error) {
+	totals := make(map[string]ScopeFootprint)
+	for _, table := range ActivityTables {
+		where, args := f.whereClause(activityLocationColumns[table])
+		var total ScopeFootprint
+		query := `SELECT COALESCE(SUM(co2e_scope1_kg), 0), COALESCE(SUM(co2e_scope2_kg), 0), COALESCE(SUM(co2e_scope3_kg), 0)
+			FROM ` + table + where
+		if err := config.DB.QueryRow(query, args...).Scan(&total.Scope1CO2eKg, &total.Scope2CO2eKg, &total.Scope3CO2eKg); err != nil {
+			return nil, fmt.Errorf("%s: %w", table, err)
+		}
+		totals[table] = total
+	}
+	return totals, nil
+}
+
+type FootprintPoint struct {
+	Period time.Time
+	CO2eKg float64
+}
+
+// FootprintSeries returns the stored kgCO2e per activity table bucketed by
+// granularity ('day', 'week' or 'month').
+func FootprintSeries(f ActivityFilter, granularity string) (map[string][]FootprintPoint, error) {
+	series := make(map[string][]FootprintPoint)
+	for _, table := range ActivityTables {
+		where, args := f.whereClause(activityLocationColumns[table])
+		args = append(args, granularity)
+		query := fmt.Sprintf(`SELECT date_trunc($%d, date)::date AS period, COALESCE(SUM(co2e_kg), 0)
+			FROM %s%s GROUP BY period ORDER BY period`, len(args), table, where)
+
+		rows, err := config.DB.Query(query, args...)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", table, err)
+		}
+		for rows.Next() {
+			p := FootprintPoint{}
+			if err := rows.Scan(&p.Period, &p.CO2eKg); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("%s: %w", table, err)
+			}
+			series[table] = append(series[table], p)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("%s: %w", table, err)
+		}
+	}
+	return series, nil
+}
+
+// FootprintsMissing reports whether any activity record lacks its stored
+// footprint or its split by scope, or any solar generation lacks its avoided
+// emissions, e.g. because they were written before these were stored.
+func FootprintsMissing() (bool, error) {
+	for _, table := range ActivityTables {
+		var missing bool
+		where := `co2e_kg IS NULL OR co2e_scope1_kg IS NULL`
+		if table == TableElectricConsumption {
+			where += ` OR (solar_generated_kwh IS NOT NULL AND avoided_co2e_kg IS NULL)`
+		}
+		query := `SELECT EXISTS (SELECT 1 FROM ` + table + ` WHERE ` + where + `)`
+		if err := config.DB.QueryRow(query).Scan(&missing); err != nil {
+			return false, fmt.Errorf("%s: %w", table, err)
+		}
+		if missing {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// recomputeMu serialises RecomputeFootprints, so that a run started after a
+// factor change can't be overwritten by an older one finishing later.
+var recomputeMu sync.Mutex
+
+// RecomputeFootprints recalculates the stored footprint of every activity
+// record, e.g. after the factor library has changed. It returns the number of
+// records updated. Runs are serialised; a call waits for the one in progress.
+func RecomputeFootprints() (int, error) {
+	recomputeMu.Lock()
+	defer recomputeMu.Unlock()
+
+	if newFootprinter == nil {
+		return 0, fmt.Errorf("no footprinter registered")
+	}
+	footprinter, err := newFootprinter()
+	if err != nil {
+		return 0, err
+	}
+
+	all := ActivityFilter{AllOrganisations: true, IncludeDeleted: true}
+	updated := 0
+	recompute := func(table string, id int, fp *Footprint, record interface{}) error {
+		if err := fp.computeWith(footprinter, record); err != nil {
+			return err
+		}
+		if err := updateFootprint(table, id, *fp); err != nil {
+			return fmt.Errorf("%s %d: %w", table, id, err)
+		}
+		updated++
+		return nil
+	}
+
+	electric, err := GetAllElectricConsumptions(all)
+	if err != nil {
+		return updated, err
+	}
+	for i := range electric {
+		if err := recompute(TableElectricConsumption, electric[i].ID, &electric[i].Footprint, &electric[i]); err != nil {
+			return updated, err
+		}
+		if err := electric[i].computeAvoided(footprinter); err != nil {
+			return updated, err
+		}
+		if err := electric[i].updateAvoided(); err != nil {
+			return updated, fmt.Errorf("%s %d: %w", TableElectricConsumption, electric[i].ID, err)
+		}
+	}
+
+	transports, err := GetAllTransports(all)
+	if err != nil {
+		return updated, err
+	}
+	for i := range transports {
+		if err := recompute(TableTransport, transports[i].ID, &transports[i].Footprint, &transports[i]); err != nil {
+			return updated, err
+		}
+	}
+
+	waterConsumptions, err := GetAllWaterConsumptions(all)
+	if err != nil {
+		return updated, err
+	}
+	for i := range waterConsumptions {
+		if err := recompute(TableWaterConsumption, waterConsumptions[i].ID, &waterConsumptions[i].Footprint, &waterConsumptions[i]); err != nil {
+			return updated, err
+		}
+	}
+
+	waterTreatments, err := GetAllWaterTreatments(all)
+	if err != nil {
+		return updated, err
+	}
+	for i := range waterTreatments {
+		if err := recompute(TableWaterTreatment, waterTreatments[i].ID, &waterTreatments[i].Footprint, &waterTreatments[i]); err != nil {
+			return updated, err
+		}
+	}
+
+	wastes, err := GetAllWasteEntries(all)
+	if err != nil {
+		return updated, err
+	}
+	for i := range wastes {
+		if err := recompute(TableWaste, wastes[i].ID, &wastes[i].Footprint, &wastes[i]); err != nil {
+			return updated, err
+		}
+	}
+
+	accommodations, err := GetAllAccommodations(all)
+	if err != nil {
+		return updated, err
+	}
+	for i := range accommodations {
+		if err := recompute(TableAccommodation, accommodations[i].ID, &accommodations[i].Footprint, &accommodations[i]); err != nil {
+			return updated, err
+		}
+	}
+
+	goods, err := GetAllGoodsPurchased(all)
+	if err != nil {
+		return updated, err
+	}
+	for i := range goods {
+		if err := recompute(TableGoodsPurchased, goods[i].ID, &goods[i].Footprint, &goods[i]); err != nil {
+			return updated, err
+		}
+	}
+
+	foods, err := GetAllFoodConsumptions(all)
+	if err != nil {
+		return updated, err
+	}
+	for i := range foods {
+		if err := recompute(TableFoodConsumption, foods[i].ID, &foods[i].Footprint, &foods[i]); err != nil {
+			return updated, err
+		}
+	}
+
+	return updated, nil
+}
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"carbon-footprint-tracker/config"
+	"database/sql"
+	"time"
+)
+
+// GeneratorMonth totals the records of one generator set for a month.
+// Records don't name their generator, so a set is told apart by its
+// location, source and rated capacity.
+//
+// The ratios of the figures are only meaningful over records that have both,
+// so those are summed separately: fuel and energy where both were recorded,
+// energy and running hours likewise, and the footprint of records with energy.
+type GeneratorMonth struct {
+	Location    string
+	Source      string
+	CapacityKVA sql.NullFloat64
+	Month       time.Time
+	Records     int
+
+	RunningHours float64
+	FuelLiters   float64
+	EnergyKWH    float64
+	CO2eKg       float64
+
+	FuelForEnergyLiters float64
+	EnergyForFuelKWH    float64
+	EnergyForHoursKWH   float64
+	HoursForEnergy      float64
+	CO2eForEnergyKg     float64
+	EnergyForCO2eKWH    float64
+}
+
+// GeneratorMonths returns the monthly totals of every diesel and biofuel
+// generator set matching the filter, ordered by set and month.
+func GeneratorMonths(f ActivityFilter) ([]GeneratorMonth, error) {
+	where, args := f.whereClause(activityLocationColumns[TableElectricConsumption])
+	if where == "" {
+		where = " WHERE "
+	} else {
+		where += " AND "
+	}
+	where += "source IN ('Diesel Generator', 'Biofuel Generator')"
+
+	rows, err := config.DB.Query(`SELECT location, source, dg_capacity_kva, date_trunc('month', date)::date AS month, COUNT(*),
+		COALESCE(SUM(running_time_hours), 0),
+		COALESCE(SUM(fuel_consumed_liters), 0),
+		COALESCE(SUM(energy_generated_dg_kwh), 0),
+		COALESCE(SUM(co2e_kg), 0),
+		COALESCE(SUM(fuel_consumed_liters) FILTER (WHERE fuel_consumed_liters IS NOT NULL AND energy_generated_dg_kwh > 0), 0),
+		COALESCE(SUM(energy_generated_dg_kwh) FILTER (WHERE fuel_consumed_liters IS NOT NULL AND energy_generated_dg_kwh > 0), 0),
+		COALESCE(SUM(energy_generated_dg_kwh) FILTER (WHERE energy_generated_dg_kwh IS NOT NULL AND running_time_hours > 0), 0),
+		COALESCE(SUM(running_time_hours) FILTER (WHERE energy_generated_dg_kwh IS NOT NULL AND running_time_hours > 0), 0),
+		COALESCE(SUM(co2e_kg) FILTER (WHERE co2e_kg IS NOT NULL AND energy_generated_dg_kwh > 0), 0),
+		COALESCE(SUM(energy_generated_dg_kwh) FILTER (WHERE co2e_kg IS NOT NULL AND energy_generated_dg_kwh > 0), 0)
+		FROM electric_consumption`+where+`
+		GROUP BY location, source, dg_capacity_kva, month
+		ORDER BY location, source, dg_capacity_kva, month`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	months := []GeneratorMonth{}
+	for rows.Next() {
+		m := GeneratorMonth{}
+		err := rows.Scan(&m.Location, &m.Source, &m.CapacityKVA, &m.Month, &m.Records,
+			&m.RunningHours, &m.FuelLiters, &m.EnergyKWH, &m.CO2eKg,
+			&m.FuelForEnergyLiters, &m.EnergyForFuelKWH, &m.EnergyForHoursKWH, &m.HoursForEnergy,
+			&m.CO2eForEnergyKg, &m.EnergyForCO2eKWH)
+		if err != nil {
+			return nil, err
+		}
+		months = append(months, m)
+	}
+	return months, rows.Err()
+}
@@ -8,6 +8,7 @@ import (
 
 type GoodsPurchased struct {
 	ID                  int             `json:"id"`
+	OrgID               int             `json:"-"` // Owning organisation, implied by the request
 	Date                time.Time       `json:"date"`
 	Location            string          `json:"location"` // Location where goods are delivered/used
 	ItemName            string          `json:"item_name"`
@@ -23,59 +24,117 @@ type GoodsPurchased struct {
 	PackagingType       sql.NullString  `json:"packaging_type,omitempty"` // 'Plastic', 'Paper', 'None'
 	IsRecyclable        sql.NullBool    `json:"is_recyclable,omitempty"`
 	Remarks             sql.NullString  `json:"remarks,omitempty"`
+
+	Footprint
+	Deletion
+}
+
+func (g *GoodsPurchased) Create(by Actor) error {
+	footprinter, err := NewFootprinter()
+	if err != nil {
+		return err
+	}
+	return inTx(func(tx Querier) error { return g.CreateWith(tx, by, footprinter) })
 }
 
-func (g *GoodsPurchased) Create() error {
+// CreateWith inserts the record for by using db, which must be a transaction
+// so that the audit log entry is written with it. Its footprint is computed
+// with footprinter, see NewFootprinter.
+func (g *GoodsPurchased) CreateWith(db Querier, by Actor, footprinter Footprinter) error {
+	g.OrgID = by.OrgID
+	if err := g.Footprint.computeWith(footprinter, g); err != nil {
+		return err
+	}
+
 	query := `INSERT INTO goods_purchased (
 		date, location, item_name, category, quantity, unit, vendor_name, origin,
 		transport_mode, transport_distance_km, bill_amount_inr, bill_attachment_url,
-		packaging_type, is_recyclable, remarks
-	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15) RETURNING id`
-	return config.DB.QueryRow(query,
+		packaging_type, is_recyclable, remarks, co2e_kg, emission_factor_id, co2e_scope1_kg, co2e_scope2_kg, co2e_scope3_kg, org_id
+	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21) RETURNING id`
+	err := db.QueryRow(query,
 		g.Date, g.Location, g.ItemName, g.Category, g.Quantity, g.Unit, g.VendorName, g.Origin,
 		g.TransportMode, g.TransportDistanceKM, g.BillAmountINR, g.BillAttachmentURL,
-		g.PackagingType, g.IsRecyclable, g.Remarks,
+		g.PackagingType, g.IsRecyclable, g.Remarks, g.CO2eKg, g.EmissionFactorID, g.Scope1CO2eKg, g.Scope2CO2eKg, g.Scope3CO2eKg, g.OrgID,
 	).Scan(&g.ID)
+	if err != nil {
+		return err
+	}
+	return by.auditCreate(db, TableGoodsPurchased, g.ID)
+}
+
+func GetAllGoodsPurchased(f ActivityFilter) ([]GoodsPurchased, error) {
+	goods, _, err := ListGoodsPurchased(ListOptions{ActivityFilter: f})
+	return goods, err
+}
+
+// ListGoodsPurchased returns the goods purchase entries matching o and the total number of matches.
+func ListGoodsPurchased(o ListOptions) ([]GoodsPurchased, int, error) {
+	goods := []GoodsPurchased{}
+	err := EachGoodsPurchased(o, func(x GoodsPurchased) error {
+		goods = append(goods, x)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total, err := o.total(ListSpecs[TableGoodsPurchased], len(goods))
+	if err != nil {
+		return nil, 0, err
+	}
+	return goods, total, nil
 }
 
-func GetAllGoodsPurchased() ([]GoodsPurchased, error) {
+// EachGoodsPurchased calls fn for each of the goods purchase entries matching o,
+// streaming rows from the database rather than loading them all at once.
+func EachGoodsPurchased(o ListOptions, fn func(GoodsPurchased) error) error {
+	spec := ListSpecs[TableGoodsPurchased]
+	where, args, err := o.whereClause(spec)
+	if err != nil {
+		return err
+	}
+	order, queryArgs, err := o.orderClause(spec, args)
+	if err != nil {
+		return err
+	}
 	rows, err := config.DB.Query(`SELECT
-		id, date, location, item_name, category, quantity, unit, vendor_name, origin,
+		id, org_id, date, location, item_name, category, quantity, unit, vendor_name, origin,
 		transport_mode, transport_distance_km, bill_amount_inr, bill_attachment_url,
-		packaging_type, is_recyclable, remarks
-		FROM goods_purchased ORDER BY date DESC`)
+		packaging_type, is_recyclable, remarks, co2e_kg, emission_factor_id, deleted_at, deleted_by
+		FROM goods_purchased`+where+order, queryArgs...)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer rows.Close()
 
-	var goods []GoodsPurchased
 	for rows.Next() {
 		g := GoodsPurchased{}
 		err := rows.Scan(
-			&g.ID, &g.Date, &g.Location, &g.ItemName, &g.Category, &g.Quantity, &g.Unit, &g.VendorName,
+			&g.ID, &g.OrgID, &g.Date, &g.Location, &g.ItemName, &g.Category, &g.Quantity, &g.Unit, &g.VendorName,
 			&g.Origin, &g.TransportMode, &g.TransportDistanceKM, &g.BillAmountINR, &g.BillAttachmentURL,
-			&g.PackagingType, &g.IsRecyclable, &g.Remarks,
+			&g.PackagingType, &g.IsRecyclable, &g.Remarks, &g.CO2eKg, &g.EmissionFactorID, &g.DeletedAt, &g.DeletedBy,
 		)
 		if err != nil {
-			return nil, err
+			return err
+		}
+		if err := fn(g); err != nil {
+			return err
 		}
-		goods = append(goods, g)
 	}
-	return goods, nil
+	return rows.Err()
 }
 
-func GetGoodsPurchasedByID(id int) (*GoodsPurchased, error) {
+func GetGoodsPurchasedByID(orgID, id int) (*GoodsPurchased, error) {
 	g := &GoodsPurchased{}
 	query := `SELECT
-		id, date, location, item_name, category, quantity, unit, vendor_name, origin,
+		id, org_id, date, location, item_name, category, quantity, unit, vendor_name, origin,
 		transport_mode, transport_distance_km, bill_amount_inr, bill_attachment_url,
-		packaging_type, is_recyclable, remarks
-		FROM goods_purchased WHERE id = $1`
-	err := config.DB.QueryRow(query, id).Scan(
-		&g.ID, &g.Date, &g.Location, &g.ItemName, &g.Category, &g.Quantity, &g.Unit, &g.VendorName,
+		packaging_type, is_recyclable, remarks, co2e_kg, emission_factor_id, deleted_at, deleted_by
+		FROM goods_purchased WHERE id = $1 AND org_id = $2 AND deleted_at IS NULL`
+	err := config.DB.QueryRow(query, id, orgID).Scan(
+		&g.ID, &g.OrgID, &g.Date, &g.Location, &g.ItemName, &g.Category, &g.Quantity, &g.Unit, &g.VendorName,
 		&g.Origin, &g.TransportMode, &g.TransportDistanceKM, &g.BillAmountINR, &g.BillAttachmentURL,
-		&g.PackagingType, &g.IsRecyclable, &g.Remarks,
+		&g.PackagingType, &g.IsRecyclable, &g.Remarks, &g.CO2eKg, &g.EmissionFactorID, &g.DeletedAt, &g.DeletedBy,
 	)
 	if err != nil {
 		return nil, err
@@ -83,22 +142,25 @@ func GetGoodsPurchasedByID(id int) (*GoodsPurchased, error) {
 	return g, nil
 }
 
-func (g *GoodsPurchased) Update() error {
+func (g *GoodsPurchased) Update(by Actor) error {
+	if err := g.Footprint.compute(g); err != nil {
+		return err
+	}
+
 	query := `UPDATE goods_purchased SET
 		date=$1, location=$2, item_name=$3, category=$4, quantity=$5, unit=$6, vendor_name=$7, origin=$8,
 		transport_mode=$9, transport_distance_km=$10, bill_amount_inr=$11, bill_attachment_url=$12,
-		packaging_type=$13, is_recyclable=$14, remarks=$15
-		WHERE id=$16`
-	_, err := config.DB.Exec(query,
-		g.Date, g.Location, g.ItemName, g.Category, g.Quantity, g.Unit, g.VendorName, g.Origin,
-		g.TransportMode, g.TransportDistanceKM, g.BillAmountINR, g.BillAttachmentURL,
-		g.PackagingType, g.IsRecyclable, g.Remarks, g.ID,
-	)
-	return err
+		packaging_type=$13, is_recyclable=$14, remarks=$15, co2e_kg=$16, emission_factor_id=$17, co2e_scope1_kg=$18, co2e_scope2_kg=$19, co2e_scope3_kg=$20
+		WHERE id=$21 AND org_id=$22 AND deleted_at IS NULL`
+	return by.update(TableGoodsPurchased, g.ID, func(tx Querier) (sql.Result, error) {
+		return tx.Exec(query,
+			g.Date, g.Location, g.ItemName, g.Category, g.Quantity, g.Unit, g.VendorName, g.Origin,
+			g.TransportMode, g.TransportDistanceKM, g.BillAmountINR, g.BillAttachmentURL,
+			g.PackagingType, g.IsRecyclable, g.Remarks, g.CO2eKg, g.EmissionFactorID, g.Scope1CO2eKg, g.Scope2CO2eKg, g.Scope3CO2eKg, g.ID, by.OrgID,
+		)
+	})
 }
 
-func DeleteGoodsPurchased(id int) error {
-	query := `DELETE FROM goods_purchased WHERE id=$1`
-	_, err := config.DB.Exec(query, id)
-	return err
+func DeleteGoodsPurchased(by Actor, id int) error {
+	return by.delete(TableGoodsPurchased, id)
 }
@@ -0,0 +1,107 @@
+package models
+
+import (
+	"carbon-footprint-tracker/config"
+	"carbon-footprint-tracker/utils"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrInvitationUnavailable is returned when an invitation has already been
+// used, has expired or does not exist.
+var ErrInvitationUnavailable = errors.New("invitation is invalid, expired or already used")
+
+// Invitation allows one person to register with the given role in the given
+// organisation. Only the hash of its token is stored; the token itself is
+// shown once on creation.
+type Invitation struct {
+	ID             int           `json:"id"`
+	OrgID          int           `json:"org_id"`
+	Email          string        `json:"email"`
+	Role           string        `json:"role"`
+	InvitedBy      sql.NullInt64 `json:"invited_by,omitempty"`
+	ExpiresAt      time.Time     `json:"expires_at"`
+	AcceptedAt     sql.NullTime  `json:"accepted_at,omitempty"`
+	AcceptedUserID sql.NullInt64 `json:"accepted_user_id,omitempty"`
+	CreatedAt      time.Time     `json:"created_at"`
+}
+
+// Create stores the invitation under the hash of token.
+func (inv *Invitation) Create(token string) error {
+	query := `INSERT INTO invitations (email, role, token_hash, invited_by, expires_at, org_id)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at`
+	return config.DB.QueryRow(query, inv.Email, inv.Role, utils.HashToken(token), inv.InvitedBy, inv.ExpiresAt, inv.OrgID).
+		Scan(&inv.ID, &inv.CreatedAt)
+}
+
+// GetAllInvitations returns the invitations of the organisation orgID.
+func GetAllInvitations(orgID int) ([]Invitation, error) {
+	rows, err := config.DB.Query(`SELECT
+		id, org_id, email, role, invited_by, expires_at, accepted_at, accepted_user_id, created_at
+		FROM invitations WHERE org_id = $1 ORDER BY created_at DESC`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invitations := []Invitation{}
+	for rows.Next() {
+		inv := Invitation{}
+		err := rows.Scan(&inv.ID, &inv.OrgID, &inv.Email, &inv.Role, &inv.InvitedBy, &inv.ExpiresAt, &inv.AcceptedAt, &inv.AcceptedUserID, &inv.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		invitations = append(invitations, inv)
+	}
+	return invitations, rows.Err()
+}
+
+// GetPendingInvitationByToken returns the unused, unexpired invitation for token.
+func GetPendingInvitationByToken(token string) (*Invitation, error) {
+	inv := &Invitation{}
+	query := `SELECT id, org_id, email, role, invited_by, expires_at, accepted_at, accepted_user_id, created_at
+		FROM invitations WHERE token_hash = $1 AND accepted_at IS NULL AND expires_at > NOW()`
+	err := config.DB.QueryRow(query, utils.HashToken(token)).Scan(
+		&inv.ID, &inv.OrgID, &inv.Email, &inv.Role, &inv.InvitedBy, &inv.ExpiresAt, &inv.AcceptedAt, &inv.AcceptedUserID, &inv.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, ErrInvitationUnavailable
+	}
+	if err != nil {
+		return nil, err
+	}
+	return inv, nil
+}
+
+// Accept marks the invitation as used by userID. It fails with
+// ErrInvitationUnavailable if another registration used it first.
+func (inv *Invitation) Accept(db Querier, userID int) error {
+	result, err := db.Exec(`UPDATE invitations SET accepted_at = NOW(), accepted_user_id = $1
+		WHERE id = $2 AND accepted_at IS NULL AND expires_at > NOW()`, userID, inv.ID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n != 1 {
+		return ErrInvitationUnavailable
+	}
+	inv.AcceptedUserID = sql.NullInt64{Int64: int64(userID), Valid: true}
+	return nil
+}
+
+// DeleteInvitation deletes an invitation of the organisation, or returns
+// sql.ErrNoRows if it has none with id.
+func DeleteInvitation(orgID, id int) error {
+	result, err := config.DB.Exec(`DELETE FROM invitations WHERE id = $1 AND org_id = $2`, id, orgID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package models
+
+import (
+	"carbon-footprint-tracker/config"
+	"fmt"
+	"sort"
+)
+
+const TablePopulation = "population"
+
+// ListSpec describes which columns of a module's table can be sorted and
+// filtered on by list endpoints.
+type ListSpec struct {
+	Table          string
+	LocationColumn string
+	Sortable       []string // Date and numeric columns
+	Filterable     []string // Text columns matched exactly
+}
+
+func (s ListSpec) CanSort(column string) bool {
+	return containsColumn(s.Sortable, column)
+}
+
+func (s ListSpec) CanFilter(column string) bool {
+	return containsColumn(s.Filterable, column)
+}
+
+func containsColumn(columns []string, column string) bool {
+	for _, c := range columns {
+		if c == column {
+			return true
+		}
+	}
+	return false
+}
+
+// ListSpecs holds the list spec of each of the nine data entry modules.
+var ListSpecs = map[string]ListSpec{
+	TableElectricConsumption: {
+		Table:          TableElectricConsumption,
+		LocationColumn: activityLocationColumns[TableElectricConsumption],
+		Sortable: []string{"id", "date", "co2e_kg", "dg_capacity_kva", "running_time_hours", "fuel_consumed_liters",
+			"energy_generated_dg_kwh", "grid_electricity_used_kwh", "electricity_bill_kwh", "electricity_bill_cost_inr", "solar_generated_kwh",
+			"solar_self_consumed_kwh", "solar_exported_kwh", "avoided_co2e_kg"},
+		Filterable: []string{"source", "fuel_type"},
+	},
+	TablePopulation: {
+		Table:          TablePopulation,
+		LocationColumn: "location",
+		Sortable:       []string{"id", "date", "registered_count", "floating_count"},
+	},
+	TableTransport: {
+		Table:          TableTransport,
+		LocationColumn: activityLocationColumns[TableTransport],
+		Sortable:       []string{"id", "date", "co2e_kg", "distance_km", "fuel_liters", "people_travelled_count", "fuel_efficiency_km_per_liter"},
+		Filterable:     []string{"vehicle_type", "fuel_type", "vehicle_number"},
+	},
+	TableWaterConsumption: {
+		Table:          TableWaterConsumption,
+		LocationColumn: activityLocationColumns[TableWaterConsumption],
+		Sortable:       []string{"id", "date", "co2e_kg", "cumulative_meter_reading", "total_consumption_kld", "per_capita_consumption_lpd"},
+		Filterable:     []string{"water_source", "usage_type"},
+	},
+	TableWaterTreatment: {
+		Table:          TableWaterTreatment,
+		LocationColumn: activityLocationColumns[TableWaterTreatment],
+		Sortable: []string{"id", "date", "co2e_kg", "treated_liters_per_day", "ultra_filtration_liters_per_day",
+			"percentage_water_reused", "electricity_used_kwh", "chemicals_used_quantity_kg"},
+	},
+	TableWaste: {
+		Table:          TableWaste,
+		LocationColumn: activityLocationColumns[TableWaste],
+		Sortable:       []string{"id", "date", "co2e_kg", "weight_kg"},
+		Filterable:     []string{"waste_type", "sub_category", "collection_method", "destination"},
+	},
+	TableAccommodation: {
+		Table:          TableAccommodation,
+		LocationColumn: activityLocationColumns[TableAccommodation],
+		Sortable:       []string{"id", "date", "co2e_kg", "people_count", "no_of_rooms", "nights", "electricity_consumption_kwh", "water_consumption_lpd"},
+		Filterable:     []string{"category", "accommodation_type", "room_type"},
+	},
+	TableGoodsPurchased: {
+		Table:          TableGoodsPurchased,
+		LocationColumn: activityLocationColumns[TableGoodsPurchased],
+		Sortable:       []string{"id", "date", "co2e_kg", "quantity", "transport_distance_km", "bill_amount_inr"},
+		Filterable:     []string{"category", "vendor_name", "origin", "transport_mode", "packaging_type"},
+	},
+	TableFoodConsumption: {
+		Table:          TableFoodConsumption,
+		LocationColumn: activityLocationColumns[TableFoodConsumption],
+		Sortable:       []string{"id", "date", "co2e_kg", "quantity_cooked_kg_liter", "no_of_meals_served", "water_used_l_washing_cooking", "fuel_used_quantity"},
+		Filterable:     []string{"food_item", "raw_material_source", "fuel_used_type"},
+	},
+}
+
+// ListOptions pages, sorts and filters a list query. A zero PageSize returns
+// every matching row, and an empty Sort orders by date, newest first.
+type ListOptions struct {
+	ActivityFilter
+	Filters  map[string]string // Column -> exact value
+	Sort     string
+	Desc     bool
+	Page     int // 1-based
+	PageSize int
+}
+
+// whereClause extends the date and location filter with the field filters.
+func (o ListOptions) whereClause(spec ListSpec) (string, []interface{}, error) {
+	where, args := o.ActivityFilter.whereClause(spec.LocationColumn)
+
+	columns := make([]string, 0, len(o.Filters))
+	for column := range o.Filters {
+		if !spec.CanFilter(column) {
+			return "", nil, fmt.Errorf("cannot filter %s on %s", spec.Table, column)
+		}
+		columns = append(columns, column)
+	}
+	// Sorted so that the generated SQL is stable between requests.
+	sort.Strings(columns)
+
+	for _, column := range columns {
+		args = append(args, o.Filters[column])
+		condition := fmt.Sprintf("%s = $%d", column, len(args))
+		if where == "" {
+			where = " WHERE " + condition
+		} else {
+			where += " AND " + condition
+		}
+	}
+	return where, args, nil
+}
+
+// orderClause returns the ORDER BY, LIMIT and OFFSET clauses, appending their
+// arguments to args.
+func (o ListOptions) orderClause(spec ListSpec, args []interface{}) (string, []interface{}, error) {
+	column, desc := o.Sort, o.Desc
+	if column == "" {
+		column, desc = "date", true
+	}
+	if !spec.CanSort(column) {
+		return "", nil, fmt.Errorf("cannot sort %s by %s", spec.Table, column)
+	}
+
+	direction := "ASC"
+	if desc {
+		direction = "DESC"
+	}
+	// id breaks ties so that pages don't overlap.
+	clause := fmt.Sprintf(" ORDER BY %s %s NULLS LAST, id %s", column, direction, direction)
+
+	if o.PageSize > 0 {
+		page := o.Page
+		if page < 1 {
+			page = 1
+		}
+		args = append(args, o.PageSize, (page-1)*o.PageSize)
+		clause += fmt.Sprintf(" LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+	}
+	return clause, args, nil
+}
+
+// total returns the number of rows matching o. Unpaged queries already hold
+// every match, so returned is used instead of counting again.
+func (o ListOptions) total(spec ListSpec, returned int) (int, error) {
+	if o.PageSize == 0 {
+		return returned, nil
+	}
+	where, args, err := o.whereClause(spec)
+	if err != nil {
+		return 0, err
+	}
+	var total int
+	err = config.DB.QueryRow(`SELECT COUNT(*) FROM `+spec.Table+where, args...).Scan(&total)
+	return total, err
+}
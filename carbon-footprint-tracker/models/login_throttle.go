@@ -0,0 +1,151 @@
+package models
+
+import (
+	"carbon-footprint-tracker/config"
+	"database/sql"
+	"time"
+)
+
+// Login throttling. Past a number of free attempts, each failed login doubles
+// the wait before the next one is allowed, up to a cap. Enough failures on one
+// account lock it for LoginLockoutDuration or until an admin unlocks it.
+// Counters start over once there has been no failure for loginFailureWindow.
+const (
+	accountFreeAttempts  = 3
+	accountMaxDelay      = 5 * time.Minute
+	LoginLockoutAttempts = 10
+	LoginLockoutDuration = 30 * time.Minute
+
+	// IPs get more slack, as many users can share one behind NAT.
+	ipFreeAttempts = 20
+	ipMaxDelay     = 15 * time.Minute
+
+	loginBaseDelay     = time.Second
+	loginFailureWindow = time.Hour
+)
+
+// loginBackoff returns how long to wait after the given number of consecutive
+// failures.
+func loginBackoff(failures, free int, max time.Duration) time.Duration {
+	if failures <= free {
+		return 0
+	}
+	delay := loginBaseDelay
+	for i := free + 1; i < failures && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+	return delay
+}
+
+// RecordAccountLoginFailure counts a wrong password for userID and returns
+// the time until which further attempts are refused, if any.
+func RecordAccountLoginFailure(userID int) (sql.NullTime, error) {
+	var lockedUntil sql.NullTime
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return lockedUntil, err
+	}
+	defer tx.Rollback() // No-op once committed
+
+	var failures int
+	var lastFailed sql.NullTime
+	err = tx.QueryRow(`SELECT failed_attempts, last_failed_login_at FROM users WHERE id = $1 FOR UPDATE`, userID).
+		Scan(&failures, &lastFailed)
+	if err != nil {
+		return lockedUntil, err
+	}
+
+	now := time.Now()
+	if lastFailed.Valid && now.Sub(lastFailed.Time) > loginFailureWindow {
+		failures = 0
+	}
+	failures++
+	if failures >= LoginLockoutAttempts {
+		lockedUntil = sql.NullTime{Time: now.Add(LoginLockoutDuration), Valid: true}
+	} else if delay := loginBackoff(failures, accountFreeAttempts, accountMaxDelay); delay > 0 {
+		lockedUntil = sql.NullTime{Time: now.Add(delay), Valid: true}
+	}
+
+	_, err = tx.Exec(`UPDATE users SET failed_attempts = $1, last_failed_login_at = $2, locked_until = $3 WHERE id = $4`,
+		failures, now, lockedUntil, userID)
+	if err != nil {
+		return lockedUntil, err
+	}
+	return lockedUntil, tx.Commit()
+}
+
+// RecordIPLoginFailure counts a failed login from ip and returns the time
+// until which further attempts from it are refused, if any.
+func RecordIPLoginFailure(ip string) (sql.NullTime, error) {
+	var blockedUntil sql.NullTime
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return blockedUntil, err
+	}
+	defer tx.Rollback() // No-op once committed
+
+	if _, err := tx.Exec(`INSERT INTO login_ip_attempts (ip) VALUES ($1) ON CONFLICT (ip) DO NOTHING`, ip); err != nil {
+		return blockedUntil, err
+	}
+	var failures int
+	var lastFailed sql.NullTime
+	err = tx.QueryRow(`SELECT failed_attempts, last_failed_at FROM login_ip_attempts WHERE ip = $1 FOR UPDATE`, ip).
+		Scan(&failures, &lastFailed)
+	if err != nil {
+		return blockedUntil, err
+	}
+
+	now := time.Now()
+	if lastFailed.Valid && now.Sub(lastFailed.Time) > loginFailureWindow {
+		failures = 0
+	}
+	failures++
+	if delay := loginBackoff(failures, ipFreeAttempts, ipMaxDelay); delay > 0 {
+		blockedUntil = sql.NullTime{Time: now.Add(delay), Valid: true}
+	}
+
+	_, err = tx.Exec(`UPDATE login_ip_attempts SET failed_attempts = $1, last_failed_at = $2, blocked_until = $3 WHERE ip = $4`,
+		failures, now, blockedUntil, ip)
+	if err != nil {
+		return blockedUntil, err
+	}
+	// Forget IPs that have been quiet for a while, so the table stays small.
+	_, err = tx.Exec(`DELETE FROM login_ip_attempts WHERE last_failed_at < $1`, now.Add(-loginFailureWindow))
+	if err != nil {
+		return blockedUntil, err
+	}
+	return blockedUntil, tx.Commit()
+}
+
+// GetIPBlockedUntil returns the time until which logins from ip are refused.
+// It is not valid if ip isn't blocked.
+func GetIPBlockedUntil(ip string) (sql.NullTime, error) {
+	var blockedUntil sql.NullTime
+	err := config.DB.QueryRow(`SELECT blocked_until FROM login_ip_attempts WHERE ip = $1`, ip).Scan(&blockedUntil)
+	if err == sql.ErrNoRows {
+		return blockedUntil, nil
+	}
+	return blockedUntil, err
+}
+
+// ClearLoginFailures resets the wrong password and two-factor code counters of
+// userID after a completed login.
+func ClearLoginFailures(userID int) error {
+	_, err := config.DB.Exec(`UPDATE users SET failed_attempts = 0, last_failed_login_at = NULL, locked_until = NULL, mfa_failed_attempts = 0
+		WHERE id = $1`, userID)
+	return err
+}
+
+// RecordLogin stores the time of a completed login for userID.
+func RecordLogin(userID int) error {
+	_, err := config.DB.Exec(`UPDATE users SET last_login_at = NOW() WHERE id = $1`, userID)
+	return err
+}
+
+// UnlockUser lifts any lockout or backoff on userID.
+func UnlockUser(userID int) error {
+	return ClearLoginFailures(userID)
+}
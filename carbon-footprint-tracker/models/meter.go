@@ -0,0 +1,299 @@
+package models
+
+import (
+	"carbon-footprint-tracker/config"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrMeterNotFound         = errors.New("meter not found")
+	ErrMeterExists           = errors.New("meter number already exists")
+	ErrMeterInUse            = errors.New("meter has readings")
+	ErrReadingOutOfOrder     = errors.New("reading is older than the meter's latest reading")
+	ErrReplacementIncomplete = errors.New("replaced_start_reading needs replaced_final_reading")
+	ErrReplacementBackwards  = errors.New("replaced meter readings must not go backwards")
+	ErrReadingOverLimit      = errors.New("reading is at or above the meter's rollover limit")
+)
+
+// MeterFlagNegativeDelta marks a reading below the previous one that isn't
+// explained by a rollover or a replacement, e.g. a misread register.
+const MeterFlagNegativeDelta = "negative_delta"
+
+// Meter is a physical electricity meter measuring grid supply at a location.
+type Meter struct {
+	ID            int             `json:"id"`
+	OrgID         int             `json:"-"` // Owning organisation, implied by the request
+	MeterNumber   string          `json:"meter_number"`
+	Location      string          `json:"location"`
+	Multiplier    float64         `json:"multiplier"`               // kWh per register unit
+	RolloverLimit sql.NullFloat64 `json:"rollover_limit,omitempty"` // Register value that wraps to zero
+	Remarks       sql.NullString  `json:"remarks,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+}
+
+// MeterReading is a cumulative register reading. Recording it computes the
+// consumption since the previous unflagged reading of the meter.
+type MeterReading struct {
+	ID                    int             `json:"id"`
+	MeterID               int             `json:"meter_id"`
+	Date                  time.Time       `json:"date"`
+	Reading               float64         `json:"reading"`
+	ReplacedFinalReading  sql.NullFloat64 `json:"replaced_final_reading,omitempty"`
+	ReplacedStartReading  sql.NullFloat64 `json:"replaced_start_reading,omitempty"`
+	PreviousReadingID     sql.NullInt64   `json:"previous_reading_id,omitempty"`
+	ConsumptionKWH        sql.NullFloat64 `json:"consumption_kwh,omitempty"`
+	RolledOver            bool            `json:"rolled_over"`
+	Flag                  sql.NullString  `json:"flag,omitempty"`
+	ElectricConsumptionID sql.NullInt64   `json:"electric_consumption_id,omitempty"`
+	CreatedBy             sql.NullInt64   `json:"created_by,omitempty"`
+	APIKeyID              sql.NullInt64   `json:"api_key_id,omitempty"`
+	CreatedAt             time.Time       `json:"created_at"`
+}
+
+const meterColumns = `id, org_id, meter_number, location, multiplier, rollover_limit, remarks, created_at, updated_at`
+
+func scanMeter(row interface{ Scan(...interface{}) error }) (*Meter, error) {
+	m := &Meter{}
+	err := row.Scan(&m.ID, &m.OrgID, &m.MeterNumber, &m.Location, &m.Multiplier, &m.RolloverLimit, &m.Remarks, &m.CreatedAt, &m.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// GetAllMeters returns the meters of the organisation orgID, limited to
+// locations unless it is nil.
+func GetAllMeters(orgID int, locations []string) ([]Meter, error) {
+	query := `SELECT ` + meterColumns + ` FROM meters WHERE org_id = $1`
+	args := []interface{}{orgID}
+	if locations != nil {
+		query += ` AND location = ANY($2)`
+		args = append(args, pq.Array(locations))
+	}
+	rows, err := config.DB.Query(query+` ORDER BY location, meter_number`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	meters := []Meter{}
+	for rows.Next() {
+		m, err := scanMeter(rows)
+		if err != nil {
+			return nil, err
+		}
+		meters = append(meters, *m)
+	}
+	return meters, rows.Err()
+}
+
+// GetMeterByID returns the meter with id of the organisation orgID, or
+// ErrMeterNotFound.
+func GetMeterByID(orgID, id int) (*Meter, error) {
+	m, err := scanMeter(config.DB.QueryRow(`SELECT `+meterColumns+` FROM meters WHERE id = $1 AND org_id = $2`, id, orgID))
+	if err == sql.ErrNoRows {
+		return nil, ErrMeterNotFound
+	}
+	return m, err
+}
+
+func (m *Meter) Create() error {
+	err := config.DB.QueryRow(`INSERT INTO meters (org_id, meter_number, location, multiplier, rollover_limit, remarks)
+		VALUES ($1, $2, $3, $4, $5, $6) ON CONFLICT (org_id, meter_number) DO NOTHING RETURNING id, created_at, updated_at`,
+		m.OrgID, m.MeterNumber, m.Location, m.Multiplier, m.RolloverLimit, m.Remarks,
+	).Scan(&m.ID, &m.CreatedAt, &m.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return ErrMeterExists
+	}
+	return err
+}
+
+// Update saves changes to the meter. A new multiplier or rollover limit only
+// applies to readings recorded from now on.
+func (m *Meter) Update() error {
+	err := config.DB.QueryRow(`UPDATE meters SET meter_number = $1, location = $2, multiplier = $3, rollover_limit = $4, remarks = $5, updated_at = NOW()
+		WHERE id = $6 AND org_id = $7 RETURNING created_at, updated_at`,
+		m.MeterNumber, m.Location, m.Multiplier, m.RolloverLimit, m.Remarks, m.ID, m.OrgID,
+	).Scan(&m.CreatedAt, &m.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return ErrMeterNotFound
+	}
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" { // unique_violation
+		return ErrMeterExists
+	}
+	return err
+}
+
+// DeleteMeter deletes a meter that has no readings.
+func DeleteMeter(orgID, id int) error {
+	result, err := config.DB.Exec(`DELETE FROM meters WHERE id = $1 AND org_id = $2`, id, orgID)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" { // foreign_key_violation
+		return ErrMeterInUse
+	}
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrMeterNotFound
+	}
+	return nil
+}
+
+const meterReadingColumns = `id, meter_id, date, reading, replaced_final_reading, replaced_start_reading, previous_reading_id,
+	consumption_kwh, rolled_over, flag, electric_consumption_id, created_by, api_key_id, created_at`
+
+func scanMeterReading(row interface{ Scan(...interface{}) error }) (*MeterReading, error) {
+	r := &MeterReading{}
+	err := row.Scan(&r.ID, &r.MeterID, &r.Date, &r.Reading, &r.ReplacedFinalReading, &r.ReplacedStartReading, &r.PreviousReadingID,
+		&r.ConsumptionKWH, &r.RolledOver, &r.Flag, &r.ElectricConsumptionID, &r.CreatedBy, &r.APIKeyID, &r.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetMeterReadings returns the readings of the meter with id of the
+// organisation orgID, newest first.
+func GetMeterReadings(orgID, meterID int) ([]MeterReading, error) {
+	rows, err := config.DB.Query(`SELECT `+meterReadingColumns+` FROM meter_readings
+		WHERE meter_id = $1 AND org_id = $2 ORDER BY date DESC, id DESC`, meterID, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	readings := []MeterReading{}
+	for rows.Next() {
+		r, err := scanMeterReading(rows)
+		if err != nil {
+			return nil, err
+		}
+		readings = append(readings, *r)
+	}
+	return readings, rows.Err()
+}
+
+// RecordMeterReading records r for the meter r.MeterID and computes the
+// consumption since the meter's previous unflagged reading, see
+// Meter.consumption. Unless the reading is the meter's first or is flagged,
+// the consumption is added as grid electricity at the meter's location, dated
+// on the reading, which is how it enters the emissions.
+//
+// Readings must be recorded in date order; an older one returns
+// ErrReadingOutOfOrder. A register value at or above the meter's rollover
+// limit returns ErrReadingOverLimit, and a replacement whose registers went
+// backwards returns ErrReplacementBackwards, as it would leave no baseline for
+// the new register.
+func RecordMeterReading(by Actor, r *MeterReading) error {
+	if r.ReplacedStartReading.Valid && !r.ReplacedFinalReading.Valid {
+		return ErrReplacementIncomplete
+	}
+	if r.ReplacedFinalReading.Valid && r.Reading < r.ReplacedStartReading.Float64 {
+		return ErrReplacementBackwards
+	}
+	footprinter, err := NewFootprinter()
+	if err != nil {
+		return err
+	}
+	return inTx(func(tx Querier) error {
+		m, err := scanMeter(tx.QueryRow(`SELECT `+meterColumns+` FROM meters WHERE id = $1 AND org_id = $2 FOR UPDATE`, r.MeterID, by.OrgID))
+		if err == sql.ErrNoRows {
+			return ErrMeterNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if m.RolloverLimit.Valid {
+			for _, value := range []sql.NullFloat64{{Float64: r.Reading, Valid: true}, r.ReplacedFinalReading, r.ReplacedStartReading} {
+				if value.Valid && value.Float64 >= m.RolloverLimit.Float64 {
+					return ErrReadingOverLimit
+				}
+			}
+		}
+
+		var latest time.Time
+		err = tx.QueryRow(`SELECT date FROM meter_readings WHERE meter_id = $1 ORDER BY date DESC LIMIT 1`, m.ID).Scan(&latest)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		if r.Date.Before(latest) {
+			return ErrReadingOutOfOrder
+		}
+
+		previous, err := scanMeterReading(tx.QueryRow(`SELECT `+meterReadingColumns+` FROM meter_readings
+			WHERE meter_id = $1 AND flag IS NULL ORDER BY date DESC, id DESC LIMIT 1`, m.ID))
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		if previous != nil {
+			r.PreviousReadingID = sql.NullInt64{Int64: int64(previous.ID), Valid: true}
+			if err := m.consumption(previous, r); err != nil {
+				return err
+			}
+		}
+
+		if r.ConsumptionKWH.Valid && !r.Flag.Valid && r.ConsumptionKWH.Float64 > 0 {
+			e := ElectricConsumption{
+				Date:                   r.Date,
+				Location:               m.Location,
+				Source:                 "Main Board",
+				GridElectricityUsedKWH: r.ConsumptionKWH,
+				Remarks: sql.NullString{
+					String: fmt.Sprintf("Meter %s, readings of %s and %s", m.MeterNumber, previous.Date.Format("2006-01-02"), r.Date.Format("2006-01-02")),
+					Valid:  true,
+				},
+			}
+			if err := e.CreateWith(tx, by, footprinter); err != nil {
+				return err
+			}
+			r.ElectricConsumptionID = sql.NullInt64{Int64: int64(e.ID), Valid: true}
+		}
+
+		r.CreatedBy = by.userID()
+		r.APIKeyID = sql.NullInt64{Int64: int64(by.APIKeyID), Valid: by.APIKeyID != 0}
+		return tx.QueryRow(`INSERT INTO meter_readings (org_id, meter_id, date, reading, replaced_final_reading, replaced_start_reading,
+			previous_reading_id, consumption_kwh, rolled_over, flag, electric_consumption_id, created_by, api_key_id)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13) RETURNING id, created_at`,
+			by.OrgID, m.ID, r.Date, r.Reading, r.ReplacedFinalReading, r.ReplacedStartReading,
+			r.PreviousReadingID, r.ConsumptionKWH, r.RolledOver, r.Flag, r.ElectricConsumptionID, r.CreatedBy, r.APIKeyID,
+		).Scan(&r.ID, &r.CreatedAt)
+	})
+}
+
+// consumption sets the kWh consumed between previous and r:
+//
+//   - after a replacement, the old register's advance up to its final reading
+//     plus the new register's advance since it was fitted, or
+//     ErrReplacementBackwards if the old register went down;
+//   - if the register went down and the meter has a rollover limit, the
+//     advance through the limit, as long as that is under half of it;
+//   - otherwise the register's advance, flagged as a negative delta if it
+//     went down.
+func (m *Meter) consumption(previous, r *MeterReading) error {
+	delta := r.Reading - previous.Reading
+	switch {
+	case r.ReplacedFinalReading.Valid:
+		old := r.ReplacedFinalReading.Float64 - previous.Reading
+		fitted := r.Reading - r.ReplacedStartReading.Float64 // Zero unless given
+		if old < 0 || fitted < 0 {
+			return ErrReplacementBackwards
+		}
+		delta = old + fitted
+	case delta < 0 && m.RolloverLimit.Valid && delta+m.RolloverLimit.Float64 >= 0 &&
+		delta+m.RolloverLimit.Float64 < m.RolloverLimit.Float64/2:
+		delta += m.RolloverLimit.Float64
+		r.RolledOver = true
+	case delta < 0:
+		r.Flag = sql.NullString{String: MeterFlagNegativeDelta, Valid: true}
+	}
+	r.ConsumptionKWH = sql.NullFloat64{Float64: delta * m.Multiplier, Valid: true}
+	return nil
+}
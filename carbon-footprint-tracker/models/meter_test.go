@@ -0,0 +1,72 @@
+package models
+
+import (
+	"database/sql"
+	"math"
+	"testing"
+)
+
+func TestMeterConsumption(t *testing.T) {
+	reading := func(f float64) sql.NullFloat64 { return sql.NullFloat64{Float64: f, Valid: true} }
+	meter := Meter{Multiplier: 2, RolloverLimit: reading(10000)}
+
+	tests := []struct {
+		name       string
+		meter      Meter
+		previous   float64
+		r          MeterReading
+		want       float64
+		rolledOver bool
+		flagged    bool
+		err        error
+	}{
+		{name: "advance", meter: meter, previous: 1000, r: MeterReading{Reading: 1250}, want: 500},
+		{name: "no advance", meter: meter, previous: 1000, r: MeterReading{Reading: 1000}, want: 0},
+		{name: "rollover", meter: meter, previous: 9900, r: MeterReading{Reading: 100}, want: 400, rolledOver: true},
+		{name: "negative delta", meter: meter, previous: 5000, r: MeterReading{Reading: 4000}, want: -2000, flagged: true},
+		{name: "negative delta without rollover", meter: Meter{Multiplier: 1}, previous: 9900, r: MeterReading{Reading: 100}, want: -9800, flagged: true},
+		{
+			name:     "replacement",
+			meter:    meter,
+			previous: 1000,
+			r:        MeterReading{Reading: 30, ReplacedFinalReading: reading(1100), ReplacedStartReading: reading(10)},
+			want:     240,
+		},
+		{
+			name:     "replacement with a new register at zero",
+			meter:    meter,
+			previous: 1000,
+			r:        MeterReading{Reading: 30, ReplacedFinalReading: reading(1100)},
+			want:     260,
+		},
+		{
+			name:     "replaced register went down",
+			meter:    meter,
+			previous: 1000,
+			r:        MeterReading{Reading: 30, ReplacedFinalReading: reading(900)},
+			err:      ErrReplacementBackwards,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := tt.r
+			err := tt.meter.consumption(&MeterReading{Reading: tt.previous}, &r)
+			if err != tt.err {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			if !r.ConsumptionKWH.Valid || math.Abs(r.ConsumptionKWH.Float64-tt.want) > 1e-9 {
+				t.Errorf("consumption = %+v, want %v", r.ConsumptionKWH, tt.want)
+			}
+			if r.RolledOver != tt.rolledOver {
+				t.Errorf("rolled over = %v, want %v", r.RolledOver, tt.rolledOver)
+			}
+			if r.Flag.Valid != tt.flagged {
+				t.Errorf("flag = %+v, want flagged %v", r.Flag, tt.flagged)
+			}
+		})
+	}
+}
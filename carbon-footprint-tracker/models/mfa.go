@@ -0,0 +1,167 @@
+package models
+
+import (
+	"carbon-footprint-tracker/config"
+	"carbon-footprint-tracker/utils"
+	"database/sql"
+	"errors"
+)
+
+// ErrMFAAlreadyEnabled is returned when starting enrollment for a user who
+// already has two-factor authentication.
+var ErrMFAAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+
+// MFALockoutAttempts is the number of wrong codes since the last accepted one
+// after which the account is locked, see RecordMFAFailure.
+const MFALockoutAttempts = 5
+
+// MFAState is the two-factor authentication state of a user.
+type MFAState struct {
+	Secret         sql.NullString // Set once enrollment has started
+	Enabled        bool
+	FailedAttempts int          // Wrong codes since the last accepted one
+	LockedUntil    sql.NullTime // Login throttling applies to codes too, see RecordAccountLoginFailure
+}
+
+func GetMFAState(userID int) (*MFAState, error) {
+	s := &MFAState{}
+	err := config.DB.QueryRow(`SELECT mfa_secret, mfa_enabled, mfa_failed_attempts, locked_until FROM users WHERE id = $1`, userID).
+		Scan(&s.Secret, &s.Enabled, &s.FailedAttempts, &s.LockedUntil)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// StartMFAEnrollment stores secret for userID until it is confirmed with
+// EnableMFA, replacing any enrollment that wasn't finished.
+func StartMFAEnrollment(userID int, secret string) error {
+	result, err := config.DB.Exec(`UPDATE users SET mfa_secret = $1, mfa_last_step = NULL WHERE id = $2 AND NOT mfa_enabled`, secret, userID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrMFAAlreadyEnabled
+	}
+	return nil
+}
+
+// EnableMFA turns on two-factor authentication for userID once they have
+// confirmed a code at step, and stores recoveryCodes.
+func EnableMFA(userID int, step int64, recoveryCodes []string) error {
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // No-op once committed
+
+	result, err := tx.Exec(`UPDATE users SET mfa_enabled = TRUE, mfa_last_step = $1, mfa_failed_attempts = 0, updated_at = NOW()
+		WHERE id = $2 AND mfa_secret IS NOT NULL AND NOT mfa_enabled`, step, userID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrMFAAlreadyEnabled
+	}
+	if err := replaceRecoveryCodes(tx, userID, recoveryCodes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DisableMFA turns off two-factor authentication for userID and discards
+// their secret and recovery codes.
+func DisableMFA(userID int) error {
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // No-op once committed
+
+	_, err = tx.Exec(`UPDATE users SET mfa_secret = NULL, mfa_enabled = FALSE, mfa_last_step = NULL, mfa_failed_attempts = 0,
+		updated_at = NOW() WHERE id = $1`, userID)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// AcceptTOTPStep records step as the last TOTP step used by userID. It
+// returns false if that step, or a later one, was already used.
+func AcceptTOTPStep(userID int, step int64) (bool, error) {
+	result, err := config.DB.Exec(`UPDATE users SET mfa_last_step = $1
+		WHERE id = $2 AND (mfa_last_step IS NULL OR mfa_last_step < $1)`, step, userID)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n == 1, err
+}
+
+// UseRecoveryCode marks code as used, returning false if userID has no such
+// unused code.
+func UseRecoveryCode(userID int, code string) (bool, error) {
+	result, err := config.DB.Exec(`UPDATE mfa_recovery_codes SET used_at = NOW()
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`, userID, utils.HashToken(utils.NormalizeRecoveryCode(code)))
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n == 1, err
+}
+
+// ReplaceRecoveryCodes discards the recovery codes of userID and stores codes.
+func ReplaceRecoveryCodes(userID int, codes []string) error {
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // No-op once committed
+
+	if err := replaceRecoveryCodes(tx, userID, codes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func replaceRecoveryCodes(db Querier, userID int, codes []string) error {
+	if _, err := db.Exec(`DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	for _, code := range codes {
+		_, err := db.Exec(`INSERT INTO mfa_recovery_codes (user_id, code_hash) VALUES ($1, $2)`,
+			userID, utils.HashToken(utils.NormalizeRecoveryCode(code)))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CountRecoveryCodes returns how many unused recovery codes userID has left.
+func CountRecoveryCodes(userID int) (int, error) {
+	var count int
+	err := config.DB.QueryRow(`SELECT COUNT(*) FROM mfa_recovery_codes WHERE user_id = $1 AND used_at IS NULL`, userID).Scan(&count)
+	return count, err
+}
+
+// RecordMFAFailure counts a wrong code for userID. Once MFALockoutAttempts
+// wrong codes have been entered since the last accepted one, the account is
+// locked for LoginLockoutDuration, as after too many wrong passwords, and the
+// time until which it is locked is returned. Like them, the count is cleared
+// by ClearLoginFailures.
+func RecordMFAFailure(userID int) (sql.NullTime, error) {
+	var lockedUntil sql.NullTime
+	err := config.DB.QueryRow(`UPDATE users SET mfa_failed_attempts = mfa_failed_attempts + 1,
+		locked_until = CASE WHEN mfa_failed_attempts + 1 >= $2 THEN NOW() + make_interval(secs => $3) ELSE locked_until END
+		WHERE id = $1 RETURNING CASE WHEN mfa_failed_attempts >= $2 THEN locked_until END`,
+		userID, MFALockoutAttempts, LoginLockoutDuration.Seconds()).Scan(&lockedUntil)
+	return lockedUntil, err
+}
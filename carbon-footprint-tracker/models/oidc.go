@@ -0,0 +1,79 @@
+package models
+
+import (
+	"carbon-footprint-tracker/config"
+	"database/sql"
+	"errors"
+)
+
+// ErrOIDCEmailInUse is returned when a new single sign-on user's email
+// address belongs to an account the identity provider hasn't verified it for.
+var ErrOIDCEmailInUse = errors.New("email address is in use by another account")
+
+// OIDCLogin is what the identity provider asserts about a user signing in,
+// together with the role their groups map to.
+type OIDCLogin struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Role          string
+}
+
+// ProvisionOIDCUser returns the user signing in with l, creating them in the
+// default organisation on their first sign-in. An existing password account
+// with the same verified email address is linked instead, which removes its
+// password and ends its sessions.
+//
+// The identity provider is the source of the user's name and role, which are
+// updated on every sign-in; super-admins keep their role.
+func ProvisionOIDCUser(l OIDCLogin) (*User, error) {
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback() // No-op once committed
+
+	var id int
+	err = tx.QueryRow(`SELECT id FROM users WHERE oidc_subject = $1 FOR UPDATE`, l.Subject).Scan(&id)
+	if err == sql.ErrNoRows {
+		id, err = linkOrCreateOIDCUser(tx, l)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`UPDATE users SET name = $1, updated_at = NOW(),
+		token_version = token_version + CASE WHEN role NOT IN ($2, $3) THEN 1 ELSE 0 END,
+		role = CASE WHEN role = $3 THEN role ELSE $2 END
+		WHERE id = $4`, l.Name, l.Role, RoleSuperAdmin, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return GetUserByID(id)
+}
+
+func linkOrCreateOIDCUser(tx Querier, l OIDCLogin) (int, error) {
+	var id int
+	var subject sql.NullString
+	err := tx.QueryRow(`SELECT id, oidc_subject FROM users WHERE email = $1 FOR UPDATE`, l.Email).Scan(&id, &subject)
+	if err == sql.ErrNoRows {
+		err = tx.QueryRow(`INSERT INTO users (name, email, role, org_id, oidc_subject) VALUES ($1, $2, $3, $4, $5) RETURNING id`,
+			l.Name, l.Email, l.Role, config.DefaultOrganisationID, l.Subject).Scan(&id)
+		return id, err
+	}
+	if err != nil {
+		return 0, err
+	}
+	if subject.Valid || !l.EmailVerified {
+		return 0, ErrOIDCEmailInUse
+	}
+
+	if _, err := tx.Exec(`UPDATE users SET oidc_subject = $1, password_hash = NULL WHERE id = $2`, l.Subject, id); err != nil {
+		return 0, err
+	}
+	return id, invalidateUserTokens(tx, id)
+}
@@ -0,0 +1,103 @@
+package models
+
+import (
+	"carbon-footprint-tracker/config"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrOrganisationNotFound = errors.New("organisation not found")
+	ErrOrganisationExists   = errors.New("organisation already exists")
+	ErrOrganisationInUse    = errors.New("organisation still has users or data")
+)
+
+// Organisation is an institution sharing the deployment. Its users only ever
+// see its own records.
+type Organisation struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func GetAllOrganisations() ([]Organisation, error) {
+	rows, err := config.DB.Query(`SELECT id, name, created_at, updated_at FROM organisations ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	organisations := []Organisation{}
+	for rows.Next() {
+		o := Organisation{}
+		if err := rows.Scan(&o.ID, &o.Name, &o.CreatedAt, &o.UpdatedAt); err != nil {
+			return nil, err
+		}
+		organisations = append(organisations, o)
+	}
+	return organisations, rows.Err()
+}
+
+// GetOrganisationByID returns the organisation with id, or ErrOrganisationNotFound.
+func GetOrganisationByID(id int) (*Organisation, error) {
+	o := &Organisation{}
+	err := config.DB.QueryRow(`SELECT id, name, created_at, updated_at FROM organisations WHERE id = $1`, id).
+		Scan(&o.ID, &o.Name, &o.CreatedAt, &o.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrOrganisationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// OrganisationExists reports whether an organisation with id exists.
+func OrganisationExists(id int) (bool, error) {
+	var exists bool
+	err := config.DB.QueryRow(`SELECT EXISTS (SELECT 1 FROM organisations WHERE id = $1)`, id).Scan(&exists)
+	return exists, err
+}
+
+func (o *Organisation) Create() error {
+	err := config.DB.QueryRow(`INSERT INTO organisations (name) VALUES ($1) ON CONFLICT (name) DO NOTHING
+		RETURNING id, created_at, updated_at`, o.Name).Scan(&o.ID, &o.CreatedAt, &o.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return ErrOrganisationExists
+	}
+	return err
+}
+
+func (o *Organisation) Update() error {
+	err := config.DB.QueryRow(`UPDATE organisations SET name = $1, updated_at = NOW() WHERE id = $2 RETURNING created_at, updated_at`,
+		o.Name, o.ID).Scan(&o.CreatedAt, &o.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return ErrOrganisationNotFound
+	}
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" { // unique_violation
+		return ErrOrganisationExists
+	}
+	return err
+}
+
+// DeleteOrganisation deletes an organisation without users or records. Its
+// pending invitations are deleted with it.
+func DeleteOrganisation(id int) error {
+	result, err := config.DB.Exec(`DELETE FROM organisations WHERE id = $1`, id)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" { // foreign_key_violation
+		return ErrOrganisationInUse
+	}
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrOrganisationNotFound
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package models
+
+import (
+	"carbon-footprint-tracker/config"
+	"carbon-footprint-tracker/utils"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// PasswordResetTTL is how long an emailed reset token stays valid.
+const PasswordResetTTL = time.Hour
+
+// ErrPasswordResetInvalid is returned for unknown, expired or used reset tokens.
+var ErrPasswordResetInvalid = errors.New("password reset token is invalid, expired or already used")
+
+// CreatePasswordReset stores a new reset token for userID and returns it.
+// Only the hash of the token is kept.
+func CreatePasswordReset(userID int) (string, error) {
+	token, err := utils.GenerateSecureToken(32)
+	if err != nil {
+		return "", err
+	}
+	_, err = config.DB.Exec(`INSERT INTO password_reset_tokens (user_id, token_hash, expires_at) VALUES ($1, $2, $3)`,
+		userID, utils.HashToken(token), time.Now().Add(PasswordResetTTL))
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// GetPasswordResetUser returns the user a pending reset token was issued to.
+func GetPasswordResetUser(token string) (*User, error) {
+	var userID int
+	err := config.DB.QueryRow(`SELECT user_id FROM password_reset_tokens
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()`, utils.HashToken(token)).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return nil, ErrPasswordResetInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+	user, err := GetUserByID(userID)
+	if err == sql.ErrNoRows {
+		return nil, ErrPasswordResetInvalid
+	}
+	return user, err
+}
+
+// ResetPassword uses up token and sets the password of its user. Every other
+// reset token of the user is used up as well, and their sessions are ended.
+func ResetPassword(token, password string) (int, error) {
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback() // No-op once committed
+
+	var userID int
+	err = tx.QueryRow(`UPDATE password_reset_tokens SET used_at = NOW()
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING user_id`, utils.HashToken(token)).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return 0, ErrPasswordResetInvalid
+	}
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(`UPDATE password_reset_tokens SET used_at = NOW() WHERE user_id = $1 AND used_at IS NULL`, userID); err != nil {
+		return 0, err
+	}
+	if err := updatePassword(tx, userID, password); err != nil {
+		return 0, err
+	}
+	return userID, tx.Commit()
+}
@@ -2,59 +2,108 @@ package models
 
 import (
 	"carbon-footprint-tracker/config"
+	"database/sql"
 	"time"
 )
 
 type Population struct {
 	ID              int       `json:"id"`
+	OrgID           int       `json:"-"` // Owning organisation, implied by the request
 	RegisteredCount int       `json:"registered_count"`
 	FloatingCount   int       `json:"floating_count"`
 	Date            time.Time `json:"date"`
 	Location        string    `json:"location"`
+
+	Deletion
 }
 
-func (p *Population) Create() error {
-	query := `INSERT INTO population (registered_count, floating_count, date, location) VALUES ($1, $2, $3, $4) RETURNING id`
-	return config.DB.QueryRow(query, p.RegisteredCount, p.FloatingCount, p.Date, p.Location).Scan(&p.ID)
+func (p *Population) Create(by Actor) error {
+	return inTx(func(tx Querier) error { return p.CreateWith(tx, by) })
 }
 
-func GetAllPopulations() ([]Population, error) {
-	rows, err := config.DB.Query(`SELECT id, registered_count, floating_count, date, location FROM population ORDER BY date DESC`)
+// CreateWith inserts the record for by using db, which must be a transaction
+// so that the audit log entry is written with it.
+func (p *Population) CreateWith(db Querier, by Actor) error {
+	p.OrgID = by.OrgID
+	query := `INSERT INTO population (registered_count, floating_count, date, location, org_id) VALUES ($1, $2, $3, $4, $5) RETURNING id`
+	err := db.QueryRow(query, p.RegisteredCount, p.FloatingCount, p.Date, p.Location, p.OrgID).Scan(&p.ID)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	return by.auditCreate(db, TablePopulation, p.ID)
+}
+
+func GetAllPopulations(f ActivityFilter) ([]Population, error) {
+	populations, _, err := ListPopulations(ListOptions{ActivityFilter: f})
+	return populations, err
+}
+
+// ListPopulations returns the population entries matching o and the total number of matches.
+func ListPopulations(o ListOptions) ([]Population, int, error) {
+	populations := []Population{}
+	err := EachPopulation(o, func(x Population) error {
+		populations = append(populations, x)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total, err := o.total(ListSpecs[TablePopulation], len(populations))
+	if err != nil {
+		return nil, 0, err
+	}
+	return populations, total, nil
+}
+
+// EachPopulation calls fn for each of the population entries matching o,
+// streaming rows from the database rather than loading them all at once.
+func EachPopulation(o ListOptions, fn func(Population) error) error {
+	spec := ListSpecs[TablePopulation]
+	where, args, err := o.whereClause(spec)
+	if err != nil {
+		return err
+	}
+	order, queryArgs, err := o.orderClause(spec, args)
+	if err != nil {
+		return err
+	}
+	rows, err := config.DB.Query(`SELECT id, org_id, registered_count, floating_count, date, location, deleted_at, deleted_by FROM population`+where+order, queryArgs...)
+	if err != nil {
+		return err
 	}
 	defer rows.Close()
 
-	var populations []Population
 	for rows.Next() {
 		p := Population{}
-		err := rows.Scan(&p.ID, &p.RegisteredCount, &p.FloatingCount, &p.Date, &p.Location)
+		err := rows.Scan(&p.ID, &p.OrgID, &p.RegisteredCount, &p.FloatingCount, &p.Date, &p.Location, &p.DeletedAt, &p.DeletedBy)
 		if err != nil {
-			return nil, err
+			return err
+		}
+		if err := fn(p); err != nil {
+			return err
 		}
-		populations = append(populations, p)
 	}
-	return populations, nil
+	return rows.Err()
 }
 
-func GetPopulationByID(id int) (*Population, error) {
+func GetPopulationByID(orgID, id int) (*Population, error) {
 	p := &Population{}
-	query := `SELECT id, registered_count, floating_count, date, location FROM population WHERE id = $1`
-	err := config.DB.QueryRow(query, id).Scan(&p.ID, &p.RegisteredCount, &p.FloatingCount, &p.Date, &p.Location)
+	query := `SELECT id, org_id, registered_count, floating_count, date, location, deleted_at, deleted_by FROM population WHERE id = $1 AND org_id = $2 AND deleted_at IS NULL`
+	err := config.DB.QueryRow(query, id, orgID).Scan(&p.ID, &p.OrgID, &p.RegisteredCount, &p.FloatingCount, &p.Date, &p.Location, &p.DeletedAt, &p.DeletedBy)
 	if err != nil {
 		return nil, err
 	}
 	return p, nil
 }
 
-func (p *Population) Update() error {
-	query := `UPDATE population SET registered_count=$1, floating_count=$2, date=$3, location=$4 WHERE id=$5`
-	_, err := config.DB.Exec(query, p.RegisteredCount, p.FloatingCount, p.Date, p.Location, p.ID)
-	return err
+func (p *Population) Update(by Actor) error {
+	query := `UPDATE population SET registered_count=$1, floating_count=$2, date=$3, location=$4 WHERE id=$5 AND org_id=$6 AND deleted_at IS NULL`
+	return by.update(TablePopulation, p.ID, func(tx Querier) (sql.Result, error) {
+		return tx.Exec(query, p.RegisteredCount, p.FloatingCount, p.Date, p.Location, p.ID, by.OrgID)
+	})
 }
 
-func DeletePopulation(id int) error {
-	query := `DELETE FROM population WHERE id=$1`
-	_, err := config.DB.Exec(query, id)
-	return err
+func DeletePopulation(by Actor, id int) error {
+	return by.delete(TablePopulation, id)
 }
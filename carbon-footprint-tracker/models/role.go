@@ -0,0 +1,245 @@
+package models
+
+import (
+	"carbon-footprint-tracker/config"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Permissions that can be granted to roles. Each data entry module has a read
+// and a write permission; write covers adding, importing, updating and
+// deleting entries.
+const (
+	PermEmissionFactorsRead  = "emission_factors:read"
+	PermEmissionFactorsWrite = "emission_factors:write"
+	PermReportsRead          = "reports:read"
+	PermReportsExport        = "reports:export"
+	PermDashboardRead        = "dashboard:read"
+	PermUsersManage          = "users:manage"
+	PermInvitationsManage    = "invitations:manage"
+	PermRolesManage          = "roles:manage"
+	PermOrganisationsManage  = "organisations:manage"
+	PermAuditRead            = "audit:read"
+	PermDeletedRecordsManage = "deleted_records:manage" // List and restore deleted entries of the modules one can read or write
+	PermAPIKeysManage        = "api_keys:manage"
+	PermLocationsAll         = "locations:all" // Access every location, whatever locations the user is assigned
+)
+
+// Modules with read and write permissions, named after their route groups.
+var PermissionModules = []string{
+	"electric", "population", "transport", "water_consumption", "water_treatment",
+	"waste", "accommodation", "goods", "food_consumption",
+}
+
+// ReadPermission and WritePermission return the permissions for a module.
+func ReadPermission(module string) string  { return module + ":read" }
+func WritePermission(module string) string { return module + ":write" }
+
+// Permissions lists every permission, in a stable order.
+var Permissions = func() []string {
+	var perms []string
+	for _, module := range PermissionModules {
+		perms = append(perms, ReadPermission(module), WritePermission(module))
+	}
+	return append(perms,
+		PermEmissionFactorsRead, PermEmissionFactorsWrite,
+		PermReportsRead, PermReportsExport, PermDashboardRead,
+		PermUsersManage, PermInvitationsManage, PermRolesManage, PermOrganisationsManage, PermAuditRead, PermDeletedRecordsManage, PermAPIKeysManage,
+		PermLocationsAll,
+	)
+}()
+
+// SuperAdminPermissions cover data shared by every organisation. Only the
+// superadmin role holds them; they can't be granted to other roles.
+var SuperAdminPermissions = []string{PermEmissionFactorsWrite, PermRolesManage, PermOrganisationsManage}
+
+func IsValidPermission(permission string) bool {
+	return containsPermission(Permissions, permission)
+}
+
+func IsSuperAdminPermission(permission string) bool {
+	return containsPermission(SuperAdminPermissions, permission)
+}
+
+func containsPermission(permissions []string, permission string) bool {
+	for _, p := range permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	ErrRoleNotFound = errors.New("role not found")
+	ErrRoleExists   = errors.New("role already exists")
+	ErrRoleInUse    = errors.New("role is assigned to users")
+	ErrSystemRole   = errors.New("system roles can't be deleted")
+)
+
+// Role is a named set of permissions. The superadmin role always holds every
+// permission and the admin role every one but SuperAdminPermissions, whatever
+// is stored for them.
+type Role struct {
+	Name        string         `json:"name"`
+	Description sql.NullString `json:"description,omitempty"`
+	IsSystem    bool           `json:"is_system"` // One of the built-in roles, which can't be deleted
+	Permissions []string       `json:"permissions"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+}
+
+// rolePermissions returns the stored permissions of role, or the fixed
+// permissions of superadmin and admin.
+func rolePermissions(role string, stored []string) []string {
+	switch role {
+	case RoleSuperAdmin:
+		return append([]string(nil), Permissions...)
+	case RoleAdmin:
+		perms := []string{}
+		for _, p := range Permissions {
+			if !IsSuperAdminPermission(p) {
+				perms = append(perms, p)
+			}
+		}
+		return perms
+	}
+	if stored == nil {
+		return []string{}
+	}
+	return stored
+}
+
+const roleColumns = `name, description, is_system, created_at, updated_at,
+	ARRAY(SELECT permission FROM role_permissions WHERE role = roles.name ORDER BY permission)`
+
+func scanRole(row interface{ Scan(...interface{}) error }) (*Role, error) {
+	r := &Role{}
+	var perms []string
+	if err := row.Scan(&r.Name, &r.Description, &r.IsSystem, &r.CreatedAt, &r.UpdatedAt, pq.Array(&perms)); err != nil {
+		return nil, err
+	}
+	r.Permissions = rolePermissions(r.Name, perms)
+	return r, nil
+}
+
+func GetAllRoles() ([]Role, error) {
+	rows, err := config.DB.Query(`SELECT ` + roleColumns + ` FROM roles ORDER BY is_system DESC, name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := []Role{}
+	for rows.Next() {
+		r, err := scanRole(rows)
+		if err != nil {
+			return nil, err
+		}
+		roles = append(roles, *r)
+	}
+	return roles, rows.Err()
+}
+
+// GetRole returns the role called name, or ErrRoleNotFound.
+func GetRole(name string) (*Role, error) {
+	r, err := scanRole(config.DB.QueryRow(`SELECT `+roleColumns+` FROM roles WHERE name = $1`, name))
+	if err == sql.ErrNoRows {
+		return nil, ErrRoleNotFound
+	}
+	return r, err
+}
+
+// RoleExists reports whether a role called name exists.
+func RoleExists(name string) (bool, error) {
+	var exists bool
+	err := config.DB.QueryRow(`SELECT EXISTS (SELECT 1 FROM roles WHERE name = $1)`, name).Scan(&exists)
+	return exists, err
+}
+
+// Create inserts the role with its permissions.
+func (r *Role) Create() error {
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // No-op once committed
+
+	err = tx.QueryRow(`INSERT INTO roles (name, description) VALUES ($1, $2) ON CONFLICT (name) DO NOTHING
+		RETURNING created_at, updated_at`, r.Name, r.Description).Scan(&r.CreatedAt, &r.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return ErrRoleExists
+	}
+	if err != nil {
+		return err
+	}
+	if err := setRolePermissions(tx, r.Name, r.Permissions); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Update stores the description and permissions of the role. The stored
+// permissions of superadmin and admin are ignored, see Role.
+func (r *Role) Update() error {
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // No-op once committed
+
+	err = tx.QueryRow(`UPDATE roles SET description = $1, updated_at = NOW() WHERE name = $2 RETURNING updated_at`,
+		r.Description, r.Name).Scan(&r.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return ErrRoleNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if err := setRolePermissions(tx, r.Name, r.Permissions); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func setRolePermissions(db Querier, role string, permissions []string) error {
+	if _, err := db.Exec(`DELETE FROM role_permissions WHERE role = $1`, role); err != nil {
+		return err
+	}
+	for _, permission := range permissions {
+		_, err := db.Exec(`INSERT INTO role_permissions (role, permission) VALUES ($1, $2) ON CONFLICT DO NOTHING`, role, permission)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteRole deletes a custom role that no user holds. Pending invitations
+// for it are deleted with it.
+func DeleteRole(name string) error {
+	r, err := GetRole(name)
+	if err != nil {
+		return err
+	}
+	if r.IsSystem {
+		return ErrSystemRole
+	}
+
+	var users int
+	if err := config.DB.QueryRow(`SELECT COUNT(*) FROM users WHERE role = $1`, name).Scan(&users); err != nil {
+		return err
+	}
+	if users > 0 {
+		return ErrRoleInUse
+	}
+
+	_, err = config.DB.Exec(`DELETE FROM roles WHERE name = $1`, name)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" { // foreign_key_violation: assigned meanwhile
+		return ErrRoleInUse
+	}
+	return err
+}
@@ -0,0 +1,153 @@
+package models
+
+import (
+	"carbon-footprint-tracker/config"
+	"carbon-footprint-tracker/utils"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ErrRefreshTokenInvalid is returned for unknown, expired or revoked refresh tokens.
+var ErrRefreshTokenInvalid = errors.New("refresh token is invalid, expired or revoked")
+
+// IssueRefreshToken stores a new refresh token for userID and returns it. An
+// empty familyID starts a new family, as on login.
+func IssueRefreshToken(db Querier, userID int, familyID string) (string, error) {
+	token, err := utils.GenerateSecureToken(32)
+	if err != nil {
+		return "", err
+	}
+	if familyID == "" {
+		if familyID, err = utils.GenerateSecureToken(16); err != nil {
+			return "", err
+		}
+	}
+
+	_, err = db.Exec(`INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at) VALUES ($1, $2, $3, $4)`,
+		userID, familyID, utils.HashToken(token), time.Now().Add(utils.RefreshTokenTTL))
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// RotateRefreshToken revokes token and issues its successor, returning the
+// owner's ID and the new token. A token that has already been rotated is
+// being replayed, possibly by someone who stole it, so presenting one
+// revokes every token in its family.
+func RotateRefreshToken(token string) (int, string, error) {
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return 0, "", err
+	}
+	defer tx.Rollback() // No-op once committed
+
+	var id, userID int
+	var familyID string
+	var expiresAt time.Time
+	var revokedAt sql.NullTime
+	err = tx.QueryRow(`SELECT id, user_id, family_id, expires_at, revoked_at
+		FROM refresh_tokens WHERE token_hash = $1 FOR UPDATE`, utils.HashToken(token)).
+		Scan(&id, &userID, &familyID, &expiresAt, &revokedAt)
+	if err == sql.ErrNoRows {
+		return 0, "", ErrRefreshTokenInvalid
+	}
+	if err != nil {
+		return 0, "", err
+	}
+
+	if revokedAt.Valid {
+		if _, err := tx.Exec(`UPDATE refresh_tokens SET revoked_at = NOW() WHERE family_id = $1 AND revoked_at IS NULL`, familyID); err != nil {
+			return 0, "", err
+		}
+		if err := tx.Commit(); err != nil {
+			return 0, "", err
+		}
+		return 0, "", ErrRefreshTokenInvalid
+	}
+	if !time.Now().Before(expiresAt) {
+		return 0, "", ErrRefreshTokenInvalid
+	}
+
+	if _, err := tx.Exec(`UPDATE refresh_tokens SET revoked_at = NOW() WHERE id = $1`, id); err != nil {
+		return 0, "", err
+	}
+	next, err := IssueRefreshToken(tx, userID, familyID)
+	if err != nil {
+		return 0, "", err
+	}
+	return userID, next, tx.Commit()
+}
+
+// RevokeRefreshToken revokes token, if it belongs to userID, together with
+// every token rotated from the same login.
+func RevokeRefreshToken(userID int, token string) error {
+	_, err := config.DB.Exec(`UPDATE refresh_tokens SET revoked_at = NOW()
+		WHERE revoked_at IS NULL AND family_id = (
+			SELECT family_id FROM refresh_tokens WHERE token_hash = $1 AND user_id = $2
+		)`, utils.HashToken(token), userID)
+	return err
+}
+
+// RevokeAccessToken denylists the access token with the given jti until it
+// expires. Entries for tokens that have since expired are purged.
+func RevokeAccessToken(jti string, expiresAt time.Time) error {
+	if _, err := config.DB.Exec(`DELETE FROM revoked_tokens WHERE expires_at < NOW()`); err != nil {
+		return err
+	}
+	_, err := config.DB.Exec(`INSERT INTO revoked_tokens (jti, expires_at) VALUES ($1, $2) ON CONFLICT (jti) DO NOTHING`, jti, expiresAt)
+	return err
+}
+
+// invalidateUserTokens rejects every access token issued to userID so far, by
+// bumping their token version, and revokes all of their refresh tokens.
+func invalidateUserTokens(db Querier, userID int) error {
+	if _, err := db.Exec(`UPDATE users SET token_version = token_version + 1 WHERE id = $1`, userID); err != nil {
+		return err
+	}
+	_, err := db.Exec(`UPDATE refresh_tokens SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`, userID)
+	return err
+}
+
+// TokenState is what an access token is checked against on each request.
+type TokenState struct {
+	OrgID        int
+	Role         string
+	TokenVersion int
+	Revoked      bool // The token's jti is on the denylist
+	MFAEnabled   bool
+	SSO          bool     // The user signs in through the identity provider, which handles two-factor authentication
+	Permissions  []string // Granted by the user's role
+	Locations    []string // Assigned to the user, see LocationScope
+}
+
+// LocationScope returns the locations the user is limited to, or nil if they
+// can access every location: they have none assigned or their role holds
+// PermLocationsAll.
+func (s *TokenState) LocationScope() []string {
+	if len(s.Locations) == 0 || containsPermission(s.Permissions, PermLocationsAll) {
+		return nil
+	}
+	return s.Locations
+}
+
+// GetTokenState returns the current organisation, role, permissions,
+// locations, token version and two-factor and single sign-on status of userID
+// and whether jti has been revoked.
+// It returns sql.ErrNoRows if the user no longer exists.
+func GetTokenState(userID int, jti string) (*TokenState, error) {
+	s := &TokenState{}
+	err := config.DB.QueryRow(`SELECT org_id, role, token_version, EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = $2), mfa_enabled,
+		oidc_subject IS NOT NULL, ARRAY(SELECT permission FROM role_permissions WHERE role_permissions.role = users.role),
+		ARRAY(SELECT location FROM user_locations WHERE user_locations.user_id = users.id ORDER BY location)
+		FROM users WHERE id = $1`, userID, jti).Scan(&s.OrgID, &s.Role, &s.TokenVersion, &s.Revoked, &s.MFAEnabled, &s.SSO,
+		pq.Array(&s.Permissions), pq.Array(&s.Locations))
+	if err != nil {
+		return nil, err
+	}
+	s.Permissions = rolePermissions(s.Role, s.Permissions)
+	return s, nil
+}
@@ -8,6 +8,7 @@ import (
 
 type Transport struct {
 	ID                       int             `json:"id"`
+	OrgID                    int             `json:"-"` // Owning organisation, implied by the request
 	Date                     time.Time       `json:"date"`
 	EventAreaLocation        string          `json:"event_area_location"`
 	VehicleType              string          `json:"vehicle_type"`
@@ -20,55 +21,113 @@ type Transport struct {
 	PeopleTravelledCount     sql.NullInt32   `json:"people_travelled_count,omitempty"`
 	FuelEfficiencyKMPerLiter sql.NullFloat64 `json:"fuel_efficiency_km_per_liter,omitempty"`
 	Remarks                  sql.NullString  `json:"remarks,omitempty"`
+
+	Footprint
+	Deletion
+}
+
+func (t *Transport) Create(by Actor) error {
+	footprinter, err := NewFootprinter()
+	if err != nil {
+		return err
+	}
+	return inTx(func(tx Querier) error { return t.CreateWith(tx, by, footprinter) })
 }
 
-func (t *Transport) Create() error {
+// CreateWith inserts the record for by using db, which must be a transaction
+// so that the audit log entry is written with it. Its footprint is computed
+// with footprinter, see NewFootprinter.
+func (t *Transport) CreateWith(db Querier, by Actor, footprinter Footprinter) error {
+	t.OrgID = by.OrgID
+	if err := t.Footprint.computeWith(footprinter, t); err != nil {
+		return err
+	}
+
 	query := `INSERT INTO transport (
 		date, event_area_location, vehicle_type, fuel_type, vehicle_number, start_location,
-		end_location, distance_km, fuel_liters, people_travelled_count, fuel_efficiency_km_per_liter, remarks
-	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) RETURNING id`
-	return config.DB.QueryRow(query,
+		end_location, distance_km, fuel_liters, people_travelled_count, fuel_efficiency_km_per_liter, remarks, co2e_kg, emission_factor_id, co2e_scope1_kg, co2e_scope2_kg, co2e_scope3_kg, org_id
+	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18) RETURNING id`
+	err := db.QueryRow(query,
 		t.Date, t.EventAreaLocation, t.VehicleType, t.FuelType, t.VehicleNumber, t.StartLocation,
-		t.EndLocation, t.DistanceKM, t.FuelLiters, t.PeopleTravelledCount, t.FuelEfficiencyKMPerLiter, t.Remarks,
+		t.EndLocation, t.DistanceKM, t.FuelLiters, t.PeopleTravelledCount, t.FuelEfficiencyKMPerLiter, t.Remarks, t.CO2eKg, t.EmissionFactorID, t.Scope1CO2eKg, t.Scope2CO2eKg, t.Scope3CO2eKg, t.OrgID,
 	).Scan(&t.ID)
+	if err != nil {
+		return err
+	}
+	return by.auditCreate(db, TableTransport, t.ID)
+}
+
+func GetAllTransports(f ActivityFilter) ([]Transport, error) {
+	transports, _, err := ListTransports(ListOptions{ActivityFilter: f})
+	return transports, err
 }
 
-func GetAllTransports() ([]Transport, error) {
+// ListTransports returns the transport entries matching o and the total number of matches.
+func ListTransports(o ListOptions) ([]Transport, int, error) {
+	transports := []Transport{}
+	err := EachTransport(o, func(x Transport) error {
+		transports = append(transports, x)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total, err := o.total(ListSpecs[TableTransport], len(transports))
+	if err != nil {
+		return nil, 0, err
+	}
+	return transports, total, nil
+}
+
+// EachTransport calls fn for each of the transport entries matching o,
+// streaming rows from the database rather than loading them all at once.
+func EachTransport(o ListOptions, fn func(Transport) error) error {
+	spec := ListSpecs[TableTransport]
+	where, args, err := o.whereClause(spec)
+	if err != nil {
+		return err
+	}
+	order, queryArgs, err := o.orderClause(spec, args)
+	if err != nil {
+		return err
+	}
 	rows, err := config.DB.Query(`SELECT
-		id, date, event_area_location, vehicle_type, fuel_type, vehicle_number, start_location,
-		end_location, distance_km, fuel_liters, people_travelled_count, fuel_efficiency_km_per_liter, remarks
-		FROM transport ORDER BY date DESC`)
+		id, org_id, date, event_area_location, vehicle_type, fuel_type, vehicle_number, start_location,
+		end_location, distance_km, fuel_liters, people_travelled_count, fuel_efficiency_km_per_liter, remarks, co2e_kg, emission_factor_id, deleted_at, deleted_by
+		FROM transport`+where+order, queryArgs...)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer rows.Close()
 
-	var transports []Transport
 	for rows.Next() {
 		t := Transport{}
 		err := rows.Scan(
-			&t.ID, &t.Date, &t.EventAreaLocation, &t.VehicleType, &t.FuelType, &t.VehicleNumber,
+			&t.ID, &t.OrgID, &t.Date, &t.EventAreaLocation, &t.VehicleType, &t.FuelType, &t.VehicleNumber,
 			&t.StartLocation, &t.EndLocation, &t.DistanceKM, &t.FuelLiters, &t.PeopleTravelledCount,
-			&t.FuelEfficiencyKMPerLiter, &t.Remarks,
+			&t.FuelEfficiencyKMPerLiter, &t.Remarks, &t.CO2eKg, &t.EmissionFactorID, &t.DeletedAt, &t.DeletedBy,
 		)
 		if err != nil {
-			return nil, err
+			return err
+		}
+		if err := fn(t); err != nil {
+			return err
 		}
-		transports = append(transports, t)
 	}
-	return transports, nil
+	return rows.Err()
 }
 
-func GetTransportByID(id int) (*Transport, error) {
+func GetTransportByID(orgID, id int) (*Transport, error) {
 	t := &Transport{}
 	query := `SELECT
-		id, date, event_area_location, vehicle_type, fuel_type, vehicle_number, start_location,
-		end_location, distance_km, fuel_liters, people_travelled_count, fuel_efficiency_km_per_liter, remarks
-		FROM transport WHERE id = $1`
-	err := config.DB.QueryRow(query, id).Scan(
-		&t.ID, &t.Date, &t.EventAreaLocation, &t.VehicleType, &t.FuelType, &t.VehicleNumber,
+		id, org_id, date, event_area_location, vehicle_type, fuel_type, vehicle_number, start_location,
+		end_location, distance_km, fuel_liters, people_travelled_count, fuel_efficiency_km_per_liter, remarks, co2e_kg, emission_factor_id, deleted_at, deleted_by
+		FROM transport WHERE id = $1 AND org_id = $2 AND deleted_at IS NULL`
+	err := config.DB.QueryRow(query, id, orgID).Scan(
+		&t.ID, &t.OrgID, &t.Date, &t.EventAreaLocation, &t.VehicleType, &t.FuelType, &t.VehicleNumber,
 		&t.StartLocation, &t.EndLocation, &t.DistanceKM, &t.FuelLiters, &t.PeopleTravelledCount,
-		&t.FuelEfficiencyKMPerLiter, &t.Remarks,
+		&t.FuelEfficiencyKMPerLiter, &t.Remarks, &t.CO2eKg, &t.EmissionFactorID, &t.DeletedAt, &t.DeletedBy,
 	)
 	if err != nil {
 		return nil, err
@@ -76,20 +135,23 @@ func GetTransportByID(id int) (*Transport, error) {
 	return t, nil
 }
 
-func (t *Transport) Update() error {
+func (t *Transport) Update(by Actor) error {
+	if err := t.Footprint.compute(t); err != nil {
+		return err
+	}
+
 	query := `UPDATE transport SET
 		date=$1, event_area_location=$2, vehicle_type=$3, fuel_type=$4, vehicle_number=$5, start_location=$6,
-		end_location=$7, distance_km=$8, fuel_liters=$9, people_travelled_count=$10, fuel_efficiency_km_per_liter=$11, remarks=$12
-		WHERE id=$13`
-	_, err := config.DB.Exec(query,
-		t.Date, t.EventAreaLocation, t.VehicleType, t.FuelType, t.VehicleNumber, t.StartLocation,
-		t.EndLocation, t.DistanceKM, t.FuelLiters, t.PeopleTravelledCount, t.FuelEfficiencyKMPerLiter, t.Remarks, t.ID,
-	)
-	return err
+		end_location=$7, distance_km=$8, fuel_liters=$9, people_travelled_count=$10, fuel_efficiency_km_per_liter=$11, remarks=$12, co2e_kg=$13, emission_factor_id=$14, co2e_scope1_kg=$15, co2e_scope2_kg=$16, co2e_scope3_kg=$17
+		WHERE id=$18 AND org_id=$19 AND deleted_at IS NULL`
+	return by.update(TableTransport, t.ID, func(tx Querier) (sql.Result, error) {
+		return tx.Exec(query,
+			t.Date, t.EventAreaLocation, t.VehicleType, t.FuelType, t.VehicleNumber, t.StartLocation,
+			t.EndLocation, t.DistanceKM, t.FuelLiters, t.PeopleTravelledCount, t.FuelEfficiencyKMPerLiter, t.Remarks, t.CO2eKg, t.EmissionFactorID, t.Scope1CO2eKg, t.Scope2CO2eKg, t.Scope3CO2eKg, t.ID, by.OrgID,
+		)
+	})
 }
 
-func DeleteTransport(id int) error {
-	query := `DELETE FROM transport WHERE id=$1`
-	_, err := config.DB.Exec(query, id)
-	return err
+func DeleteTransport(by Actor, id int) error {
+	return by.delete(TableTransport, id)
 }
@@ -3,20 +3,65 @@ package models
 import (
 	"carbon-footprint-tracker/config"
 	"carbon-footprint-tracker/utils"
+	"database/sql"
 	"time"
 )
 
+// Built-in roles. Further roles can be defined in the roles table, see Role.
+const (
+	RoleSuperAdmin = "superadmin" // Manages organisations and acts in any of them
+	RoleAdmin      = "admin"
+	RoleStaff      = "staff"
+	RoleViewer     = "viewer"
+)
+
 type User struct {
 	ID           int       `json:"id"`
+	OrgID        int       `json:"org_id"` // Organisation the user belongs to
 	Name         string    `json:"name"`
 	Email        string    `json:"email"`
-	PasswordHash string    `json:"password_hash,omitempty"` // Omit for security on output
-	Role         string    `json:"role"`                    // 'admin', 'staff', 'viewer'
+	PasswordHash string    `json:"password_hash,omitempty"` // Omit for security on output; empty for single sign-on users
+	Role         string    `json:"role"`                    // Name of a role, e.g. 'admin', 'staff', 'viewer'
+	TokenVersion int       `json:"-"`                       // Bumped to invalidate issued tokens
+	MFAEnabled   bool      `json:"mfa_enabled"`             // TOTP two-factor authentication is on
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
+
+	FailedAttempts int          `json:"failed_attempts"` // Consecutive wrong passwords
+	LockedUntil    sql.NullTime `json:"locked_until,omitempty"`
+	LastLoginAt    sql.NullTime `json:"last_login_at,omitempty"`
+
+	OIDCSubject sql.NullString `json:"oidc_subject,omitempty"` // Set for users signing in through the identity provider
+}
+
+// SSO reports whether u signs in through the identity provider rather than
+// with a password.
+func (u *User) SSO() bool {
+	return u.OIDCSubject.Valid
+}
+
+// Locked reports whether login attempts for u are currently refused.
+func (u *User) Locked() bool {
+	return u.LockedUntil.Valid && time.Now().Before(u.LockedUntil.Time)
+}
+
+// LocationScoped reports whether u is limited to their assigned locations, if
+// they have any. Users whose role holds PermLocationsAll never are.
+func (u *User) LocationScoped() (bool, error) {
+	r, err := GetRole(u.Role)
+	if err != nil {
+		return false, err
+	}
+	return !containsPermission(r.Permissions, PermLocationsAll), nil
 }
 
 func (u *User) Create() error {
+	return u.CreateWith(config.DB)
+}
+
+// CreateWith hashes the password held in PasswordHash and inserts the user
+// using db, which may be a transaction.
+func (u *User) CreateWith(db Querier) error {
 	var userID int
 	hashedPassword, err := utils.HashPassword(u.PasswordHash)
 	if err != nil {
@@ -24,8 +69,8 @@ func (u *User) Create() error {
 	}
 	u.PasswordHash = hashedPassword
 
-	query := `INSERT INTO users (name, email, password_hash, role) VALUES ($1, $2, $3, $4) RETURNING id`
-	err = config.DB.QueryRow(query, u.Name, u.Email, u.PasswordHash, u.Role).Scan(&userID)
+	query := `INSERT INTO users (name, email, password_hash, role, org_id) VALUES ($1, $2, $3, $4, $5) RETURNING id`
+	err = db.QueryRow(query, u.Name, u.Email, u.PasswordHash, u.Role, u.OrgID).Scan(&userID)
 	if err != nil {
 		return err
 	}
@@ -35,16 +80,20 @@ func (u *User) Create() error {
 
 func GetUserByEmail(email string) (*User, error) {
 	user := &User{}
-	query := `SELECT id, name, email, password_hash, role FROM users WHERE email = $1`
-	err := config.DB.QueryRow(query, email).Scan(&user.ID, &user.Name, &user.Email, &user.PasswordHash, &user.Role)
+	query := `SELECT id, org_id, name, email, COALESCE(password_hash, ''), role, token_version, mfa_enabled, failed_attempts, locked_until, oidc_subject
+		FROM users WHERE email = $1`
+	err := config.DB.QueryRow(query, email).Scan(&user.ID, &user.OrgID, &user.Name, &user.Email, &user.PasswordHash, &user.Role, &user.TokenVersion,
+		&user.MFAEnabled, &user.FailedAttempts, &user.LockedUntil, &user.OIDCSubject)
 	if err != nil {
 		return nil, err
 	}
 	return user, nil
 }
 
-func GetAllUsers() ([]User, error) {
-	rows, err := config.DB.Query(`SELECT id, name, email, role FROM users`)
+// GetAllUsers returns the users of the organisation orgID.
+func GetAllUsers(orgID int) ([]User, error) {
+	rows, err := config.DB.Query(`SELECT id, org_id, name, email, role, mfa_enabled, failed_attempts, locked_until, last_login_at, oidc_subject
+		FROM users WHERE org_id = $1 ORDER BY id`, orgID)
 	if err != nil {
 		return nil, err
 	}
@@ -53,7 +102,8 @@ func GetAllUsers() ([]User, error) {
 	users := []User{}
 	for rows.Next() {
 		user := User{}
-		err := rows.Scan(&user.ID, &user.Name, &user.Email, &user.Role)
+		err := rows.Scan(&user.ID, &user.OrgID, &user.Name, &user.Email, &user.Role, &user.MFAEnabled,
+			&user.FailedAttempts, &user.LockedUntil, &user.LastLoginAt, &user.OIDCSubject)
 		if err != nil {
 			return nil, err
 		}
@@ -64,22 +114,82 @@ func GetAllUsers() ([]User, error) {
 
 func GetUserByID(id int) (*User, error) {
 	user := &User{}
-	query := `SELECT id, name, email, role FROM users WHERE id = $1`
-	err := config.DB.QueryRow(query, id).Scan(&user.ID, &user.Name, &user.Email, &user.Role)
+	query := `SELECT id, org_id, name, email, role, token_version, mfa_enabled, oidc_subject FROM users WHERE id = $1`
+	err := config.DB.QueryRow(query, id).Scan(&user.ID, &user.OrgID, &user.Name, &user.Email, &user.Role, &user.TokenVersion, &user.MFAEnabled, &user.OIDCSubject)
 	if err != nil {
 		return nil, err
 	}
 	return user, nil
 }
 
-func (u *User) Update() error {
-	query := `UPDATE users SET name=$1, email=$2, role=$3 WHERE id=$4`
-	_, err := config.DB.Exec(query, u.Name, u.Email, u.Role, u.ID)
+// Update stores the name, email and role of u. With endSessions, e.g. as the
+// role changed, every token issued to u is invalidated in the same
+// transaction, see invalidateUserTokens.
+func (u *User) Update(endSessions bool) error {
+	return inTx(func(tx Querier) error {
+		query := `UPDATE users SET name=$1, email=$2, role=$3, updated_at=NOW() WHERE id=$4 AND org_id=$5`
+		if _, err := tx.Exec(query, u.Name, u.Email, u.Role, u.ID, u.OrgID); err != nil {
+			return err
+		}
+		if !endSessions {
+			return nil
+		}
+		return invalidateUserTokens(tx, u.ID)
+	})
+}
+
+// UpdatePassword sets the password of u and ends all of their sessions, by
+// invalidating every access and refresh token issued so far.
+func (u *User) UpdatePassword(password string) error {
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // No-op once committed
+
+	if err := updatePassword(tx, u.ID, password); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	u.TokenVersion++
+	return nil
+}
+
+// GetPasswordHash returns the stored password hash of the user with id, empty
+// for single sign-on users.
+func GetPasswordHash(id int) (string, error) {
+	var hash string
+	err := config.DB.QueryRow(`SELECT COALESCE(password_hash, '') FROM users WHERE id = $1`, id).Scan(&hash)
+	return hash, err
+}
+
+// updatePassword hashes and stores the password of userID, bumps their token
+// version and revokes their refresh tokens. Any login lockout is lifted.
+func updatePassword(db Querier, userID int, password string) error {
+	hashedPassword, err := utils.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`UPDATE users SET password_hash = $1, password_changed_at = NOW(), updated_at = NOW(),
+		token_version = token_version + 1, failed_attempts = 0, last_failed_login_at = NULL, locked_until = NULL WHERE id = $2`, hashedPassword, userID)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`UPDATE refresh_tokens SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`, userID)
 	return err
 }
 
-func DeleteUser(id int) error {
-	query := `DELETE FROM users WHERE id=$1`
-	_, err := config.DB.Exec(query, id)
+// CountUsersWithRole returns how many users hold role.
+func CountUsersWithRole(role string) (int, error) {
+	var count int
+	err := config.DB.QueryRow(`SELECT COUNT(*) FROM users WHERE role = $1`, role).Scan(&count)
+	return count, err
+}
+
+func DeleteUser(orgID, id int) error {
+	query := `DELETE FROM users WHERE id=$1 AND org_id=$2`
+	_, err := config.DB.Exec(query, id, orgID)
 	return err
 }
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"carbon-footprint-tracker/config"
+	"fmt"
+)
+
+// GetUserLocations returns the locations userID is limited to, sorted, or nil
+// if they have none assigned.
+func GetUserLocations(userID int) ([]string, error) {
+	rows, err := config.DB.Query(`SELECT location FROM user_locations WHERE user_id = $1 ORDER BY location`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var locations []string
+	for rows.Next() {
+		var location string
+		if err := rows.Scan(&location); err != nil {
+			return nil, err
+		}
+		locations = append(locations, location)
+	}
+	return locations, rows.Err()
+}
+
+// SetUserLocations replaces the locations userID is limited to.
+func SetUserLocations(userID int, locations []string) error {
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // No-op once committed
+
+	if _, err := tx.Exec(`DELETE FROM user_locations WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	for _, location := range locations {
+		_, err := tx.Exec(`INSERT INTO user_locations (user_id, location) VALUES ($1, $2) ON CONFLICT DO NOTHING`, userID, location)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// RecordLocation returns the location of the record with id in one of the
+// module tables, or sql.ErrNoRows if orgID has none.
+func RecordLocation(orgID int, table string, id int) (string, error) {
+	spec, ok := ListSpecs[table]
+	if !ok {
+		return "", fmt.Errorf("unknown table %s", table)
+	}
+	var location string
+	err := config.DB.QueryRow(`SELECT `+spec.LocationColumn+` FROM `+spec.Table+` WHERE id = $1 AND org_id = $2`, id, orgID).Scan(&location)
+	return location, err
+}
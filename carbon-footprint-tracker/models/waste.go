@@ -8,6 +8,7 @@ import (
 
 type Waste struct {
 	ID                 int            `json:"id"`
+	OrgID              int            `json:"-"` // Owning organisation, implied by the request
 	Date               time.Time      `json:"date"`
 	CollectionLocation string         `json:"collection_location"`    // Renamed from Location, from OCR "Location/Building"
 	WasteType          string         `json:"waste_type"`             // 'Biodegradable', 'Non-Biodegradable', 'Recyclable', 'Landfill'
@@ -17,53 +18,111 @@ type Waste struct {
 	TransportMode      sql.NullString `json:"transport_mode,omitempty"`
 	Destination        sql.NullString `json:"destination,omitempty"` // 'Composting', 'Recycler', 'Landfill', 'Incinerator', 'OWC', 'STP'
 	Remarks            sql.NullString `json:"remarks,omitempty"`
+
+	Footprint
+	Deletion
+}
+
+func (w *Waste) Create(by Actor) error {
+	footprinter, err := NewFootprinter()
+	if err != nil {
+		return err
+	}
+	return inTx(func(tx Querier) error { return w.CreateWith(tx, by, footprinter) })
 }
 
-func (w *Waste) Create() error {
+// CreateWith inserts the record for by using db, which must be a transaction
+// so that the audit log entry is written with it. Its footprint is computed
+// with footprinter, see NewFootprinter.
+func (w *Waste) CreateWith(db Querier, by Actor, footprinter Footprinter) error {
+	w.OrgID = by.OrgID
+	if err := w.Footprint.computeWith(footprinter, w); err != nil {
+		return err
+	}
+
 	query := `INSERT INTO waste (
 		date, collection_location, waste_type, sub_category, weight_kg,
-		collection_method, transport_mode, destination, remarks
-	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id`
-	return config.DB.QueryRow(query,
+		collection_method, transport_mode, destination, remarks, co2e_kg, emission_factor_id, co2e_scope1_kg, co2e_scope2_kg, co2e_scope3_kg, org_id
+	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15) RETURNING id`
+	err := db.QueryRow(query,
 		w.Date, w.CollectionLocation, w.WasteType, w.SubCategory, w.WeightKG,
-		w.CollectionMethod, w.TransportMode, w.Destination, w.Remarks,
+		w.CollectionMethod, w.TransportMode, w.Destination, w.Remarks, w.CO2eKg, w.EmissionFactorID, w.Scope1CO2eKg, w.Scope2CO2eKg, w.Scope3CO2eKg, w.OrgID,
 	).Scan(&w.ID)
+	if err != nil {
+		return err
+	}
+	return by.auditCreate(db, TableWaste, w.ID)
+}
+
+func GetAllWasteEntries(f ActivityFilter) ([]Waste, error) {
+	wastes, _, err := ListWasteEntries(ListOptions{ActivityFilter: f})
+	return wastes, err
 }
 
-func GetAllWasteEntries() ([]Waste, error) {
+// ListWasteEntries returns the waste entries matching o and the total number of matches.
+func ListWasteEntries(o ListOptions) ([]Waste, int, error) {
+	wastes := []Waste{}
+	err := EachWasteEntry(o, func(x Waste) error {
+		wastes = append(wastes, x)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total, err := o.total(ListSpecs[TableWaste], len(wastes))
+	if err != nil {
+		return nil, 0, err
+	}
+	return wastes, total, nil
+}
+
+// EachWasteEntry calls fn for each of the waste entries matching o,
+// streaming rows from the database rather than loading them all at once.
+func EachWasteEntry(o ListOptions, fn func(Waste) error) error {
+	spec := ListSpecs[TableWaste]
+	where, args, err := o.whereClause(spec)
+	if err != nil {
+		return err
+	}
+	order, queryArgs, err := o.orderClause(spec, args)
+	if err != nil {
+		return err
+	}
 	rows, err := config.DB.Query(`SELECT
-		id, date, collection_location, waste_type, sub_category, weight_kg,
-		collection_method, transport_mode, destination, remarks
-		FROM waste ORDER BY date DESC`)
+		id, org_id, date, collection_location, waste_type, sub_category, weight_kg,
+		collection_method, transport_mode, destination, remarks, co2e_kg, emission_factor_id, deleted_at, deleted_by
+		FROM waste`+where+order, queryArgs...)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer rows.Close()
 
-	var wastes []Waste
 	for rows.Next() {
 		w := Waste{}
 		err := rows.Scan(
-			&w.ID, &w.Date, &w.CollectionLocation, &w.WasteType, &w.SubCategory, &w.WeightKG,
-			&w.CollectionMethod, &w.TransportMode, &w.Destination, &w.Remarks,
+			&w.ID, &w.OrgID, &w.Date, &w.CollectionLocation, &w.WasteType, &w.SubCategory, &w.WeightKG,
+			&w.CollectionMethod, &w.TransportMode, &w.Destination, &w.Remarks, &w.CO2eKg, &w.EmissionFactorID, &w.DeletedAt, &w.DeletedBy,
 		)
 		if err != nil {
-			return nil, err
+			return err
+		}
+		if err := fn(w); err != nil {
+			return err
 		}
-		wastes = append(wastes, w)
 	}
-	return wastes, nil
+	return rows.Err()
 }
 
-func GetWasteByID(id int) (*Waste, error) {
+func GetWasteByID(orgID, id int) (*Waste, error) {
 	w := &Waste{}
 	query := `SELECT
-		id, date, collection_location, waste_type, sub_category, weight_kg,
-		collection_method, transport_mode, destination, remarks
-		FROM waste WHERE id = $1`
-	err := config.DB.QueryRow(query, id).Scan(
-		&w.ID, &w.Date, &w.CollectionLocation, &w.WasteType, &w.SubCategory, &w.WeightKG,
-		&w.CollectionMethod, &w.TransportMode, &w.Destination, &w.Remarks,
+		id, org_id, date, collection_location, waste_type, sub_category, weight_kg,
+		collection_method, transport_mode, destination, remarks, co2e_kg, emission_factor_id, deleted_at, deleted_by
+		FROM waste WHERE id = $1 AND org_id = $2 AND deleted_at IS NULL`
+	err := config.DB.QueryRow(query, id, orgID).Scan(
+		&w.ID, &w.OrgID, &w.Date, &w.CollectionLocation, &w.WasteType, &w.SubCategory, &w.WeightKG,
+		&w.CollectionMethod, &w.TransportMode, &w.Destination, &w.Remarks, &w.CO2eKg, &w.EmissionFactorID, &w.DeletedAt, &w.DeletedBy,
 	)
 	if err != nil {
 		return nil, err
@@ -71,20 +130,23 @@ func GetWasteByID(id int) (*Waste, error) {
 	return w, nil
 }
 
-func (w *Waste) Update() error {
+func (w *Waste) Update(by Actor) error {
+	if err := w.Footprint.compute(w); err != nil {
+		return err
+	}
+
 	query := `UPDATE waste SET
 		date=$1, collection_location=$2, waste_type=$3, sub_category=$4, weight_kg=$5,
-		collection_method=$6, transport_mode=$7, destination=$8, remarks=$9
-		WHERE id=$10`
-	_, err := config.DB.Exec(query,
-		w.Date, w.CollectionLocation, w.WasteType, w.SubCategory, w.WeightKG,
-		w.CollectionMethod, w.TransportMode, w.Destination, w.Remarks, w.ID,
-	)
-	return err
+		collection_method=$6, transport_mode=$7, destination=$8, remarks=$9, co2e_kg=$10, emission_factor_id=$11, co2e_scope1_kg=$12, co2e_scope2_kg=$13, co2e_scope3_kg=$14
+		WHERE id=$15 AND org_id=$16 AND deleted_at IS NULL`
+	return by.update(TableWaste, w.ID, func(tx Querier) (sql.Result, error) {
+		return tx.Exec(query,
+			w.Date, w.CollectionLocation, w.WasteType, w.SubCategory, w.WeightKG,
+			w.CollectionMethod, w.TransportMode, w.Destination, w.Remarks, w.CO2eKg, w.EmissionFactorID, w.Scope1CO2eKg, w.Scope2CO2eKg, w.Scope3CO2eKg, w.ID, by.OrgID,
+		)
+	})
 }
 
-func DeleteWaste(id int) error {
-	query := `DELETE FROM waste WHERE id=$1`
-	_, err := config.DB.Exec(query, id)
-	return err
+func DeleteWaste(by Actor, id int) error {
+	return by.delete(TableWaste, id)
 }
@@ -8,6 +8,7 @@ import (
 
 type WaterConsumption struct {
 	ID                      int             `json:"id"`
+	OrgID                   int             `json:"-"` // Owning organisation, implied by the request
 	Date                    time.Time       `json:"date"`
 	Location                string          `json:"location"`
 	WaterSource             sql.NullString  `json:"water_source,omitempty"`
@@ -16,53 +17,111 @@ type WaterConsumption struct {
 	PerCapitaConsumptionLPD sql.NullFloat64 `json:"per_capita_consumption_lpd,omitempty"`
 	UsageType               sql.NullString  `json:"usage_type,omitempty"`
 	Remarks                 sql.NullString  `json:"remarks,omitempty"`
+
+	Footprint
+	Deletion
+}
+
+func (w *WaterConsumption) Create(by Actor) error {
+	footprinter, err := NewFootprinter()
+	if err != nil {
+		return err
+	}
+	return inTx(func(tx Querier) error { return w.CreateWith(tx, by, footprinter) })
 }
 
-func (w *WaterConsumption) Create() error {
+// CreateWith inserts the record for by using db, which must be a transaction
+// so that the audit log entry is written with it. Its footprint is computed
+// with footprinter, see NewFootprinter.
+func (w *WaterConsumption) CreateWith(db Querier, by Actor, footprinter Footprinter) error {
+	w.OrgID = by.OrgID
+	if err := w.Footprint.computeWith(footprinter, w); err != nil {
+		return err
+	}
+
 	query := `INSERT INTO water_consumption (
 		date, location, water_source, cumulative_meter_reading, total_consumption_kld,
-		per_capita_consumption_lpd, usage_type, remarks
-	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`
-	return config.DB.QueryRow(query,
+		per_capita_consumption_lpd, usage_type, remarks, co2e_kg, emission_factor_id, co2e_scope1_kg, co2e_scope2_kg, co2e_scope3_kg, org_id
+	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14) RETURNING id`
+	err := db.QueryRow(query,
 		w.Date, w.Location, w.WaterSource, w.CumulativeMeterReading, w.TotalConsumptionKLD,
-		w.PerCapitaConsumptionLPD, w.UsageType, w.Remarks,
+		w.PerCapitaConsumptionLPD, w.UsageType, w.Remarks, w.CO2eKg, w.EmissionFactorID, w.Scope1CO2eKg, w.Scope2CO2eKg, w.Scope3CO2eKg, w.OrgID,
 	).Scan(&w.ID)
+	if err != nil {
+		return err
+	}
+	return by.auditCreate(db, TableWaterConsumption, w.ID)
+}
+
+func GetAllWaterConsumptions(f ActivityFilter) ([]WaterConsumption, error) {
+	consumptions, _, err := ListWaterConsumptions(ListOptions{ActivityFilter: f})
+	return consumptions, err
 }
 
-func GetAllWaterConsumptions() ([]WaterConsumption, error) {
+// ListWaterConsumptions returns the water consumption entries matching o and the total number of matches.
+func ListWaterConsumptions(o ListOptions) ([]WaterConsumption, int, error) {
+	consumptions := []WaterConsumption{}
+	err := EachWaterConsumption(o, func(x WaterConsumption) error {
+		consumptions = append(consumptions, x)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total, err := o.total(ListSpecs[TableWaterConsumption], len(consumptions))
+	if err != nil {
+		return nil, 0, err
+	}
+	return consumptions, total, nil
+}
+
+// EachWaterConsumption calls fn for each of the water consumption entries matching o,
+// streaming rows from the database rather than loading them all at once.
+func EachWaterConsumption(o ListOptions, fn func(WaterConsumption) error) error {
+	spec := ListSpecs[TableWaterConsumption]
+	where, args, err := o.whereClause(spec)
+	if err != nil {
+		return err
+	}
+	order, queryArgs, err := o.orderClause(spec, args)
+	if err != nil {
+		return err
+	}
 	rows, err := config.DB.Query(`SELECT
-		id, date, location, water_source, cumulative_meter_reading, total_consumption_kld,
-		per_capita_consumption_lpd, usage_type, remarks
-		FROM water_consumption ORDER BY date DESC`)
+		id, org_id, date, location, water_source, cumulative_meter_reading, total_consumption_kld,
+		per_capita_consumption_lpd, usage_type, remarks, co2e_kg, emission_factor_id, deleted_at, deleted_by
+		FROM water_consumption`+where+order, queryArgs...)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer rows.Close()
 
-	var consumptions []WaterConsumption
 	for rows.Next() {
 		w := WaterConsumption{}
 		err := rows.Scan(
-			&w.ID, &w.Date, &w.Location, &w.WaterSource, &w.CumulativeMeterReading, &w.TotalConsumptionKLD,
-			&w.PerCapitaConsumptionLPD, &w.UsageType, &w.Remarks,
+			&w.ID, &w.OrgID, &w.Date, &w.Location, &w.WaterSource, &w.CumulativeMeterReading, &w.TotalConsumptionKLD,
+			&w.PerCapitaConsumptionLPD, &w.UsageType, &w.Remarks, &w.CO2eKg, &w.EmissionFactorID, &w.DeletedAt, &w.DeletedBy,
 		)
 		if err != nil {
-			return nil, err
+			return err
+		}
+		if err := fn(w); err != nil {
+			return err
 		}
-		consumptions = append(consumptions, w)
 	}
-	return consumptions, nil
+	return rows.Err()
 }
 
-func GetWaterConsumptionByID(id int) (*WaterConsumption, error) {
+func GetWaterConsumptionByID(orgID, id int) (*WaterConsumption, error) {
 	w := &WaterConsumption{}
 	query := `SELECT
-		id, date, location, water_source, cumulative_meter_reading, total_consumption_kld,
-		per_capita_consumption_lpd, usage_type, remarks
-		FROM water_consumption WHERE id = $1`
-	err := config.DB.QueryRow(query, id).Scan(
-		&w.ID, &w.Date, &w.Location, &w.WaterSource, &w.CumulativeMeterReading, &w.TotalConsumptionKLD,
-		&w.PerCapitaConsumptionLPD, &w.UsageType, &w.Remarks,
+		id, org_id, date, location, water_source, cumulative_meter_reading, total_consumption_kld,
+		per_capita_consumption_lpd, usage_type, remarks, co2e_kg, emission_factor_id, deleted_at, deleted_by
+		FROM water_consumption WHERE id = $1 AND org_id = $2 AND deleted_at IS NULL`
+	err := config.DB.QueryRow(query, id, orgID).Scan(
+		&w.ID, &w.OrgID, &w.Date, &w.Location, &w.WaterSource, &w.CumulativeMeterReading, &w.TotalConsumptionKLD,
+		&w.PerCapitaConsumptionLPD, &w.UsageType, &w.Remarks, &w.CO2eKg, &w.EmissionFactorID, &w.DeletedAt, &w.DeletedBy,
 	)
 	if err != nil {
 		return nil, err
@@ -70,20 +129,23 @@ func GetWaterConsumptionByID(id int) (*WaterConsumption, error) {
 	return w, nil
 }
 
-func (w *WaterConsumption) Update() error {
+func (w *WaterConsumption) Update(by Actor) error {
+	if err := w.Footprint.compute(w); err != nil {
+		return err
+	}
+
 	query := `UPDATE water_consumption SET
 		date=$1, location=$2, water_source=$3, cumulative_meter_reading=$4, total_consumption_kld=$5,
-		per_capita_consumption_lpd=$6, usage_type=$7, remarks=$8
-		WHERE id=$9`
-	_, err := config.DB.Exec(query,
-		w.Date, w.Location, w.WaterSource, w.CumulativeMeterReading, w.TotalConsumptionKLD,
-		w.PerCapitaConsumptionLPD, w.UsageType, w.Remarks, w.ID,
-	)
-	return err
+		per_capita_consumption_lpd=$6, usage_type=$7, remarks=$8, co2e_kg=$9, emission_factor_id=$10, co2e_scope1_kg=$11, co2e_scope2_kg=$12, co2e_scope3_kg=$13
+		WHERE id=$14 AND org_id=$15 AND deleted_at IS NULL`
+	return by.update(TableWaterConsumption, w.ID, func(tx Querier) (sql.Result, error) {
+		return tx.Exec(query,
+			w.Date, w.Location, w.WaterSource, w.CumulativeMeterReading, w.TotalConsumptionKLD,
+			w.PerCapitaConsumptionLPD, w.UsageType, w.Remarks, w.CO2eKg, w.EmissionFactorID, w.Scope1CO2eKg, w.Scope2CO2eKg, w.Scope3CO2eKg, w.ID, by.OrgID,
+		)
+	})
 }
 
-func DeleteWaterConsumption(id int) error {
-	query := `DELETE FROM water_consumption WHERE id=$1`
-	_, err := config.DB.Exec(query, id)
-	return err
+func DeleteWaterConsumption(by Actor, id int) error {
+	return by.delete(TableWaterConsumption, id)
 }
@@ -8,6 +8,7 @@ import (
 
 type WaterTreatment struct {
 	ID                          int             `json:"id"`
+	OrgID                       int             `json:"-"` // Owning organisation, implied by the request
 	Date                        time.Time       `json:"date"`
 	Location                    string          `json:"location"`
 	TreatedLitersPerDay         sql.NullFloat64 `json:"treated_liters_per_day,omitempty"`
@@ -17,60 +18,118 @@ type WaterTreatment struct {
 	ChemicalsUsedDescription    sql.NullString  `json:"chemicals_used_description,omitempty"`
 	ChemicalsUsedQuantityKG     sql.NullFloat64 `json:"chemicals_used_quantity_kg,omitempty"`
 	Remarks                     sql.NullString  `json:"remarks,omitempty"`
+
+	Footprint
+	Deletion
+}
+
+func (wt *WaterTreatment) Create(by Actor) error {
+	footprinter, err := NewFootprinter()
+	if err != nil {
+		return err
+	}
+	return inTx(func(tx Querier) error { return wt.CreateWith(tx, by, footprinter) })
 }
 
-func (wt *WaterTreatment) Create() error {
+// CreateWith inserts the record for by using db, which must be a transaction
+// so that the audit log entry is written with it. Its footprint is computed
+// with footprinter, see NewFootprinter.
+func (wt *WaterTreatment) CreateWith(db Querier, by Actor, footprinter Footprinter) error {
+	wt.OrgID = by.OrgID
+	if err := wt.Footprint.computeWith(footprinter, wt); err != nil {
+		return err
+	}
+
 	query := `INSERT INTO water_treatment (
 		date, location, treated_liters_per_day, ultra_filtration_liters_per_day,
 		percentage_water_reused, electricity_used_kwh, chemicals_used_description,
-		chemicals_used_quantity_kg, remarks
-	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id`
+		chemicals_used_quantity_kg, remarks, co2e_kg, emission_factor_id, co2e_scope1_kg, co2e_scope2_kg, co2e_scope3_kg, org_id
+	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15) RETURNING id`
 
-	return config.DB.QueryRow(query,
+	err := db.QueryRow(query,
 		wt.Date, wt.Location, wt.TreatedLitersPerDay, wt.UltraFiltrationLitersPerDay,
 		wt.PercentageWaterReused, wt.ElectricityUsedKWH, wt.ChemicalsUsedDescription,
-		wt.ChemicalsUsedQuantityKG, wt.Remarks,
+		wt.ChemicalsUsedQuantityKG, wt.Remarks, wt.CO2eKg, wt.EmissionFactorID, wt.Scope1CO2eKg, wt.Scope2CO2eKg, wt.Scope3CO2eKg, wt.OrgID,
 	).Scan(&wt.ID)
+	if err != nil {
+		return err
+	}
+	return by.auditCreate(db, TableWaterTreatment, wt.ID)
+}
+
+func GetAllWaterTreatments(f ActivityFilter) ([]WaterTreatment, error) {
+	treatments, _, err := ListWaterTreatments(ListOptions{ActivityFilter: f})
+	return treatments, err
+}
+
+// ListWaterTreatments returns the water treatment entries matching o and the total number of matches.
+func ListWaterTreatments(o ListOptions) ([]WaterTreatment, int, error) {
+	treatments := []WaterTreatment{}
+	err := EachWaterTreatment(o, func(x WaterTreatment) error {
+		treatments = append(treatments, x)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total, err := o.total(ListSpecs[TableWaterTreatment], len(treatments))
+	if err != nil {
+		return nil, 0, err
+	}
+	return treatments, total, nil
 }
 
-func GetAllWaterTreatments() ([]WaterTreatment, error) {
+// EachWaterTreatment calls fn for each of the water treatment entries matching o,
+// streaming rows from the database rather than loading them all at once.
+func EachWaterTreatment(o ListOptions, fn func(WaterTreatment) error) error {
+	spec := ListSpecs[TableWaterTreatment]
+	where, args, err := o.whereClause(spec)
+	if err != nil {
+		return err
+	}
+	order, queryArgs, err := o.orderClause(spec, args)
+	if err != nil {
+		return err
+	}
 	rows, err := config.DB.Query(`SELECT
-		id, date, location, treated_liters_per_day, ultra_filtration_liters_per_day,
+		id, org_id, date, location, treated_liters_per_day, ultra_filtration_liters_per_day,
 		percentage_water_reused, electricity_used_kwh, chemicals_used_description,
-		chemicals_used_quantity_kg, remarks
-		FROM water_treatment ORDER BY date DESC`)
+		chemicals_used_quantity_kg, remarks, co2e_kg, emission_factor_id, deleted_at, deleted_by
+		FROM water_treatment`+where+order, queryArgs...)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer rows.Close()
 
-	var treatments []WaterTreatment
 	for rows.Next() {
 		wt := WaterTreatment{}
 		err := rows.Scan(
-			&wt.ID, &wt.Date, &wt.Location, &wt.TreatedLitersPerDay, &wt.UltraFiltrationLitersPerDay,
+			&wt.ID, &wt.OrgID, &wt.Date, &wt.Location, &wt.TreatedLitersPerDay, &wt.UltraFiltrationLitersPerDay,
 			&wt.PercentageWaterReused, &wt.ElectricityUsedKWH, &wt.ChemicalsUsedDescription,
-			&wt.ChemicalsUsedQuantityKG, &wt.Remarks,
+			&wt.ChemicalsUsedQuantityKG, &wt.Remarks, &wt.CO2eKg, &wt.EmissionFactorID, &wt.DeletedAt, &wt.DeletedBy,
 		)
 		if err != nil {
-			return nil, err
+			return err
+		}
+		if err := fn(wt); err != nil {
+			return err
 		}
-		treatments = append(treatments, wt)
 	}
-	return treatments, nil
+	return rows.Err()
 }
 
-func GetWaterTreatmentByID(id int) (*WaterTreatment, error) {
+func GetWaterTreatmentByID(orgID, id int) (*WaterTreatment, error) {
 	wt := &WaterTreatment{}
 	query := `SELECT
-		id, date, location, treated_liters_per_day, ultra_filtration_liters_per_day,
+		id, org_id, date, location, treated_liters_per_day, ultra_filtration_liters_per_day,
 		percentage_water_reused, electricity_used_kwh, chemicals_used_description,
-		chemicals_used_quantity_kg, remarks
-		FROM water_treatment WHERE id = $1`
-	err := config.DB.QueryRow(query, id).Scan(
-		&wt.ID, &wt.Date, &wt.Location, &wt.TreatedLitersPerDay, &wt.UltraFiltrationLitersPerDay,
+		chemicals_used_quantity_kg, remarks, co2e_kg, emission_factor_id, deleted_at, deleted_by
+		FROM water_treatment WHERE id = $1 AND org_id = $2 AND deleted_at IS NULL`
+	err := config.DB.QueryRow(query, id, orgID).Scan(
+		&wt.ID, &wt.OrgID, &wt.Date, &wt.Location, &wt.TreatedLitersPerDay, &wt.UltraFiltrationLitersPerDay,
 		&wt.PercentageWaterReused, &wt.ElectricityUsedKWH, &wt.ChemicalsUsedDescription,
-		&wt.ChemicalsUsedQuantityKG, &wt.Remarks,
+		&wt.ChemicalsUsedQuantityKG, &wt.Remarks, &wt.CO2eKg, &wt.EmissionFactorID, &wt.DeletedAt, &wt.DeletedBy,
 	)
 	if err != nil {
 		return nil, err
@@ -78,22 +137,25 @@ func GetWaterTreatmentByID(id int) (*WaterTreatment, error) {
 	return wt, nil
 }
 
-func (wt *WaterTreatment) Update() error {
+func (wt *WaterTreatment) Update(by Actor) error {
+	if err := wt.Footprint.compute(wt); err != nil {
+		return err
+	}
+
 	query := `UPDATE water_treatment SET
 		date=$1, location=$2, treated_liters_per_day=$3, ultra_filtration_liters_per_day=$4,
 		percentage_water_reused=$5, electricity_used_kwh=$6, chemicals_used_description=$7,
-		chemicals_used_quantity_kg=$8, remarks=$9
-		WHERE id=$10`
-	_, err := config.DB.Exec(query,
-		wt.Date, wt.Location, wt.TreatedLitersPerDay, wt.UltraFiltrationLitersPerDay,
-		wt.PercentageWaterReused, wt.ElectricityUsedKWH, wt.ChemicalsUsedDescription,
-		wt.ChemicalsUsedQuantityKG, wt.Remarks, wt.ID,
-	)
-	return err
+		chemicals_used_quantity_kg=$8, remarks=$9, co2e_kg=$10, emission_factor_id=$11, co2e_scope1_kg=$12, co2e_scope2_kg=$13, co2e_scope3_kg=$14
+		WHERE id=$15 AND org_id=$16 AND deleted_at IS NULL`
+	return by.update(TableWaterTreatment, wt.ID, func(tx Querier) (sql.Result, error) {
+		return tx.Exec(query,
+			wt.Date, wt.Location, wt.TreatedLitersPerDay, wt.UltraFiltrationLitersPerDay,
+			wt.PercentageWaterReused, wt.ElectricityUsedKWH, wt.ChemicalsUsedDescription,
+			wt.ChemicalsUsedQuantityKG, wt.Remarks, wt.CO2eKg, wt.EmissionFactorID, wt.Scope1CO2eKg, wt.Scope2CO2eKg, wt.Scope3CO2eKg, wt.ID, by.OrgID,
+		)
+	})
 }
 
-func DeleteWaterTreatment(id int) error {
-	query := `DELETE FROM water_treatment WHERE id=$1`
-	_, err := config.DB.Exec(query, id)
-	return err
+func DeleteWaterTreatment(by Actor, id int) error {
+	return by.delete(TableWaterTreatment, id)
 }
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"carbon-footprint-tracker/config"
+	"carbon-footprint-tracker/models"
+	"log"
+	"time"
+)
+
+const purgeInterval = time.Hour
+
+// deletedCutoff is when records must have been deleted before to be purged.
+func deletedCutoff() time.Time {
+	return time.Now().AddDate(0, 0, -config.DeletedRetentionDays)
+}
+
+// startPurgeJob purges deleted module records past the retention window now
+// and then every purgeInterval, for as long as the server runs.
+func startPurgeJob() {
+	if config.DeletedRetentionDays == 0 {
+		log.Println("DELETED_RETENTION_DAYS is 0, deleted records are never purged")
+		return
+	}
+	go func() {
+		for {
+			n, err := models.PurgeDeleted(deletedCutoff())
+			if err != nil {
+				log.Printf("Failed to purge deleted records: %v", err)
+			} else if n > 0 {
+				log.Printf("Purged %d records deleted more than %d days ago", n, config.DeletedRetentionDays)
+			}
+			time.Sleep(purgeInterval)
+		}
+	}()
+}
@@ -2,24 +2,47 @@ package utils
 
 import (
 	"carbon-footprint-tracker/config"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
 )
 
+const (
+	AccessTokenTTL  = 15 * time.Minute
+	RefreshTokenTTL = 30 * 24 * time.Hour
+	MFAChallengeTTL = 5 * time.Minute
+	OIDCLoginTTL    = 10 * time.Minute
+)
+
 type Claims struct {
-	UserID int    `json:"user_id"`
-	Role   string `json:"role"`
+	UserID       int    `json:"user_id"`
+	OrgID        int    `json:"org_id"` // Organisation the user belongs to
+	Role         string `json:"role"`
+	TokenVersion int    `json:"token_version"` // Must match users.token_version
 	jwt.StandardClaims
 }
 
-func GenerateToken(userID int, role string) (string, error) {
+// GenerateToken issues a short-lived access token. Each token gets a random
+// jti (StandardClaims.Id) so that it can be revoked individually on logout.
+func GenerateToken(userID, orgID int, role string, tokenVersion int) (string, error) {
+	jti, err := GenerateSecureToken(16)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
 	claims := &Claims{
-		UserID: userID,
-		Role:   role,
+		UserID:       userID,
+		OrgID:        orgID,
+		Role:         role,
+		TokenVersion: tokenVersion,
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(time.Hour * 24).Unix(),
-			IssuedAt:  time.Now().Unix(),
+			Id:        jti,
+			ExpiresAt: now.Add(AccessTokenTTL).Unix(),
+			IssuedAt:  now.Unix(),
 		},
 	}
 
@@ -30,3 +53,113 @@ func GenerateToken(userID int, role string) (string, error) {
 	}
 	return tokenString, nil
 }
+
+// MFAChallengeClaims identify a user who has passed the password step of a
+// login and still has to enter a two-factor code.
+type MFAChallengeClaims struct {
+	UserID       int `json:"user_id"`
+	TokenVersion int `json:"token_version"`
+	jwt.StandardClaims
+}
+
+// mfaChallengeKey derives the key challenge tokens are signed with. It
+// differs from the access token key, so a challenge token can never pass
+// as an access token or the other way round.
+func mfaChallengeKey() []byte {
+	mac := hmac.New(sha256.New, config.JWTSecret)
+	mac.Write([]byte("mfa-challenge"))
+	return mac.Sum(nil)
+}
+
+// GenerateMFAChallenge issues the token returned by the password step of a
+// login for a user with two-factor authentication.
+func GenerateMFAChallenge(userID, tokenVersion int) (string, time.Time, error) {
+	jti, err := GenerateSecureToken(16)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(MFAChallengeTTL)
+	claims := &MFAChallengeClaims{
+		UserID:       userID,
+		TokenVersion: tokenVersion,
+		StandardClaims: jwt.StandardClaims{
+			Id:        jti,
+			ExpiresAt: expiresAt.Unix(),
+			IssuedAt:  now.Unix(),
+		},
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(mfaChallengeKey())
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, expiresAt, nil
+}
+
+// ParseMFAChallenge verifies a challenge token and returns its claims.
+func ParseMFAChallenge(tokenString string) (*MFAChallengeClaims, error) {
+	claims := &MFAChallengeClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("unexpected signing method")
+		}
+		return mfaChallengeKey(), nil
+	})
+	if err != nil || !token.Valid {
+		return nil, errors.New("invalid or expired challenge token")
+	}
+	return claims, nil
+}
+
+// OIDCLoginClaims remember a single sign-on login between sending the user to
+// the identity provider and their return to the callback.
+type OIDCLoginClaims struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"` // PKCE code verifier
+	jwt.StandardClaims
+}
+
+// oidcLoginKey derives the key login state is signed with, see mfaChallengeKey.
+func oidcLoginKey() []byte {
+	mac := hmac.New(sha256.New, config.JWTSecret)
+	mac.Write([]byte("oidc-login"))
+	return mac.Sum(nil)
+}
+
+// GenerateOIDCLogin starts a single sign-on login, returning its claims and
+// their signed form for the browser to keep until the callback.
+func GenerateOIDCLogin() (*OIDCLoginClaims, string, error) {
+	claims := &OIDCLoginClaims{
+		StandardClaims: jwt.StandardClaims{ExpiresAt: time.Now().Add(OIDCLoginTTL).Unix()},
+	}
+	for _, value := range []*string{&claims.State, &claims.Nonce, &claims.Verifier} {
+		var err error
+		if *value, err = GenerateSecureToken(32); err != nil {
+			return nil, "", err
+		}
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(oidcLoginKey())
+	if err != nil {
+		return nil, "", err
+	}
+	return claims, token, nil
+}
+
+// ParseOIDCLogin verifies the signed login state and returns its claims.
+func ParseOIDCLogin(tokenString string) (*OIDCLoginClaims, error) {
+	claims := &OIDCLoginClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("unexpected signing method")
+		}
+		return oidcLoginKey(), nil
+	})
+	if err != nil || !token.Valid {
+		return nil, errors.New("invalid or expired login state")
+	}
+	return claims, nil
+}
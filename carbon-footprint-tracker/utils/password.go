@@ -1,12 +1,86 @@
 package utils
 
-import "golang.org/x/crypto/bcrypt"
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/crypto/bcrypt"
+)
 
 func HashPassword(password string) (string, error) {
 	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	return string(bytes), err
 }
+
 func CheckPasswordHash(password, hash string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 	return err == nil
 }
+
+const (
+	MinPasswordLength = 10
+	MaxPasswordLength = 72 // bcrypt ignores anything past 72 bytes
+)
+
+// commonPasswords are rejected outright, whatever their length or mix.
+var commonPasswords = map[string]bool{
+	"password": true, "password1": true, "password123": true, "passw0rd": true,
+	"123456789": true, "1234567890": true, "qwertyuiop": true, "1q2w3e4r5t": true,
+	"iloveyou": true, "welcome1": true, "letmein123": true, "admin123": true,
+	"administrator": true, "changeme": true, "carbonfootprint": true,
+}
+
+// ValidatePasswordStrength checks password against the password policy: at
+// least MinPasswordLength characters using three of lowercase, uppercase,
+// digits and symbols, not a common password, and not containing the user's
+// name or the local part of their email address.
+func ValidatePasswordStrength(password, name, email string) error {
+	if utf8.RuneCountInString(password) < MinPasswordLength {
+		return fmt.Errorf("password must be at least %d characters long", MinPasswordLength)
+	}
+	if len(password) > MaxPasswordLength {
+		return fmt.Errorf("password must be at most %d bytes long", MaxPasswordLength)
+	}
+
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+	classes := 0
+	for _, present := range []bool{lower, upper, digit, symbol} {
+		if present {
+			classes++
+		}
+	}
+	if classes < 3 {
+		return errors.New("password must contain at least three of lowercase letters, uppercase letters, digits and symbols")
+	}
+
+	lowered := strings.ToLower(password)
+	if commonPasswords[lowered] {
+		return errors.New("password is too common")
+	}
+	localPart, _, _ := strings.Cut(email, "@")
+	for _, p := range []string{name, localPart} {
+		for _, word := range strings.FieldsFunc(strings.ToLower(p), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}) {
+			if len(word) >= 3 && strings.Contains(lowered, word) {
+				return errors.New("password must not contain your name or email address")
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+// The RFC 4226 and RFC 6238 test secret, "12345678901234567890", in base32.
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// RFC 4226 Appendix D.
+func TestHOTP(t *testing.T) {
+	want := []string{"755224", "287082", "359152", "969429", "338314", "254676", "287922", "162583", "399871", "520489"}
+	key := []byte("12345678901234567890")
+	for counter, code := range want {
+		if got := hotp(key, int64(counter)); got != code {
+			t.Errorf("hotp(%d) = %s, want %s", counter, got, code)
+		}
+	}
+}
+
+// RFC 6238 Appendix B, SHA-1. The RFC lists 8-digit codes; 6-digit codes are
+// their last 6 digits.
+func TestValidateTOTPVectors(t *testing.T) {
+	tests := []struct {
+		unix int64
+		code string
+		step int64
+	}{
+		{59, "287082", 1},
+		{1111111109, "081804", 37037036},
+		{1111111111, "050471", 37037037},
+		{1234567890, "005924", 41152263},
+		{2000000000, "279037", 66666666},
+		{20000000000, "353130", 666666666},
+	}
+
+	for _, tt := range tests {
+		step, ok := ValidateTOTP(rfcSecret, tt.code, time.Unix(tt.unix, 0))
+		if !ok || step != tt.step {
+			t.Errorf("ValidateTOTP(%s at %d) = %d, %v, want %d, true", tt.code, tt.unix, step, ok, tt.step)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	at := time.Unix(59, 0) // Step 1
+	tests := []struct {
+		name   string
+		secret string
+		code   string
+		step   int64
+		ok     bool
+	}{
+		{"current step", rfcSecret, "287082", 1, true},
+		{"previous step", rfcSecret, "755224", 0, true},
+		{"next step", rfcSecret, "359152", 2, true},
+		{"two steps ahead", rfcSecret, "969429", 0, false},
+		{"spaces", rfcSecret, " 287 082 ", 1, true},
+		{"lowercase secret", "gezdgnbvgy3tqojqgezdgnbvgy3tqojq", "287082", 1, true},
+		{"wrong code", rfcSecret, "287083", 0, false},
+		{"too short", rfcSecret, "28708", 0, false},
+		{"too long", rfcSecret, "2870820", 0, false},
+		{"empty", rfcSecret, "", 0, false},
+		{"letters", rfcSecret, "28708a", 0, false},
+		{"sign", rfcSecret, "+87082", 0, false},
+		{"invalid secret", "not base32!", "287082", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := ValidateTOTP(tt.secret, tt.code, at)
+			if ok != tt.ok || step != tt.step {
+				t.Errorf("ValidateTOTP(%q) = %d, %v, want %d, %v", tt.code, step, ok, tt.step, tt.ok)
+			}
+		})
+	}
+}
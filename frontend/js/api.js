@@ -1,6 +1,38 @@
 const API_BASE_URL = 'http://localhost:8080'; // Your Go backend URL
 
-async function request(method, endpoint, data = null, requiresAuth = true) {
+// Exchanges the stored refresh token for a new token pair. Returns false if
+// the session can no longer be refreshed.
+async function refreshSession() {
+    const refreshToken = localStorage.getItem('refresh_token');
+    if (!refreshToken) {
+        return false;
+    }
+    const response = await fetch(`${API_BASE_URL}/auth/refresh`, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ refresh_token: refreshToken }),
+    });
+    if (!response.ok) {
+        return false;
+    }
+    const data = await response.json();
+    localStorage.setItem('jwt_token', data.token);
+    localStorage.setItem('refresh_token', data.refresh_token);
+    localStorage.setItem('user_role', data.role);
+    localStorage.setItem('org_id', data.org_id);
+    localStorage.setItem('user_locations', JSON.stringify(data.locations));
+    return true;
+}
+
+export function clearSession() {
+    localStorage.removeItem('jwt_token');
+    localStorage.removeItem('refresh_token');
+    localStorage.removeItem('user_role');
+    localStorage.removeItem('org_id');
+    localStorage.removeItem('user_locations');
+}
+
+async function request(method, endpoint, data = null, requiresAuth = true, retried = false) {
     const url = `${API_BASE_URL}${endpoint}`;
     const headers = {
         'Content-Type': 'application/json',
@@ -31,11 +63,13 @@ async function request(method, endpoint, data = null, requiresAuth = true) {
 
         if (!response.ok) {
             console.error(`API Error: ${response.status} - ${JSON.stringify(responseData)}`);
-            // Specific handling for 401/403 errors
-            if (response.status === 401 || response.status === 403) {
-                 // Potentially refresh token or redirect to login
-                 alert('Authentication expired or insufficient permissions. Please log in again.');
-                 localStorage.removeItem('jwt_token');
+            // Access tokens are short-lived, so try refreshing once before giving up
+            if (response.status === 401 && requiresAuth && !retried && await refreshSession()) {
+                return request(method, endpoint, data, requiresAuth, true);
+            }
+            if (response.status === 401 && requiresAuth) {
+                 alert('Your session has expired. Please log in again.');
+                 clearSession();
                  window.location.href = '/index.html';
             }
             throw new Error(responseData.error || 'Something went wrong');
@@ -53,54 +87,98 @@ async function request(method, endpoint, data = null, requiresAuth = true) {
 // Auth
 export const registerUser = (userData) => request('POST', '/auth/register', userData, false);
 export const loginUser = (credentials) => request('POST', '/auth/login', credentials, false);
+// Single sign-on is a browser redirect; the API sends the tokens back to SSO_REDIRECT_URL in the fragment
+export const ssoLoginUrl = `${API_BASE_URL}/auth/oidc/login`;
+export const verifyMfaLogin = (mfaToken, code) => request('POST', '/auth/login/mfa', { mfa_token: mfaToken, code }, false);
+export const setupMfa = () => request('POST', '/auth/mfa/setup');
+export const enableMfa = (code) => request('POST', '/auth/mfa/enable', { code });
+export const logoutUser = () => request('POST', '/auth/logout', { refresh_token: localStorage.getItem('refresh_token') });
 
 // Users (Admin only)
 export const getUsers = () => request('GET', '/users');
 export const addUser = (userData) => request('POST', '/users', userData);
 export const updateUser = (id, userData) => request('PUT', `/users/${id}`, userData);
 export const deleteUser = (id) => request('DELETE', `/users/${id}`);
+export const unlockUser = (id) => request('POST', `/users/${id}/unlock`);
+export const getUserLocations = (id) => request('GET', `/users/${id}/locations`);
+export const setUserLocations = (id, locations) => request('PUT', `/users/${id}/locations`, { locations });
+
+// Roles (Admin only)
+export const getRoles = () => request('GET', '/roles');
+export const getPermissions = () => request('GET', '/roles/permissions');
+export const addRole = (roleData) => request('POST', '/roles', roleData);
+export const updateRole = (name, roleData) => request('PUT', `/roles/${name}`, roleData);
+export const deleteRole = (name) => request('DELETE', `/roles/${name}`);
+
+// Audit log (Admin only)
+export const getAuditLog = (params = {}) => request('GET', `/audit?${new URLSearchParams(params)}`);
+
+// Deleted entries (Admin only): list with { include_deleted: true }, restore by module route, e.g. 'waste'
+export const restoreEntry = (module, id) => request('POST', `/${module}/${id}/restore`);
+
+// API keys (Admin only); the key itself is only in the response to addApiKey
+export const getApiKeys = () => request('GET', '/api_keys');
+export const addApiKey = (keyData) => request('POST', '/api_keys', keyData);
+export const revokeApiKey = (id) => request('DELETE', `/api_keys/${id}`);
+
+// Organisations (Super-admin only)
+export const getOrganisations = () => request('GET', '/organisations');
+export const addOrganisation = (orgData) => request('POST', '/organisations', orgData);
+export const updateOrganisation = (id, orgData) => request('PUT', `/organisations/${id}`, orgData);
+export const deleteOrganisation = (id) => request('DELETE', `/organisations/${id}`);
 
 // Electric Consumption
-export const getElectricConsumptions = () => request('GET', '/electric');
+export const getElectricConsumptions = (params = {}) => request('GET', `/electric?${new URLSearchParams(params)}`);
 export const addElectricConsumption = (data) => request('POST', '/electric', data);
 export const updateElectricConsumption = (id, data) => request('PUT', `/electric/${id}`, data);
 export const deleteElectricConsumption = (id) => request('DELETE', `/electric/${id}`);
 
+// Electricity meters; readings are recorded as grid consumption
+export const getMeters = () => request('GET', '/electric/meters');
+export const addMeter = (meterData) => request('POST', '/electric/meters', meterData);
+export const updateMeter = (id, meterData) => request('PUT', `/electric/meters/${id}`, meterData);
+export const deleteMeter = (id) => request('DELETE', `/electric/meters/${id}`);
+export const getMeterReadings = (id) => request('GET', `/electric/meters/${id}/readings`);
+export const addMeterReading = (id, readingData) => request('POST', `/electric/meters/${id}/readings`, readingData);
+
+// Generator efficiency per set and month; params: from, to, location
+export const getGeneratorAnalytics = (params = {}) => request('GET', `/electric/generators/analytics?${new URLSearchParams(params)}`);
+
 // Population
-export const getPopulations = () => request('GET', '/population');
+export const getPopulations = (params = {}) => request('GET', `/population?${new URLSearchParams(params)}`);
 export const addPopulation = (data) => request('POST', '/population', data);
 export const updatePopulation = (id, data) => request('PUT', `/population/${id}`, data);
 export const deletePopulation = (id) => request('DELETE', `/population/${id}`);
 
 // Transport
-export const getTransports = () => request('GET', '/transport');
+export const getTransports = (params = {}) => request('GET', `/transport?${new URLSearchParams(params)}`);
 export const addTransport = (data) => request('POST', '/transport', data);
 export const updateTransport = (id, data) => request('PUT', `/transport/${id}`, data);
 export const deleteTransport = (id) => request('DELETE', `/transport/${id}`);
 
 // Water Consumption
-export const getWaterConsumptions = () => request('GET', '/water');
+export const getWaterConsumptions = (params = {}) => request('GET', `/water?${new URLSearchParams(params)}`);
 export const addWaterConsumption = (data) => request('POST', '/water', data);
 export const updateWaterConsumption = (id, data) => request('PUT', `/water/${id}`, data);
 export const deleteWaterConsumption = (id) => request('DELETE', `/water/${id}`);
 
 // Waste
-export const getWasteEntries = () => request('GET', '/waste');
+export const getWasteEntries = (params = {}) => request('GET', `/waste?${new URLSearchParams(params)}`);
 export const addWasteEntry = (data) => request('POST', '/waste', data);
 export const updateWasteEntry = (id, data) => request('PUT', `/waste/${id}`, data);
 export const deleteWasteEntry = (id) => request('DELETE', `/waste/${id}`);
 
 // Accommodation
-export const getAccommodations = () => request('GET', '/accommodation');
+export const getAccommodations = (params = {}) => request('GET', `/accommodation?${new URLSearchParams(params)}`);
 export const addAccommodation = (data) => request('POST', '/accommodation', data);
 export const updateAccommodation = (id, data) => request('PUT', `/accommodation/${id}`, data);
 export const deleteAccommodation = (id) => request('DELETE', `/accommodation/${id}`);
 
 // Goods Purchased
-export const getGoodsPurchased = () => request('GET', '/goods');
+export const getGoodsPurchased = (params = {}) => request('GET', `/goods?${new URLSearchParams(params)}`);
 export const addGoodsPurchased = (data) => request('POST', '/goods', data);
 export const updateGoodsPurchased = (id, data) => request('PUT', `/goods/${id}`, data);
 export const deleteGoodsPurchased = (id) => request('DELETE', `/goods/${id}`);
 
 // Dashboard
-export const getDashboardSummary = () => request('GET', '/dashboard');
\ No newline at end of file
+export const getDashboardSummary = (params = {}) => request('GET', `/dashboard?${new URLSearchParams(params)}`);
\ No newline at end of file
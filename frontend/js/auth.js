@@ -1,9 +1,13 @@
-import { registerUser, loginUser } from './api.js';
+import { registerUser, loginUser, verifyMfaLogin, setupMfa, enableMfa, ssoLoginUrl } from './api.js';
 import { createMessage, clearMessages } from './components.js';
 
 document.addEventListener('DOMContentLoaded', () => {
     const registerForm = document.getElementById('registerForm');
     const loginForm = document.getElementById('loginForm');
+    const mfaForm = document.getElementById('mfaForm');
+    const mfaSetup = document.getElementById('mfaSetup');
+    const mfaEnableForm = document.getElementById('mfaEnableForm');
+    let mfaToken = null; // Challenge from the password step of a two-factor login
     const messageContainer = document.getElementById('messageContainer');
 
     if (registerForm) {
@@ -14,10 +18,10 @@ document.addEventListener('DOMContentLoaded', () => {
             const name = registerForm.name.value;
             const email = registerForm.email.value;
             const password = registerForm.password.value;
-            const role = registerForm.role.value;
+            const invite_token = registerForm.invite_token.value;
 
             try {
-                const data = await registerUser({ name, email, password, role });
+                const data = await registerUser({ name, email, password, invite_token });
                 messageContainer.appendChild(createMessage('success', data.message || 'Registration successful!'));
                 registerForm.reset();
             } catch (error) {
@@ -26,6 +30,24 @@ document.addEventListener('DOMContentLoaded', () => {
         });
     }
 
+    const ssoLogin = document.getElementById('ssoLogin');
+    if (ssoLogin) {
+        ssoLogin.href = ssoLoginUrl;
+    }
+
+    // Back from single sign-on, with the tokens in the fragment
+    const sso = new URLSearchParams(window.location.hash.slice(1));
+    if (loginForm && sso.has('token')) {
+        history.replaceState(null, '', window.location.pathname);
+        completeLogin({
+            token: sso.get('token'),
+            refresh_token: sso.get('refresh_token'),
+            role: sso.get('role'),
+            org_id: sso.get('org_id'),
+            locations: JSON.parse(sso.get('locations')),
+        });
+    }
+
     if (loginForm) {
         loginForm.addEventListener('submit', async (e) => {
             e.preventDefault();
@@ -36,13 +58,79 @@ document.addEventListener('DOMContentLoaded', () => {
 
             try {
                 const data = await loginUser({ email, password });
-                localStorage.setItem('jwt_token', data.token);
-                localStorage.setItem('user_role', data.role); // Store user role
-                messageContainer.appendChild(createMessage('success', data.message || 'Login successful! Redirecting...'));
-                window.location.href = '/dashboard.html'; // Redirect to dashboard
+                if (data.mfa_required) {
+                    // Second step: the password was right, now ask for a code
+                    mfaToken = data.mfa_token;
+                    loginForm.style.display = 'none';
+                    mfaForm.style.display = 'block';
+                    messageContainer.appendChild(createMessage('success', data.message));
+                    return;
+                }
+                await completeLogin(data);
             } catch (error) {
                 messageContainer.appendChild(createMessage('error', error.message || 'Login failed.'));
             }
         });
     }
+
+    if (mfaForm) {
+        mfaForm.addEventListener('submit', async (e) => {
+            e.preventDefault();
+            clearMessages(messageContainer);
+
+            try {
+                const data = await verifyMfaLogin(mfaToken, mfaForm.code.value);
+                await completeLogin(data);
+            } catch (error) {
+                messageContainer.appendChild(createMessage('error', error.message || 'Verification failed.'));
+                if (/log in again/.test(error.message)) {
+                    mfaForm.reset();
+                    mfaForm.style.display = 'none';
+                    loginForm.style.display = 'block';
+                }
+            }
+        });
+    }
+
+    if (mfaEnableForm) {
+        mfaEnableForm.addEventListener('submit', async (e) => {
+            e.preventDefault();
+            clearMessages(messageContainer);
+
+            try {
+                const data = await enableMfa(mfaEnableForm.code.value);
+                mfaEnableForm.style.display = 'none';
+                document.getElementById('mfaRecoveryCodeList').textContent = data.recovery_codes.join('\n');
+                document.getElementById('mfaRecoveryCodes').style.display = 'block';
+                messageContainer.appendChild(createMessage('success', data.message));
+            } catch (error) {
+                messageContainer.appendChild(createMessage('error', error.message || 'Failed to enable two-factor authentication.'));
+            }
+        });
+        document.getElementById('mfaContinue').addEventListener('click', () => {
+            window.location.href = '/dashboard.html';
+        });
+    }
+
+    // Stores the session and goes to the dashboard, unless the account has to
+    // set up two-factor authentication first.
+    async function completeLogin(data) {
+        localStorage.setItem('jwt_token', data.token);
+        localStorage.setItem('refresh_token', data.refresh_token);
+        localStorage.setItem('user_role', data.role); // Store user role
+        localStorage.setItem('org_id', data.org_id);
+        localStorage.setItem('user_locations', JSON.stringify(data.locations)); // null if unrestricted
+
+        if (data.mfa_setup_required) {
+            const setup = await setupMfa();
+            loginForm.style.display = 'none';
+            mfaSetup.style.display = 'block';
+            document.getElementById('mfaSecret').textContent = setup.secret;
+            document.getElementById('mfaUri').href = setup.otpauth_uri;
+            return;
+        }
+
+        messageContainer.appendChild(createMessage('success', data.message || 'Login successful! Redirecting...'));
+        window.location.href = '/dashboard.html'; // Redirect to dashboard
+    }
 });
\ No newline at end of file
@@ -28,12 +28,25 @@ document.addEventListener('DOMContentLoaded', async () => {
     }
 
     // Handle logout
-    document.getElementById('logoutButton').addEventListener('click', () => {
-        localStorage.removeItem('jwt_token');
-        localStorage.removeItem('user_role');
+    document.getElementById('logoutButton').addEventListener('click', async () => {
+        try {
+            await api.logoutUser(); // Revoke the tokens server-side
+        } catch (error) {
+            console.error('Logout failed:', error);
+        }
+        api.clearSession();
         window.location.href = '/index.html';
     });
 
+    // Users limited to some locations enter data for the first of them by default
+    const locations = JSON.parse(localStorage.getItem('user_locations') || 'null');
+    if (locations && locations.length) {
+        document.querySelectorAll('input[name="location"]').forEach(input => {
+            input.defaultValue = locations[0];
+            input.value = locations[0];
+        });
+    }
+
     // --- Load Data on Dashboard ---
     await loadDashboardSummary();
     await loadElectricConsumptions();
@@ -72,15 +85,36 @@ async function loadDashboardSummary() {
                     <h3>Per-Capita CO2e</h3>
                     <p>${summary.per_capita_footprint_co2e.toFixed(2)} kg CO2e</p>
                 </div>
+                <div class="dashboard-card">
+                    <h3>Net CO2e (after solar)</h3>
+                    <p>${summary.net_carbon_footprint_co2e.toFixed(2)} kg CO2e</p>
+                </div>
             </div>
+            <h3>Electricity</h3>
+            <ul>
+                <li><strong>Gross grid emissions:</strong> ${summary.electricity.gross_grid_co2e.toFixed(2)} kg CO2e</li>
+                <li><strong>Generator emissions:</strong> ${summary.electricity.generator_co2e.toFixed(2)} kg CO2e</li>
+                <li><strong>Avoided by solar:</strong> ${summary.electricity.avoided_solar_co2e.toFixed(2)} kg CO2e</li>
+                <li><strong>Net electricity emissions:</strong> ${summary.electricity.net_co2e.toFixed(2)} kg CO2e</li>
+                <li><strong>Solar generated:</strong> ${summary.electricity.solar_generated_kwh.toFixed(2)} kWh
+                    (${summary.electricity.solar_self_consumed_kwh.toFixed(2)} used on site, ${summary.electricity.solar_exported_kwh.toFixed(2)} exported)</li>
+                <li><strong>Renewable share:</strong> ${summary.electricity.renewable_share_percent.toFixed(1)}%</li>
+            </ul>
             <h3>Component Breakdown (kg CO2e)</h3>
             <ul>
                 ${Object.entries(summary.component_breakdown).map(([key, value]) => `
                     <li><strong>${key}:</strong> ${value.toFixed(2)}</li>
                 `).join('')}
             </ul>
-            <!-- Trends would go here, requiring more complex chart libraries -->
+            <h3>Trend (kg CO2e per ${summary.trends ? summary.trends.granularity : 'day'})</h3>
+            <div id="dashboardTrend"></div>
         `;
+        const trendContainer = document.getElementById('dashboardTrend');
+        if (summary.trends && summary.trends.total.length > 0) {
+            trendContainer.appendChild(createTable(['Period', 'CO2e'], summary.trends.total));
+        } else {
+            trendContainer.innerHTML = '<p>No emissions recorded for this period.</p>';
+        }
     } catch (error) {
         messageContainer.appendChild(createMessage('error', `Failed to load dashboard summary: ${error.message}`));
     }
@@ -88,7 +122,7 @@ async function loadDashboardSummary() {
 
 async function loadElectricConsumptions() {
     try {
-        const data = await api.getElectricConsumptions();
+        const { data } = await api.getElectricConsumptions();
         if (data.length > 0) {
             const headers = ['ID', 'Source', 'KWH', 'Fuel Liters', 'Hours', 'Date', 'Location'];
             electricDataList.innerHTML = ''; // Clear previous data
@@ -103,7 +137,7 @@ async function loadElectricConsumptions() {
 
 async function loadPopulations() {
     try {
-        const data = await api.getPopulations();
+        const { data } = await api.getPopulations();
         if (data.length > 0) {
             const headers = ['ID', 'Registered Count', 'Floating Count', 'Date', 'Location'];
             populationDataList.innerHTML = '';
@@ -118,7 +152,7 @@ async function loadPopulations() {
 
 async function loadTransports() {
     try {
-        const data = await api.getTransports();
+        const { data } = await api.getTransports();
         if (data.length > 0) {
             const headers = ['ID', 'Vehicle Type', 'Fuel Type', 'Distance KM', 'Fuel Liters', 'Date', 'Location'];
             transportDataList.innerHTML = '';
@@ -133,7 +167,7 @@ async function loadTransports() {
 
 async function loadWaterConsumptions() {
     try {
-        const data = await api.getWaterConsumptions();
+        const { data } = await api.getWaterConsumptions();
         if (data.length > 0) {
             const headers = ['ID', 'Meter Reading', 'Date', 'Location'];
             waterDataList.innerHTML = '';
@@ -148,7 +182,7 @@ async function loadWaterConsumptions() {
 
 async function loadWasteEntries() {
     try {
-        const data = await api.getWasteEntries();
+        const { data } = await api.getWasteEntries();
         if (data.length > 0) {
             const headers = ['ID', 'Spot Name', 'Waste Type', 'Weight KG', 'Date', 'Location'];
             wasteDataList.innerHTML = '';
@@ -163,7 +197,7 @@ async function loadWasteEntries() {
 
 async function loadAccommodations() {
     try {
-        const data = await api.getAccommodations();
+        const { data } = await api.getAccommodations();
         if (data.length > 0) {
             const headers = ['ID', 'People Count', 'Nights', 'Date', 'Location'];
             accommodationDataList.innerHTML = '';
@@ -178,7 +212,7 @@ async function loadAccommodations() {
 
 async function loadGoodsPurchased() {
     try {
-        const data = await api.getGoodsPurchased();
+        const { data } = await api.getGoodsPurchased();
         if (data.length > 0) {
             const headers = ['ID', 'Item Name', 'Quantity', 'Cost', 'Date', 'Location'];
             goodsDataList.innerHTML = '';